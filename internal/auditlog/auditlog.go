@@ -0,0 +1,339 @@
+package auditlog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ba58ajbse/envcraft/internal/envdiff"
+	"github.com/joho/godotenv"
+)
+
+// EnvLog holds the path of the audit log. Auditing is disabled when it is not set.
+const EnvLog = "ENVCRAFT_AUDIT_LOG"
+
+// EnvKey optionally holds the secret the log is keyed with. Values are always hashed with
+// HMAC-SHA256 so that low-entropy values cannot be recovered from the log by brute force;
+// without EnvKey, a random key is generated and kept next to the log (see Key). When EnvKey is
+// set, the hash chain is keyed with it as well.
+const EnvKey = "ENVCRAFT_AUDIT_KEY"
+
+// keySuffix is appended to the path of the log to name its generated key file.
+const keySuffix = ".key"
+
+// hmacPrefix marks values and entry hashes computed with HMAC-SHA256.
+const hmacPrefix = "hmac-sha256:"
+
+// ErrTampered is returned when the hash chain of the log is broken.
+var ErrTampered = errors.New("audit log hash chain is broken")
+
+// Change is a key changed by an audited operation, with its hashed old and new values.
+type Change struct {
+	Kind string `json:"kind"`
+	Key  string `json:"key"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// Entry is an audited operation. Prev is the hash of the previous entry and Hash
+// the hash of this entry without its Hash field, chaining the entries together.
+//
+// Without EnvKey the chain is a plain SHA-256 chain: anyone able to write the log can
+// recompute it, so it only catches accidental corruption and careless edits. With EnvKey the
+// entries are hashed with HMAC-SHA256 and cannot be forged without the key.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Host    string    `json:"host"`
+	Command string    `json:"command"`
+	File    string    `json:"file"`
+	Changes []Change  `json:"changes"`
+	Prev    string    `json:"prev"`
+	Hash    string    `json:"hash,omitempty"`
+}
+
+// Path returns the path of the audit log, or "" if auditing is disabled.
+func Path() string {
+	return os.Getenv(EnvLog)
+}
+
+// Recorder returns an fs.OnWrite hook auditing the writes of command when auditing
+// is enabled. Failing to audit does not fail the command; a warning is printed instead.
+func Recorder(command string) func(filePath string, before, after []byte) {
+	return func(filePath string, before, after []byte) {
+		logPath := Path()
+		if logPath == "" || string(before) == string(after) {
+			return
+		}
+		secret, err := Key(logPath)
+		if err == nil {
+			err = Append(logPath, NewEntry(command, filePath, before, after, secret))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: error writing audit log: %v\n", err)
+		}
+	}
+}
+
+// NewEntry returns the entry of an operation of command that replaced before with
+// after in filePath, for the current user and host. Values are only stored hashed with secret.
+func NewEntry(command, filePath string, before, after, secret []byte) *Entry {
+	if abs, err := filepath.Abs(filePath); err == nil {
+		filePath = abs
+	}
+	host, _ := os.Hostname()
+	return &Entry{
+		Time:    time.Now().UTC(),
+		User:    currentUser(),
+		Host:    host,
+		Command: command,
+		File:    filePath,
+		Changes: changes(before, after, secret),
+	}
+}
+
+// Append chains entry to the last entry of the log at logPath and appends it,
+// creating the log readable by the owner only. The log is locked while the last entry is
+// read and the new one written, so that concurrent envcraft runs do not fork the chain.
+func Append(logPath string, entry *Entry) error {
+	file, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("error opening audit log %s: %w", logPath, err)
+	}
+	defer file.Close()
+	if err := lock(file); err != nil {
+		return fmt.Errorf("error locking audit log %s: %w", logPath, err)
+	}
+	defer unlock(file)
+
+	last, err := lastHash(file)
+	if err != nil {
+		return fmt.Errorf("audit log %s: %w", logPath, err)
+	}
+	entry.Prev = last
+	entry.Hash = ""
+	sum, err := entry.digest(chainKey())
+	if err != nil {
+		return err
+	}
+	entry.Hash = sum
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding audit entry: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing audit log %s: %w", logPath, err)
+	}
+	return nil
+}
+
+// Read returns the entries of the log at logPath, checking the hash chain. When the
+// chain is broken, the entries are returned along with an error wrapping ErrTampered.
+func Read(logPath string) ([]Entry, error) {
+	file, err := os.Open(logPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading audit log %s: %w", logPath, err)
+	}
+	defer file.Close()
+	return read(file)
+}
+
+func read(r io.Reader) ([]Entry, error) {
+	entries := []Entry{}
+	key := chainKey()
+	var broken error
+	prev := ""
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, fmt.Errorf("error parsing audit log line %d: %w", n, err)
+		}
+		if broken == nil {
+			if err := entry.verify(prev, key); err != nil {
+				broken = fmt.Errorf("%w at line %d: %v", ErrTampered, n, err)
+			}
+		}
+		prev = entry.Hash
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return entries, fmt.Errorf("error reading audit log: %w", err)
+	}
+	return entries, broken
+}
+
+// verify checks that the entry follows the entry hashed prev and that its hash, keyed with key
+// if set, matches its content.
+func (e Entry) verify(prev string, key []byte) error {
+	if e.Prev != prev {
+		return errors.New("previous hash does not match")
+	}
+	keyed := strings.HasPrefix(e.Hash, hmacPrefix)
+	if keyed && len(key) == 0 {
+		return fmt.Errorf("entry is keyed, set %s to verify it", EnvKey)
+	}
+	if !keyed && len(key) > 0 {
+		return fmt.Errorf("entry is not keyed with %s", EnvKey)
+	}
+	stored := e.Hash
+	e.Hash = ""
+	sum, err := e.digest(key)
+	if err != nil {
+		return err
+	}
+	if sum != stored {
+		return errors.New("entry hash does not match its content")
+	}
+	return nil
+}
+
+// digest returns the HMAC-SHA256 of the JSON encoding of the entry keyed with key, or its
+// SHA-256 if key is empty.
+func (e Entry) digest(key []byte) (string, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("error encoding audit entry: %w", err)
+	}
+	if len(key) == 0 {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:]), nil
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hmacPrefix + hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// chainKey returns the key of the hash chain: EnvKey, or nil if it is not set.
+func chainKey() []byte {
+	return []byte(os.Getenv(EnvKey))
+}
+
+// lastHash returns the hash of the last entry of the log, or "" if the log is empty.
+func lastHash(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("error reading: %w", err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	last := lines[len(lines)-1]
+	if len(last) == 0 {
+		return "", nil
+	}
+	var entry Entry
+	if err := json.Unmarshal(last, &entry); err != nil {
+		return "", fmt.Errorf("error parsing last entry: %w", err)
+	}
+	return entry.Hash, nil
+}
+
+// changes returns the keys changed from before to after with values hashed with secret.
+// Content that cannot be parsed, such as an encrypted file, has no changes.
+func changes(before, after, secret []byte) []Change {
+	result := []Change{}
+	old, err := godotenv.UnmarshalBytes(before)
+	if err != nil {
+		return result
+	}
+	new, err := godotenv.UnmarshalBytes(after)
+	if err != nil {
+		return result
+	}
+	for _, change := range envdiff.Compare(old, new) {
+		c := Change{Kind: change.Kind, Key: change.Key}
+		if change.Kind != envdiff.Added {
+			c.Old = HashValue(secret, change.Key, change.Old)
+		}
+		if change.Kind != envdiff.Removed {
+			c.New = HashValue(secret, change.Key, change.New)
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+// HashValue returns the HMAC-SHA256 of the value of key, keyed with secret.
+func HashValue(secret []byte, key, value string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(key))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hmacPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Key returns the secret the values of the log at logPath are hashed with: EnvKey when it is
+// set, otherwise a random key kept in logPath+".key", generated on first use and readable by
+// the owner only.
+func Key(logPath string) ([]byte, error) {
+	if secret := os.Getenv(EnvKey); secret != "" {
+		return []byte(secret), nil
+	}
+	keyPath := logPath + keySuffix
+	data, err := os.ReadFile(keyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return newKey(keyPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading audit key %s: %w", keyPath, err)
+	}
+	return decodeKey(keyPath, data)
+}
+
+// newKey generates a random key and writes it to keyPath. If another run created the file
+// first, its key is used instead.
+func newKey(keyPath string) ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("error generating audit key: %w", err)
+	}
+	file, err := os.OpenFile(keyPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		data, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading audit key %s: %w", keyPath, err)
+		}
+		return decodeKey(keyPath, data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating audit key %s: %w", keyPath, err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(hex.EncodeToString(secret) + "\n"); err != nil {
+		return nil, fmt.Errorf("error writing audit key %s: %w", keyPath, err)
+	}
+	return secret, nil
+}
+
+// decodeKey decodes the hex key read from keyPath.
+func decodeKey(keyPath string, data []byte) ([]byte, error) {
+	secret, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(secret) == 0 {
+		return nil, fmt.Errorf("invalid audit key %s", keyPath)
+	}
+	return secret, nil
+}
+
+// currentUser returns the name of the user running envcraft.
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,141 @@
+package auditlog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ba58ajbse/envcraft/internal/envdiff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendAndRead(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	secret := []byte("audit-secret")
+	require.NoError(t, Append(logPath, NewEntry("update", ".env", []byte("A=1\nB=2\n"), []byte("A=secret\nB=2\n"), secret)))
+	require.NoError(t, Append(logPath, NewEntry("delete", ".env", []byte("A=secret\nB=2\n"), []byte("A=secret\n"), secret)))
+
+	info, err := os.Stat(logPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	data, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+
+	entries, err := Read(logPath)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "", entries[0].Prev)
+	assert.Equal(t, entries[0].Hash, entries[1].Prev)
+	assert.Equal(t, []Change{{Kind: envdiff.Changed, Key: "A", Old: HashValue(secret, "A", "1"), New: HashValue(secret, "A", "secret")}}, entries[0].Changes)
+	assert.Equal(t, []Change{{Kind: envdiff.Removed, Key: "B", Old: HashValue(secret, "B", "2")}}, entries[1].Changes)
+	assert.True(t, filepath.IsAbs(entries[0].File))
+}
+
+func TestReadTampered(t *testing.T) {
+	tests := map[string]func(lines []string) []string{
+		"edited entry": func(lines []string) []string {
+			lines[0] = strings.Replace(lines[0], `"command":"update"`, `"command":"add"`, 1)
+			return lines
+		},
+		"removed entry": func(lines []string) []string {
+			return lines[1:]
+		},
+		"reordered entries": func(lines []string) []string {
+			return []string{lines[1], lines[0], lines[2]}
+		},
+	}
+
+	for name, tamper := range tests {
+		t.Run(name, func(t *testing.T) {
+			logPath := filepath.Join(t.TempDir(), "audit.log")
+			for _, value := range []string{"1", "2", "3"} {
+				require.NoError(t, Append(logPath, NewEntry("update", ".env", []byte("A=0\n"), []byte("A="+value+"\n"), []byte("secret"))))
+			}
+			data, err := os.ReadFile(logPath)
+			require.NoError(t, err)
+			lines := tamper(strings.Split(strings.TrimSpace(string(data)), "\n"))
+			require.NoError(t, os.WriteFile(logPath, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+
+			_, err = Read(logPath)
+			assert.ErrorIs(t, err, ErrTampered)
+		})
+	}
+}
+
+func TestReadKeyed(t *testing.T) {
+	t.Setenv(EnvKey, "audit-secret")
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	for _, value := range []string{"1", "2"} {
+		require.NoError(t, Append(logPath, NewEntry("update", ".env", []byte("A=0\n"), []byte("A="+value+"\n"), []byte("secret"))))
+	}
+	entries, err := Read(logPath)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(entries[0].Hash, "hmac-sha256:"))
+
+	// An entry rewritten with a plain SHA-256 chain, as anyone without the key could, is rejected.
+	forged := entries[1]
+	forged.Command = "add"
+	forged.Hash = ""
+	forged.Hash, err = forged.digest(nil)
+	require.NoError(t, err)
+	assert.Error(t, forged.verify(entries[0].Hash, []byte("audit-secret")))
+
+	t.Setenv(EnvKey, "")
+	_, err = Read(logPath)
+	assert.ErrorIs(t, err, ErrTampered)
+}
+
+func TestAppendWaitsForLock(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_RDWR, 0o600)
+	require.NoError(t, err)
+	defer file.Close()
+	require.NoError(t, lock(file))
+
+	done := make(chan error)
+	go func() {
+		done <- Append(logPath, NewEntry("update", ".env", []byte("A=0\n"), []byte("A=1\n"), []byte("secret")))
+	}()
+	select {
+	case <-done:
+		t.Fatal("Append wrote the log while it was locked")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, unlock(file))
+	require.NoError(t, <-done)
+	entries, err := Read(logPath)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestHashValue(t *testing.T) {
+	hashed := HashValue([]byte("audit-secret"), "A", "value")
+	assert.True(t, strings.HasPrefix(hashed, "hmac-sha256:"))
+	assert.NotEqual(t, hashed, HashValue([]byte("audit-secret"), "B", "value"))
+	assert.NotEqual(t, hashed, HashValue([]byte("other-secret"), "A", "value"))
+}
+
+func TestKey(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	t.Setenv(EnvKey, "")
+
+	generated, err := Key(logPath)
+	require.NoError(t, err)
+	assert.Len(t, generated, 32)
+	info, err := os.Stat(logPath + ".key")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	again, err := Key(logPath)
+	require.NoError(t, err)
+	assert.Equal(t, generated, again)
+
+	t.Setenv(EnvKey, "audit-secret")
+	configured, err := Key(logPath)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("audit-secret"), configured)
+}
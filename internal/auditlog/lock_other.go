@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package auditlog
+
+import "os"
+
+// lock does nothing on systems without file locks.
+func lock(file *os.File) error {
+	return nil
+}
+
+// unlock does nothing on systems without file locks.
+func unlock(file *os.File) error {
+	return nil
+}
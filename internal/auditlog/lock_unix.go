@@ -0,0 +1,18 @@
+//go:build unix
+
+package auditlog
+
+import (
+	"os"
+	"syscall"
+)
+
+// lock takes an exclusive lock on file, waiting for other holders to release it.
+func lock(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+}
+
+// unlock releases the lock taken by lock.
+func unlock(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -6,20 +6,26 @@ import (
 	"fmt"
 	"os"
 	"slices"
-	"strconv"
 	"strings"
 
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envfile"
 	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/ba58ajbse/envcraft/internal/input"
 	"github.com/ba58ajbse/envcraft/internal/lines"
 )
 
 // AddOptions holds the options for adding a new environment variable.
 type AddOptions struct {
-	Key      string
-	Value    string
-	FilePath string
-	Line     int
-	Create   bool
+	Key       string
+	Value     string
+	FilePath  string
+	Line      int
+	Create    bool
+	Source    input.ValueSource
+	IfMissing bool
+	Secret    bool
+	Keys      crypt.KeyOptions
 }
 
 // AddCmd represents the command for adding a new environment variable to a file.
@@ -72,9 +78,23 @@ func (c *AddCmd) Exec() error {
 	}
 
 	if err := c.duplicateKey(); err != nil {
+		if c.Options.IfMissing && errors.Is(err, ErrDuplicateKey) {
+			fmt.Printf("%s is already set, skipped.\n", c.Options.Key)
+			return nil
+		}
 		return err
 	}
 
+	if err := c.readValue(); err != nil {
+		return err
+	}
+
+	if c.Options.Secret {
+		if err := c.encryptValue(); err != nil {
+			return err
+		}
+	}
+
 	newlines, err := c.makeNewLines()
 	if err != nil {
 		return err
@@ -95,6 +115,35 @@ func (c *AddCmd) Exec() error {
 	return nil
 }
 
+// readValue reads the value from the chosen source when it was not given on the command line.
+func (c *AddCmd) readValue() error {
+	if !c.Options.Source.IsSet() {
+		return nil
+	}
+	value, err := c.Options.Source.Read(c.Options.Key)
+	if err != nil {
+		return err
+	}
+	c.Options.Value = value
+
+	return nil
+}
+
+// encryptValue replaces the value with its encrypted form, keeping the key readable.
+func (c *AddCmd) encryptValue() error {
+	keys, err := envfile.LoadValueKeys(c.OrgLines, c.Options.Keys, true)
+	if err != nil {
+		return err
+	}
+	value, err := crypt.NewValueCipher(keys).Encrypt(c.Options.Key, c.Options.Value)
+	if err != nil {
+		return err
+	}
+	c.Options.Value = value
+
+	return nil
+}
+
 // readLines reads all lines from the file specified in AddCmd and stores them in OrgLines.
 func (c *AddCmd) readLines() error {
 	lines, err := fs.ReadLines(c.filePath())
@@ -127,15 +176,19 @@ func (c *AddCmd) duplicateKey() error {
 
 // makeNewLines generates the new lines to be written to the file after adding the new variable.
 func (c *AddCmd) makeNewLines() ([]string, error) {
+	line, err := c.keyAndValue()
+	if err != nil {
+		return nil, err
+	}
 	newLines := slices.Clone(c.OrgLines)
 	if c.insertLineNum() == 0 {
 		if lines.IsEmptyOrBlank(newLines) {
-			return []string{c.keyAndValue()}, nil
+			return []string{line}, nil
 		}
 		if lines.EndsWithoutNewline(newLines) {
 			newLines[len(newLines)-1] += "\n" // Add a newline if the last line does not end with a newline
 		}
-		newLines = slices.Insert(newLines, len(newLines), c.keyAndValue())
+		newLines = slices.Insert(newLines, len(newLines), line)
 		return newLines, nil
 	}
 
@@ -144,15 +197,15 @@ func (c *AddCmd) makeNewLines() ([]string, error) {
 			newLines[len(newLines)-1] += "\n" // Add a newline if the last line does not end with a newline
 		}
 		emptyLines := slices.Repeat([]string{"\n"}, c.insertLineNum()-len(c.OrgLines)-1)
-		newLines = slices.Concat(newLines, emptyLines, []string{c.keyAndValue()})
+		newLines = slices.Concat(newLines, emptyLines, []string{line})
 		return newLines, nil
 	}
 
 	if len(newLines) == 1 && newLines[0] == "" {
 		// If the file is empty, add the new line
-		return []string{c.keyAndValue()}, nil
+		return []string{line}, nil
 	}
-	newLines = slices.Insert(newLines, c.insertLineNum()-1, c.keyAndValue()+"\n")
+	newLines = slices.Insert(newLines, c.insertLineNum()-1, line+"\n")
 	return newLines, nil
 }
 
@@ -181,8 +234,13 @@ func (c *AddCmd) filePath() string {
 	return c.Options.FilePath
 }
 
-func (c *AddCmd) keyAndValue() string {
-	return c.Options.Key + "=" + strconv.Quote(c.Options.Value)
+// keyAndValue returns the line KEY="value", quoted so that dotenv loaders read the value back unchanged.
+func (c *AddCmd) keyAndValue() (string, error) {
+	value, err := lines.Quote(c.Options.Value)
+	if err != nil {
+		return "", err
+	}
+	return c.Options.Key + "=" + value, nil
 }
 
 func (c *AddCmd) keyEqual(key string) bool {
@@ -200,20 +258,35 @@ func ParseAddOptions(opts []string) (*AddOptions, error) {
 	line := flagSet.Int("l", 0, "Line number to insert the variable (optional)")
 	create := flagSet.Bool("c", false, "Create the file if it does not exist")
 	flagSet.BoolVar(create, "create", false, "Create the file if it does not exist")
+	ifMissing := flagSet.Bool("if-missing", false, "Do nothing if the key is already set")
+	secret := flagSet.Bool("secret", false, "Encrypt the value, keeping the key readable")
 
-	var key, value string
+	var keys crypt.KeyOptions
+	keys.AddFlags(flagSet)
+
+	var source input.ValueSource
+	source.AddFlags(flagSet)
+
+	args := []string{}
+	for len(opts) > 0 && len(args) < 2 && !strings.HasPrefix(opts[0], "-") {
+		args = append(args, opts[0])
+		opts = opts[1:]
+	}
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	args = append(args, flagSet.Args()...)
 
-	if len(opts) >= 2 && !strings.HasPrefix(opts[0], "-") && !strings.HasPrefix(opts[1], "-") {
-		key = opts[0]
-		value = opts[1]
-		if err := flagSet.Parse(opts[2:]); err != nil {
-			return nil, err
+	if err := source.Validate(); err != nil {
+		return nil, err
+	}
+	var key, value string
+	if source.IsSet() {
+		if len(args) != 1 {
+			return nil, errors.New("only the key is allowed when the value is read from another source")
 		}
+		key = args[0]
 	} else {
-		if err := flagSet.Parse(opts); err != nil {
-			return nil, err
-		}
-		args := flagSet.Args()
 		if len(args) < 2 {
 			return nil, errors.New("key and value are required")
 		}
@@ -234,11 +307,15 @@ func ParseAddOptions(opts []string) (*AddOptions, error) {
 	}
 
 	return &AddOptions{
-		Key:      key,
-		Value:    value,
-		FilePath: *file,
-		Line:     *line,
-		Create:   *create,
+		Key:       key,
+		Value:     value,
+		FilePath:  *file,
+		Line:      *line,
+		Create:    *create,
+		Source:    source,
+		IfMissing: *ifMissing,
+		Secret:    *secret,
+		Keys:      keys,
 	}, nil
 }
 
@@ -3,8 +3,12 @@ package add
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -106,6 +110,36 @@ func Test_ParseAddOptions(t *testing.T) {
 			want:    &AddOptions{Key: "KEY", Value: "VALUE", FilePath: "test.env", Line: 2, Create: true},
 			wantErr: false,
 		},
+		"value from stdin": {
+			opts:    []string{"KEY", "--stdin", "-f", "test.env"},
+			want:    &AddOptions{Key: "KEY", FilePath: "test.env", Source: input.ValueSource{Stdin: true}},
+			wantErr: false,
+		},
+		"value from env": {
+			opts:    []string{"-f", "test.env", "--from-env", "SRC", "KEY"},
+			want:    &AddOptions{Key: "KEY", FilePath: "test.env", Source: input.ValueSource{FromEnv: "SRC"}},
+			wantErr: false,
+		},
+		"positional value with stdin": {
+			opts:    []string{"KEY", "VALUE", "--stdin", "-f", "test.env"},
+			want:    nil,
+			wantErr: true,
+		},
+		"generate if missing": {
+			opts:    []string{"KEY", "--generate", "--charset", "uuid", "--if-missing", "-f", "test.env"},
+			want:    &AddOptions{Key: "KEY", FilePath: "test.env", Source: input.ValueSource{Generate: true, Charset: "uuid"}, IfMissing: true},
+			wantErr: false,
+		},
+		"charset without generate": {
+			opts:    []string{"KEY", "VALUE", "--charset", "hex", "-f", "test.env"},
+			want:    nil,
+			wantErr: true,
+		},
+		"multiple value sources": {
+			opts:    []string{"KEY", "--stdin", "--prompt", "-f", "test.env"},
+			want:    nil,
+			wantErr: true,
+		},
 		"missing key/value": {
 			opts:    []string{"KEY"},
 			want:    nil,
@@ -240,6 +274,145 @@ func TestExec_CreatesFileWhenOptionEnabled(t *testing.T) {
 	assert.Equal(t, "FOO=\"bar\"", string(data))
 }
 
+func TestExec_ReadsMultiLineValueFromStdin(t *testing.T) {
+	tmpDir := t.TempDir()
+	targetFile := filepath.Join(tmpDir, "new.env")
+
+	orgStdin := input.Stdin
+	input.Stdin = strings.NewReader("-----BEGIN KEY-----\nabc\n-----END KEY-----\n")
+	defer func() { input.Stdin = orgStdin }()
+
+	options := &AddOptions{
+		Key:      "CERT",
+		FilePath: targetFile,
+		Create:   true,
+		Source:   input.ValueSource{Stdin: true},
+	}
+
+	cmd, err := NewAddCmd(options)
+	assert.NoError(t, err)
+
+	err = cmd.Exec()
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(targetFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "CERT=\"-----BEGIN KEY-----\\nabc\\n-----END KEY-----\"", string(data))
+}
+
+func TestExec_ValueRoundTrip(t *testing.T) {
+	tests := map[string]string{
+		"references":        "x$A${B}",
+		"tab":               "a\tb",
+		"backslashes":       `C:\dir\n`,
+		"double quotes":     `say "hi" now`,
+		"newlines":          "line1\nline2",
+		"ending in a quote": `"quoted"`,
+		"ending in a slash": `C:\dir\`,
+	}
+
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			targetFile := filepath.Join(t.TempDir(), ".env")
+			assert.NoError(t, os.WriteFile(targetFile, []byte("A=1\nB=2\n"), 0o644))
+
+			orgStdin := input.Stdin
+			input.Stdin = strings.NewReader(value)
+			defer func() { input.Stdin = orgStdin }()
+
+			cmd, err := NewAddCmd(&AddOptions{Key: "PW", FilePath: targetFile, Source: input.ValueSource{Stdin: true}})
+			assert.NoError(t, err)
+			assert.NoError(t, cmd.Exec())
+
+			data, err := os.ReadFile(targetFile)
+			assert.NoError(t, err)
+			env, err := godotenv.UnmarshalBytes(data)
+			assert.NoError(t, err)
+			assert.Equal(t, value, env["PW"])
+		})
+	}
+}
+
+func TestExec_GenerateIfMissing(t *testing.T) {
+	tmpDir := t.TempDir()
+	targetFile := filepath.Join(tmpDir, "new.env")
+
+	options := &AddOptions{
+		Key:       "SESSION_SECRET",
+		FilePath:  targetFile,
+		Create:    true,
+		Source:    input.ValueSource{Generate: true, Charset: "hex", Length: 16},
+		IfMissing: true,
+	}
+
+	cmd, err := NewAddCmd(options)
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+
+	data, err := os.ReadFile(targetFile)
+	assert.NoError(t, err)
+	assert.Regexp(t, `^SESSION_SECRET="[0-9a-f]{16}"$`, string(data))
+
+	// A second run must leave the existing secret untouched.
+	cmd, err = NewAddCmd(options)
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+
+	again, err := os.ReadFile(targetFile)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), string(again))
+}
+
+func TestExec_SecretEncryptsValue(t *testing.T) {
+	tmpDir := t.TempDir()
+	keyFile := filepath.Join(tmpDir, "team.key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("0123456789abcdef"), 0600))
+	targetFile := filepath.Join(tmpDir, ".env")
+	assert.NoError(t, os.WriteFile(targetFile, []byte("PLAIN=\"x\"\n"), 0644))
+
+	options := &AddOptions{
+		Key:      "API_KEY",
+		Value:    "s3cr3t",
+		FilePath: targetFile,
+		Secret:   true,
+		Keys:     crypt.KeyOptions{KeyFile: keyFile},
+	}
+	cmd, err := NewAddCmd(options)
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+
+	data, err := os.ReadFile(targetFile)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "PLAIN=\"x\"\nAPI_KEY=\"enc:v1:keyfile:"))
+	assert.NotContains(t, string(data), "s3cr3t")
+}
+
+func TestExec_SecretRejectsOtherKey(t *testing.T) {
+	tmpDir := t.TempDir()
+	keyFile := filepath.Join(tmpDir, "typo.key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("fedcba9876543210"), 0600))
+	encrypted, err := crypt.NewValueCipher(crypt.Keys{KeyFile: []byte("0123456789abcdef")}).Encrypt("DB_PASSWORD", "hunter2")
+	assert.NoError(t, err)
+	targetFile := filepath.Join(tmpDir, ".env")
+	content := "DB_PASSWORD=\"" + encrypted + "\"\n"
+	assert.NoError(t, os.WriteFile(targetFile, []byte(content), 0644))
+
+	options := &AddOptions{
+		Key:      "API_KEY",
+		Value:    "s3cr3t",
+		FilePath: targetFile,
+		Secret:   true,
+		Keys:     crypt.KeyOptions{KeyFile: keyFile},
+	}
+	cmd, err := NewAddCmd(options)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, cmd.Exec(), crypt.ErrKeyMismatch)
+
+	data, err := os.ReadFile(targetFile)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(data))
+}
+
 func Test_duplicateKey(t *testing.T) {
 	tests := map[string]struct {
 		orgLines []string
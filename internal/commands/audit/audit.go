@@ -0,0 +1,215 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ba58ajbse/envcraft/internal/auditlog"
+)
+
+// Actions of the audit command.
+const (
+	ActionShow   = "show"
+	ActionVerify = "verify"
+)
+
+// dateLayout is the layout of dates without a time, which cover the whole day.
+const dateLayout = "2006-01-02"
+
+// AuditOptions holds the options for reading the audit log.
+type AuditOptions struct {
+	Action  string
+	LogPath string
+	Key     string
+	File    string
+	Since   time.Time
+	Until   time.Time
+	JSON    bool
+}
+
+// AuditCmd represents the command for showing and verifying the audit log of changes.
+type AuditCmd struct {
+	Options AuditOptions
+	stdout  io.Writer
+}
+
+func Run(args []string) error {
+	options, err := ParseAuditOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewAuditCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewAuditCmd creates a new AuditCmd instance with the specified options.
+func NewAuditCmd(options *AuditOptions) (*AuditCmd, error) {
+	switch options.Action {
+	case ActionShow, ActionVerify:
+	default:
+		return nil, fmt.Errorf("unknown action %q (expected show or verify)", options.Action)
+	}
+	if options.LogPath == "" {
+		return nil, fmt.Errorf("audit log path is required (--log or %s)", auditlog.EnvLog)
+	}
+
+	return &AuditCmd{
+		Options: *options,
+		stdout:  os.Stdout,
+	}, nil
+}
+
+// Exec executes the audit command: show prints the matching entries and warns if
+// the hash chain is broken, verify only checks the chain.
+func (c *AuditCmd) Exec() error {
+	entries, err := auditlog.Read(c.Options.LogPath)
+	if err != nil && !errors.Is(err, auditlog.ErrTampered) {
+		return err
+	}
+	chainErr := err
+
+	if c.Options.Action == ActionVerify {
+		if chainErr != nil {
+			return chainErr
+		}
+		fmt.Fprintf(c.stdout, "%d entries, hash chain intact\n", len(entries))
+		return nil
+	}
+
+	if chainErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", chainErr)
+	}
+	for _, entry := range entries {
+		if !c.matches(entry) {
+			continue
+		}
+		if err := c.print(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches reports whether the entry passes the key, file and date filters.
+func (c *AuditCmd) matches(entry auditlog.Entry) bool {
+	if !c.Options.Since.IsZero() && entry.Time.Before(c.Options.Since) {
+		return false
+	}
+	if !c.Options.Until.IsZero() && entry.Time.After(c.Options.Until) {
+		return false
+	}
+	if c.Options.File != "" && !matchFile(c.Options.File, entry.File) {
+		return false
+	}
+	if c.Options.Key != "" {
+		for _, change := range entry.Changes {
+			if matched, _ := path.Match(c.Options.Key, change.Key); matched {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// matchFile reports whether the file of an entry, always absolute, is the given file.
+func matchFile(file, entryFile string) bool {
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	return filepath.Clean(file) == filepath.Clean(entryFile)
+}
+
+// print writes the entry as a JSON line or as a header followed by the changed keys.
+func (c *AuditCmd) print(entry auditlog.Entry) error {
+	if c.Options.JSON {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("error encoding audit entry: %w", err)
+		}
+		fmt.Fprintln(c.stdout, string(line))
+		return nil
+	}
+
+	fmt.Fprintf(c.stdout, "%s  %s@%s  %s  %s\n", entry.Time.Local().Format("2006-01-02 15:04:05"), entry.User, entry.Host, entry.Command, entry.File)
+	for _, change := range entry.Changes {
+		fmt.Fprintf(c.stdout, "  %s %s\n", change.Kind, change.Key)
+	}
+	return nil
+}
+
+// parseTime parses a date or an RFC 3339 time. A date used as an upper bound
+// covers the whole day.
+func parseTime(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(dateLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD or RFC 3339)", value)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+// ParseAuditOptions parses command-line arguments and returns an AuditOptions struct.
+func ParseAuditOptions(opts []string) (*AuditOptions, error) {
+	if len(opts) == 0 || strings.HasPrefix(opts[0], "-") {
+		return nil, errors.New("action is required (show or verify)")
+	}
+	action := opts[0]
+
+	flagSet := flag.NewFlagSet("audit "+action, flag.ContinueOnError)
+	logPath := flagSet.String("log", os.Getenv(auditlog.EnvLog), "Path to the audit log (default $"+auditlog.EnvLog+")")
+	key := flagSet.String("key", "", "Only show entries changing keys matching this pattern")
+	file := flagSet.String("f", "", "Only show entries for this file")
+	since := flagSet.String("since", "", "Only show entries from this date or time")
+	until := flagSet.String("until", "", "Only show entries up to this date (inclusive) or time")
+	jsonOutput := flagSet.Bool("json", false, "Print entries as JSON lines")
+
+	if err := flagSet.Parse(opts[1:]); err != nil {
+		return nil, err
+	}
+	if len(flagSet.Args()) > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flagSet.Args(), " "))
+	}
+
+	sinceTime, err := parseTime(*since, false)
+	if err != nil {
+		return nil, err
+	}
+	untilTime, err := parseTime(*until, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuditOptions{
+		Action:  action,
+		LogPath: *logPath,
+		Key:     *key,
+		File:    *file,
+		Since:   sinceTime,
+		Until:   untilTime,
+		JSON:    *jsonOutput,
+	}, nil
+}
@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ba58ajbse/envcraft/internal/auditlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAuditOptions(t *testing.T) {
+	t.Setenv(auditlog.EnvLog, "/var/log/envcraft.log")
+	tests := map[string]struct {
+		opts    []string
+		want    *AuditOptions
+		wantErr bool
+	}{
+		"show with log from environment": {
+			opts:    []string{"show"},
+			want:    &AuditOptions{Action: ActionShow, LogPath: "/var/log/envcraft.log"},
+			wantErr: false,
+		},
+		"show with filters": {
+			opts: []string{"show", "--log", "audit.log", "--key", "DB_*", "-f", ".env", "--since", "2026-01-01T00:00:00Z", "--until", "2026-01-31T12:00:00Z", "--json"},
+			want: &AuditOptions{
+				Action:  ActionShow,
+				LogPath: "audit.log",
+				Key:     "DB_*",
+				File:    ".env",
+				Since:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+				Until:   time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC),
+				JSON:    true,
+			},
+			wantErr: false,
+		},
+		"missing action": {
+			opts:    []string{"--key", "A"},
+			want:    nil,
+			wantErr: true,
+		},
+		"invalid date": {
+			opts:    []string{"show", "--since", "yesterday"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseAuditOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseTimeDateCoversWholeDay(t *testing.T) {
+	until, err := parseTime("2026-01-31", true)
+	require.NoError(t, err)
+	assert.True(t, time.Date(2026, 1, 31, 23, 59, 0, 0, time.Local).Before(until))
+	assert.True(t, time.Date(2026, 2, 1, 0, 0, 0, 0, time.Local).After(until))
+}
+
+func TestAuditExec(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "audit.log")
+	envPath := filepath.Join(dir, ".env")
+	otherPath := filepath.Join(dir, ".env.production")
+	require.NoError(t, auditlog.Append(logPath, auditlog.NewEntry("add", envPath, []byte(""), []byte("DB_HOST=db\n"), []byte("secret"))))
+	require.NoError(t, auditlog.Append(logPath, auditlog.NewEntry("update", otherPath, []byte("API_TOKEN=a\n"), []byte("API_TOKEN=b\n"), []byte("secret"))))
+
+	tests := map[string]struct {
+		options AuditOptions
+		want    string
+	}{
+		"show by key": {
+			options: AuditOptions{Action: ActionShow, Key: "DB_*"},
+			want:    "  + DB_HOST\n",
+		},
+		"show by file": {
+			options: AuditOptions{Action: ActionShow, File: otherPath},
+			want:    "  ~ API_TOKEN\n",
+		},
+		"show nothing after until": {
+			options: AuditOptions{Action: ActionShow, Until: time.Now().Add(-time.Hour)},
+			want:    "",
+		},
+		"verify": {
+			options: AuditOptions{Action: ActionVerify},
+			want:    "2 entries, hash chain intact\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.options.LogPath = logPath
+			cmd, err := NewAuditCmd(&tt.options)
+			require.NoError(t, err)
+			var out bytes.Buffer
+			cmd.stdout = &out
+
+			require.NoError(t, cmd.Exec())
+			if tt.want == "" {
+				assert.Empty(t, out.String())
+			} else {
+				assert.Contains(t, out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package batch
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envdiff"
+	"github.com/ba58ajbse/envcraft/internal/envedit"
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+	"github.com/joho/godotenv"
+)
+
+// ErrInvalid is returned when an operation of the batch cannot be applied; no file is changed then.
+var ErrInvalid = errors.New("batch rejected, no file was changed")
+
+// BatchOptions holds the options for applying a batch of operations to env files.
+type BatchOptions struct {
+	Input    string
+	FilePath string
+	Yes      bool
+	DryRun   bool
+	Keys     crypt.KeyOptions
+}
+
+// BatchCmd represents the command for applying a script of operations to one or more
+// env files together: every file is updated or none is.
+type BatchCmd struct {
+	Options BatchOptions
+	confirm func(prompt string) (bool, error)
+	editor  *envedit.Editor
+}
+
+// fileChange is the content of a file before and after the operations of the batch.
+type fileChange struct {
+	path     string
+	perm     os.FileMode
+	orgLines []string
+	newLines []string
+}
+
+func Run(args []string) error {
+	options, err := ParseBatchOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewBatchCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewBatchCmd creates a new BatchCmd instance with the specified options.
+func NewBatchCmd(options *BatchOptions) (*BatchCmd, error) {
+	if options.Input == "" {
+		return nil, errors.New("operations file is required (- for stdin)")
+	}
+	if options.Input == "-" && !options.Yes && !options.DryRun {
+		return nil, errors.New("--yes or --dry-run is required when reading the operations from stdin")
+	}
+
+	cmd := &BatchCmd{
+		Options: *options,
+		confirm: func(prompt string) (bool, error) { return input.Confirm(prompt, false) },
+	}
+	cmd.editor = &envedit.Editor{LoadKeys: func() (crypt.Keys, error) { return cmd.Options.Keys.Load(true) }}
+	return cmd, nil
+}
+
+// Exec executes the batch command: applies every operation in memory, rejects the whole
+// batch if any of them fails, shows the changed keys of every file without their values,
+// and writes all files once confirmed.
+func (c *BatchCmd) Exec() error {
+	ops, err := c.readOps()
+	if err != nil {
+		return err
+	}
+
+	changes, err := c.makeChanges(ops)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Println("Nothing to change.")
+		return nil
+	}
+	for _, change := range changes {
+		printChange(change)
+	}
+
+	if c.Options.DryRun {
+		return nil
+	}
+	if !c.Options.Yes {
+		ok, err := c.confirm(fmt.Sprintf("Apply these changes to %d file(s)?", len(changes)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Batch cancelled.")
+			return nil
+		}
+	}
+
+	return apply(changes)
+}
+
+// readOps reads and parses the operations file, or stdin for -.
+func (c *BatchCmd) readOps() ([]Op, error) {
+	if c.Options.Input == "-" {
+		return ParseOps(input.Stdin, c.Options.FilePath)
+	}
+	file, err := os.Open(c.Options.Input)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", c.Options.Input, err)
+	}
+	defer file.Close()
+	ops, err := ParseOps(file, c.Options.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.Options.Input, err)
+	}
+	return ops, nil
+}
+
+// makeChanges applies the operations to the files in memory, in order, and returns the
+// files that changed. Every failing operation is reported, wrapped in ErrInvalid.
+func (c *BatchCmd) makeChanges(ops []Op) ([]*fileChange, error) {
+	files := map[string]*fileChange{}
+	order := []*fileChange{}
+	problems := []string{}
+	for _, op := range ops {
+		// Operations on .env and ./.env edit the same file.
+		id := filepath.Clean(op.File)
+		if abs, err := filepath.Abs(op.File); err == nil {
+			id = abs
+		}
+		change, ok := files[id]
+		if !ok {
+			var err error
+			change, err = readFile(op.File)
+			if err != nil {
+				problems = append(problems, err.Error())
+				change = nil
+			} else {
+				order = append(order, change)
+			}
+			files[id] = change
+		}
+		if change == nil {
+			continue
+		}
+		newLines, err := c.applyOp(change.newLines, op)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: line %d: %s: %v", op.File, op.Line, op, err))
+			continue
+		}
+		change.newLines = newLines
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w:\n  %s", ErrInvalid, strings.Join(problems, "\n  "))
+	}
+
+	changed := []*fileChange{}
+	for _, change := range order {
+		if !slices.Equal(change.orgLines, change.newLines) {
+			changed = append(changed, change)
+		}
+	}
+	return changed, nil
+}
+
+// readFile reads the lines and permissions of a file the batch operates on.
+func readFile(filePath string) (*fileChange, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", filePath, err)
+	}
+	orgLines, err := fs.ReadLines(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", filePath, err)
+	}
+	return &fileChange{
+		path:     filePath,
+		perm:     info.Mode().Perm(),
+		orgLines: orgLines,
+		newLines: slices.Clone(orgLines),
+	}, nil
+}
+
+// applyOp returns the lines of a file after the operation.
+func (c *BatchCmd) applyOp(fileLines []string, op Op) ([]string, error) {
+	indexes := lines.KeyIndexes(fileLines, op.Key)
+	if op.Name != OpSet && len(indexes) == 0 {
+		return nil, errors.New("key not found")
+	}
+
+	switch op.Name {
+	case OpSet:
+		return c.editor.Set(fileLines, op.Key, op.Value)
+	case OpDelete:
+		return envedit.Unset(fileLines, op.Key), nil
+	case OpRename:
+		return c.editor.Rename(fileLines, op.Key, op.Value)
+	case OpComment:
+		return slices.Insert(slices.Clone(fileLines), indexes[0], "# "+op.Value+"\n"), nil
+	case OpDisable:
+		newLines := slices.Clone(fileLines)
+		for _, i := range indexes {
+			newLines[i] = "# " + fileLines[i]
+		}
+		return newLines, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", op.Name)
+}
+
+// printChange prints the keys added, removed or changed in a file, without their values.
+func printChange(change *fileChange) {
+	fmt.Printf("Changes to %s:\n", change.path)
+	old, oldErr := godotenv.Unmarshal(strings.Join(change.orgLines, ""))
+	new, newErr := godotenv.Unmarshal(strings.Join(change.newLines, ""))
+	diff := []envdiff.Change{}
+	if oldErr == nil && newErr == nil {
+		diff = envdiff.Compare(old, new)
+	}
+	if len(diff) == 0 {
+		fmt.Println("  (comments only)")
+		return
+	}
+	envdiff.PrintMasked(os.Stdout, diff)
+}
+
+// apply stages every file and then replaces them. If replacing one fails, the files
+// already replaced are restored so that the batch is applied to all files or none.
+func apply(changes []*fileChange) error {
+	staged := []*fs.StagedFile{}
+	discard := func() {
+		for _, s := range staged {
+			s.Discard()
+		}
+	}
+	for _, change := range changes {
+		s, err := fs.StageFile(change.path, []byte(strings.Join(change.newLines, "")), change.perm)
+		if err != nil {
+			discard()
+			return err
+		}
+		staged = append(staged, s)
+	}
+
+	for i, s := range staged {
+		if err := s.Commit(); err != nil {
+			for _, s := range staged[i+1:] {
+				s.Discard()
+			}
+			restoreErrs := []error{err}
+			for _, change := range changes[:i] {
+				if err := fs.WriteFileAtomic(change.path, []byte(strings.Join(change.orgLines, "")), change.perm); err != nil {
+					restoreErrs = append(restoreErrs, fmt.Errorf("error restoring %s: %w", change.path, err))
+				}
+			}
+			return errors.Join(restoreErrs...)
+		}
+	}
+	return nil
+}
+
+// ParseBatchOptions parses command-line arguments and returns a BatchOptions struct.
+func ParseBatchOptions(opts []string) (*BatchOptions, error) {
+	flagSet := flag.NewFlagSet("apply-batch", flag.ContinueOnError)
+	file := flagSet.String("f", "", "Path to the .env file operations apply to until a file line selects another")
+	yes := flagSet.Bool("yes", false, "Apply without asking for confirmation")
+	dryRun := flagSet.Bool("dry-run", false, "Show the changes without applying them")
+
+	var keys crypt.KeyOptions
+	keys.AddFlags(flagSet)
+
+	args := []string{}
+	if len(opts) > 0 && (opts[0] == "-" || !strings.HasPrefix(opts[0], "-")) {
+		args = append(args, opts[0])
+		opts = opts[1:]
+	}
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	args = append(args, flagSet.Args()...)
+
+	inputPath := "-"
+	switch len(args) {
+	case 0:
+	case 1:
+		inputPath = args[0]
+	default:
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(args[1:], " "))
+	}
+
+	return &BatchOptions{
+		Input:    inputPath,
+		FilePath: *file,
+		Yes:      *yes,
+		DryRun:   *dryRun,
+		Keys:     keys,
+	}, nil
+}
@@ -0,0 +1,220 @@
+package batch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBatchOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *BatchOptions
+		wantErr bool
+	}{
+		"stdin by default": {
+			opts:    []string{"--yes"},
+			want:    &BatchOptions{Input: "-", Yes: true},
+			wantErr: false,
+		},
+		"operations file with default file": {
+			opts:    []string{"ops.txt", "-f", ".env", "--dry-run", "--key-file", "team.key"},
+			want:    &BatchOptions{Input: "ops.txt", FilePath: ".env", DryRun: true, Keys: crypt.KeyOptions{KeyFile: "team.key"}},
+			wantErr: false,
+		},
+		"explicit stdin": {
+			opts:    []string{"-", "--yes"},
+			want:    &BatchOptions{Input: "-", Yes: true},
+			wantErr: false,
+		},
+		"too many arguments": {
+			opts:    []string{"ops.txt", "more.txt"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseBatchOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseOps(t *testing.T) {
+	tests := map[string]struct {
+		script  string
+		want    []Op
+		wantErr bool
+	}{
+		"operations across files": {
+			script: "# setup\nset A \"a b\"\n\nfile .env.prod\nrename OLD NEW\ncomment A the A key\ndisable B\ndelete C\nset D 'd'\n",
+			want: []Op{
+				{Line: 2, File: ".env", Name: OpSet, Key: "A", Value: "a b"},
+				{Line: 5, File: ".env.prod", Name: OpRename, Key: "OLD", Value: "NEW"},
+				{Line: 6, File: ".env.prod", Name: OpComment, Key: "A", Value: "the A key"},
+				{Line: 7, File: ".env.prod", Name: OpDisable, Key: "B"},
+				{Line: 8, File: ".env.prod", Name: OpDelete, Key: "C"},
+				{Line: 9, File: ".env.prod", Name: OpSet, Key: "D", Value: "d"},
+			},
+			wantErr: false,
+		},
+		"unknown operation": {
+			script:  "unset A\n",
+			wantErr: true,
+		},
+		"invalid key": {
+			script:  "set 1A x\n",
+			wantErr: true,
+		},
+		"rename without new key": {
+			script:  "rename A\n",
+			wantErr: true,
+		},
+		"delete with extra arguments": {
+			script:  "delete A B\n",
+			wantErr: true,
+		},
+		"unterminated quote": {
+			script:  "set A \"x\n",
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseOps(strings.NewReader(tt.script), ".env")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseOpsRequiresFile(t *testing.T) {
+	_, err := ParseOps(strings.NewReader("set A 1\n"), "")
+	assert.Error(t, err)
+}
+
+func TestExec(t *testing.T) {
+	tests := map[string]struct {
+		script   string
+		wantEnv  string
+		wantProd string
+		wantErr  error
+	}{
+		"applies every operation": {
+			script:   "set C \"hello world\"\nset A 2\nrename OLD NEW\ncomment A the A key\ndisable B\nfile .env.prod\ndelete X\nset Y y\n",
+			wantEnv:  "# the A key\nA=\"2\"\n# B=2\nNEW=x\nC=\"hello world\"",
+			wantProd: "Y=\"y\"",
+		},
+		"one failing operation rejects the batch": {
+			script:   "set C 3\nfile .env.prod\ndelete MISSING\n",
+			wantEnv:  "A=1\nB=2\nOLD=x\n",
+			wantProd: "X=1\n",
+			wantErr:  ErrInvalid,
+		},
+		"one file under two paths": {
+			script:   "set A 2\nfile DIR/./.env\nset B 3\n",
+			wantEnv:  "A=\"2\"\nB=\"3\"\nOLD=x\n",
+			wantProd: "X=1\n",
+		},
+		"rename to an existing key": {
+			script:   "rename OLD A\n",
+			wantEnv:  "A=1\nB=2\nOLD=x\n",
+			wantProd: "X=1\n",
+			wantErr:  ErrInvalid,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			envFile := filepath.Join(dir, ".env")
+			prodFile := filepath.Join(dir, ".env.prod")
+			require.NoError(t, os.WriteFile(envFile, []byte("A=1\nB=2\nOLD=x\n"), 0o640))
+			require.NoError(t, os.WriteFile(prodFile, []byte("X=1\n"), 0o600))
+			script := strings.ReplaceAll(tt.script, "file .env.prod", "file "+prodFile)
+			script = strings.ReplaceAll(script, "DIR/", dir+"/")
+
+			orgStdin := input.Stdin
+			input.Stdin = strings.NewReader(script)
+			t.Cleanup(func() { input.Stdin = orgStdin })
+
+			cmd, err := NewBatchCmd(&BatchOptions{Input: "-", FilePath: envFile, Yes: true})
+			require.NoError(t, err)
+			err = cmd.Exec()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			got, _ := os.ReadFile(envFile)
+			assert.Equal(t, tt.wantEnv, string(got))
+			got, _ = os.ReadFile(prodFile)
+			assert.Equal(t, tt.wantProd, string(got))
+			info, _ := os.Stat(envFile)
+			assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+		})
+	}
+}
+
+func TestExecConfirmDeclined(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	opsFile := filepath.Join(dir, "ops.txt")
+	require.NoError(t, os.WriteFile(envFile, []byte("A=1\n"), 0o600))
+	require.NoError(t, os.WriteFile(opsFile, []byte("delete A\n"), 0o600))
+
+	cmd, err := NewBatchCmd(&BatchOptions{Input: opsFile, FilePath: envFile})
+	require.NoError(t, err)
+	cmd.confirm = func(string) (bool, error) { return false, nil }
+	require.NoError(t, cmd.Exec())
+
+	got, _ := os.ReadFile(envFile)
+	assert.Equal(t, "A=1\n", string(got))
+}
+
+func TestExecRenameEncryptedValue(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "team.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("0123456789abcdef"), 0o600))
+	keys, err := crypt.KeyOptions{KeyFile: keyFile}.Load(false)
+	require.NoError(t, err)
+	cipher := crypt.NewValueCipher(keys)
+	encrypted, err := cipher.Encrypt("OLD_TOKEN", "s3cr3t")
+	require.NoError(t, err)
+
+	envFile := filepath.Join(dir, ".env")
+	opsFile := filepath.Join(dir, "ops.txt")
+	require.NoError(t, os.WriteFile(envFile, []byte("OLD_TOKEN=\""+encrypted+"\"\n"), 0o600))
+	require.NoError(t, os.WriteFile(opsFile, []byte("rename OLD_TOKEN API_TOKEN\n"), 0o600))
+
+	cmd, err := NewBatchCmd(&BatchOptions{Input: opsFile, FilePath: envFile, Yes: true, Keys: crypt.KeyOptions{KeyFile: keyFile}})
+	require.NoError(t, err)
+	require.NoError(t, cmd.Exec())
+
+	data, err := os.ReadFile(envFile)
+	require.NoError(t, err)
+	key, value, _ := strings.Cut(strings.TrimSpace(string(data)), "=")
+	assert.Equal(t, "API_TOKEN", key)
+	plaintext, err := cipher.Decrypt("API_TOKEN", strings.Trim(value, `"`))
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", plaintext)
+}
@@ -0,0 +1,137 @@
+package batch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Operations of a batch script.
+const (
+	OpFile    = "file"
+	OpSet     = "set"
+	OpDelete  = "delete"
+	OpRename  = "rename"
+	OpComment = "comment"
+	OpDisable = "disable"
+)
+
+// validKey matches the keys a batch may set or rename to.
+var validKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Op is an operation of a batch script on the file selected by the last file line.
+type Op struct {
+	Line  int
+	File  string
+	Name  string
+	Key   string
+	Value string // New value for set, new key for rename, comment text for comment.
+}
+
+// String returns the operation as written in the script, without the value of set.
+func (o Op) String() string {
+	switch o.Name {
+	case OpRename:
+		return fmt.Sprintf("%s %s %s", o.Name, o.Key, o.Value)
+	default:
+		return fmt.Sprintf("%s %s", o.Name, o.Key)
+	}
+}
+
+// ParseOps reads a batch script: one operation per line, blank lines and lines
+// starting with # ignored. Operations apply to defaultFile until a file line
+// selects another one:
+//
+//	file .env.production
+//	set KEY value            (the value may be double or single quoted)
+//	delete KEY
+//	rename OLD NEW
+//	comment KEY text         (adds "# text" above KEY)
+//	disable KEY              (comments KEY out)
+func ParseOps(r io.Reader, defaultFile string) ([]Op, error) {
+	ops := []Op{}
+	file := defaultFile
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+
+		if name == OpFile {
+			if rest == "" {
+				return nil, fmt.Errorf("line %d: file requires a path", n)
+			}
+			file = rest
+			continue
+		}
+		if file == "" {
+			return nil, fmt.Errorf("line %d: no file selected (use -f or a file line)", n)
+		}
+
+		key, arg, _ := strings.Cut(rest, " ")
+		arg = strings.TrimSpace(arg)
+		if key == "" {
+			return nil, fmt.Errorf("line %d: %s requires a key", n, name)
+		}
+		op := Op{Line: n, File: file, Name: name, Key: key}
+		switch name {
+		case OpSet:
+			if !validKey.MatchString(key) {
+				return nil, fmt.Errorf("line %d: invalid key %q", n, key)
+			}
+			value, err := unquote(arg)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			op.Value = value
+		case OpRename:
+			if arg == "" || strings.Contains(arg, " ") {
+				return nil, fmt.Errorf("line %d: rename requires the old and new key", n)
+			}
+			if !validKey.MatchString(arg) {
+				return nil, fmt.Errorf("line %d: invalid key %q", n, arg)
+			}
+			op.Value = arg
+		case OpComment:
+			if arg == "" {
+				return nil, fmt.Errorf("line %d: comment requires a text", n)
+			}
+			op.Value = arg
+		case OpDelete, OpDisable:
+			if arg != "" {
+				return nil, fmt.Errorf("line %d: unexpected arguments: %s", n, arg)
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unknown operation %q (expected file, set, delete, rename, comment or disable)", n, name)
+		}
+		ops = append(ops, op)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading operations: %w", err)
+	}
+	return ops, nil
+}
+
+// unquote returns the value of set, removing double quotes (with escapes) or single quotes.
+func unquote(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted value %s", value)
+		}
+		return unquoted, nil
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("invalid quoted value %s", value)
+		}
+		return value[1 : len(value)-1], nil
+	}
+	return value, nil
+}
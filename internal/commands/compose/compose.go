@@ -0,0 +1,320 @@
+package compose
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+	"github.com/ba58ajbse/envcraft/internal/sensitive"
+	"gopkg.in/yaml.v3"
+)
+
+// Actions of the compose command.
+const (
+	ActionResolve = "resolve"
+	ActionCheck   = "check"
+	ActionAdd     = "add"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+)
+
+// ErrUndefined is returned by check when the compose file references variables that are not defined.
+var ErrUndefined = errors.New("undefined variables")
+
+// ComposeOptions holds the options for working with the variables of a compose file.
+type ComposeOptions struct {
+	Action      string
+	ComposeFile string
+	Service     string
+	Keys        []string
+	Value       string
+	ShowValues  bool
+	KeyOptions  crypt.KeyOptions
+}
+
+// ComposeCmd represents the command for resolving, checking and editing the variables of compose services.
+type ComposeCmd struct {
+	Options ComposeOptions
+	project *project
+}
+
+func Run(args []string) error {
+	options, err := ParseComposeOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewComposeCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewComposeCmd creates a new ComposeCmd instance with the specified options.
+func NewComposeCmd(options *ComposeOptions) (*ComposeCmd, error) {
+	if options.Action != ActionCheck && options.Service == "" {
+		return nil, errors.New("service is required")
+	}
+	if (options.Action == ActionAdd || options.Action == ActionUpdate) && len(options.Keys) != 1 {
+		return nil, errors.New("exactly one key is required")
+	}
+	if options.Action == ActionDelete && len(options.Keys) == 0 {
+		return nil, errors.New("at least one key is required")
+	}
+
+	return &ComposeCmd{
+		Options: *options,
+	}, nil
+}
+
+// Exec executes the compose command.
+func (c *ComposeCmd) Exec() error {
+	path, err := findComposeFile(c.Options.ComposeFile)
+	if err != nil {
+		return err
+	}
+	if c.project, err = loadProject(path); err != nil {
+		return err
+	}
+
+	switch c.Options.Action {
+	case ActionResolve:
+		return c.resolve()
+	case ActionCheck:
+		return c.check()
+	case ActionAdd, ActionUpdate, ActionDelete:
+		return c.edit()
+	default:
+		return fmt.Errorf("unknown action %q", c.Options.Action)
+	}
+}
+
+// resolved is the final value of a variable of a service and where it came from.
+type resolved struct {
+	value      string
+	source     string
+	overridden []string
+	secret     bool
+}
+
+// resolve prints the variables a service's container starts with, with the source of each.
+// Values of secret keys are masked unless --show-values is set.
+func (c *ComposeCmd) resolve() error {
+	s, err := c.project.service(c.Options.Service)
+	if err != nil {
+		return err
+	}
+	order, vars, err := c.variables(s)
+	if err != nil {
+		return err
+	}
+
+	if len(order) == 0 {
+		fmt.Printf("Service %s has no variables.\n", s.name)
+		return nil
+	}
+	for _, key := range order {
+		v := vars[key]
+		value := strconv.Quote(v.value)
+		if !c.Options.ShowValues && v.secret {
+			value = "********"
+		}
+		source := v.source
+		if len(v.overridden) > 0 {
+			source += ", overrides " + strings.Join(v.overridden, ", ")
+		}
+		fmt.Printf("%s=%s  # %s\n", key, value, source)
+	}
+	return nil
+}
+
+// variables returns the variables of a service in the order they are first defined: env_file
+// entries in order, then environment, each overriding what came before.
+func (c *ComposeCmd) variables(s *service) ([]string, map[string]*resolved, error) {
+	lookup, err := c.project.lookup()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	order := []string{}
+	vars := map[string]*resolved{}
+	set := func(key, value, source string, secret bool) {
+		secret = secret || sensitive.MatchesAny(key, sensitive.DefaultPatterns)
+		v, ok := vars[key]
+		if !ok {
+			order = append(order, key)
+			vars[key] = &resolved{value: value, source: source, secret: secret}
+			return
+		}
+		v.overridden = append(v.overridden, v.source)
+		v.value, v.source, v.secret = value, source, v.secret || secret
+	}
+
+	for _, ref := range s.envFiles {
+		path := ref.path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.project.dir(), path)
+		}
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) && !ref.required {
+			continue
+		}
+		env, err := envfile.Load(path, c.Options.KeyOptions)
+		if err != nil {
+			return nil, nil, fmt.Errorf("env_file %s of service %s: %w", ref.path, s.name, err)
+		}
+		secret := sensitive.Keys(env.Lines, sensitive.DefaultPatterns)
+		for _, key := range env.Keys {
+			set(key, env.Values[key], "env_file "+ref.path, secret[key])
+		}
+	}
+
+	for _, entry := range s.entries {
+		if entry.hasValue {
+			set(entry.key, interpolate(entry.value, lookup, func(reference) {}), "environment", false)
+		} else if value, ok := lookup(entry.key); ok {
+			set(entry.key, value, "environment, from the shell", false)
+		}
+	}
+	return order, vars, nil
+}
+
+// check reports variables referenced in the compose file that are neither set in the shell nor
+// in the project .env file, the two places compose interpolates from. References with a default
+// value are fine.
+func (c *ComposeCmd) check() error {
+	undefined, referenced, err := c.undefined()
+	if err != nil {
+		return err
+	}
+	if len(undefined) == 0 {
+		fmt.Printf("All %d referenced variable(s) are defined.\n", referenced)
+		return nil
+	}
+
+	names := make([]string, 0, len(undefined))
+	for name := range undefined {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		lines := make([]string, len(undefined[name]))
+		for i, line := range undefined[name] {
+			lines[i] = strconv.Itoa(line)
+		}
+		fmt.Printf("%s: %s is not defined (line %s)\n", c.project.path, name, strings.Join(lines, ", "))
+	}
+	return fmt.Errorf("%w: %s (set them in the shell or in %s)", ErrUndefined, strings.Join(names, ", "), filepath.Join(c.project.dir(), ".env"))
+}
+
+// undefined returns the lines referencing each undefined variable, and the number of variables referenced.
+func (c *ComposeCmd) undefined() (map[string][]int, int, error) {
+	lookup, err := c.project.lookup()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	undefined := map[string][]int{}
+	referenced := map[string]bool{}
+	walkValues(c.project.root, func(node *yaml.Node) {
+		interpolate(node.Value, lookup, func(ref reference) {
+			referenced[ref.name] = true
+			if _, ok := lookup(ref.name); !ok && !ref.hasDefault && !slices.Contains(undefined[ref.name], node.Line) {
+				undefined[ref.name] = append(undefined[ref.name], node.Line)
+			}
+		})
+	})
+	return undefined, len(referenced), nil
+}
+
+// walkValues calls fn for every scalar value below node. Keys are not interpolated by compose.
+func walkValues(node *yaml.Node, fn func(*yaml.Node)) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		fn(node)
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			walkValues(node.Content[i], fn)
+		}
+	case yaml.SequenceNode, yaml.DocumentNode:
+		for _, child := range node.Content {
+			walkValues(child, fn)
+		}
+	}
+}
+
+// ParseComposeOptions parses command-line arguments and returns a ComposeOptions struct.
+//
+//	envcraft compose resolve SERVICE
+//	envcraft compose check
+//	envcraft compose add SERVICE KEY VALUE
+//	envcraft compose update SERVICE KEY VALUE
+//	envcraft compose delete SERVICE KEY...
+func ParseComposeOptions(opts []string) (*ComposeOptions, error) {
+	flagSet := flag.NewFlagSet("compose", flag.ContinueOnError)
+	file := flagSet.String("c", "", "Path to the compose file (default "+strings.Join(DefaultFiles, ", ")+")")
+	showValues := flagSet.Bool("show-values", false, "Show the values of secret keys (resolve only)")
+
+	var keyOptions crypt.KeyOptions
+	keyOptions.AddFlags(flagSet)
+	keyOptions.AddRecipientFlags(flagSet)
+
+	usage := errors.New("usage: envcraft compose [resolve|check|add|update|delete] [SERVICE] [KEY [VALUE]] [flags]")
+	if len(opts) == 0 || strings.HasPrefix(opts[0], "-") {
+		return nil, usage
+	}
+	action := opts[0]
+	if !slices.Contains([]string{ActionResolve, ActionCheck, ActionAdd, ActionUpdate, ActionDelete}, action) {
+		return nil, fmt.Errorf("unknown action %q (expected resolve, check, add, update or delete)", action)
+	}
+	opts = opts[1:]
+
+	args := []string{}
+	for len(opts) > 0 && !strings.HasPrefix(opts[0], "-") {
+		args = append(args, opts[0])
+		opts = opts[1:]
+	}
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	args = append(args, flagSet.Args()...)
+
+	options := &ComposeOptions{
+		Action:      action,
+		ComposeFile: *file,
+		ShowValues:  *showValues,
+		KeyOptions:  keyOptions,
+	}
+	switch action {
+	case ActionCheck:
+		if len(args) != 0 {
+			return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+		}
+	case ActionResolve:
+		if len(args) != 1 {
+			return nil, usage
+		}
+		options.Service = args[0]
+	case ActionAdd, ActionUpdate:
+		if len(args) != 3 {
+			return nil, usage
+		}
+		options.Service, options.Keys, options.Value = args[0], []string{args[1]}, args[2]
+	case ActionDelete:
+		if len(args) < 2 {
+			return nil, usage
+		}
+		options.Service, options.Keys = args[0], args[1:]
+	}
+	return options, nil
+}
@@ -0,0 +1,225 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCompose = `services:
+  web:
+    image: "nginx:${NGINX_TAG:-latest}"
+    env_file:
+      - .env.web
+      - path: .env.local
+        required: false
+    environment:
+      APP_ENV: production
+      API_URL: "https://${API_HOST}/v1"
+      FROM_SHELL:
+    ports:
+      - "${WEB_PORT}:80"
+  worker:
+    image: worker
+    environment:
+    - QUEUE=jobs
+  db:
+    image: postgres # no environment yet
+    volumes:
+      - data:/var/lib/postgresql/data
+`
+
+func writeProject(t *testing.T) string {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "compose.yaml"), []byte(testCompose), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".env.web"), []byte("APP_ENV=development\nDB_PASSWORD=secret\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("API_HOST=api.example.com\n"), 0644))
+	return filepath.Join(dir, "compose.yaml")
+}
+
+func newCmd(t *testing.T, options *ComposeOptions) *ComposeCmd {
+	cmd, err := NewComposeCmd(options)
+	assert.NoError(t, err)
+	cmd.project, err = loadProject(options.ComposeFile)
+	assert.NoError(t, err)
+	return cmd
+}
+
+func TestVariables(t *testing.T) {
+	path := writeProject(t)
+	t.Setenv("FROM_SHELL", "shell")
+	cmd := newCmd(t, &ComposeOptions{Action: ActionResolve, ComposeFile: path, Service: "web"})
+
+	s, err := cmd.project.service("web")
+	assert.NoError(t, err)
+	order, vars, err := cmd.variables(s)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"APP_ENV", "DB_PASSWORD", "API_URL", "FROM_SHELL"}, order)
+	assert.Equal(t, &resolved{value: "production", source: "environment", overridden: []string{"env_file .env.web"}}, vars["APP_ENV"])
+	assert.Equal(t, &resolved{value: "secret", source: "env_file .env.web", secret: true}, vars["DB_PASSWORD"])
+	assert.Equal(t, "https://api.example.com/v1", vars["API_URL"].value)
+	assert.Equal(t, "environment, from the shell", vars["FROM_SHELL"].source)
+}
+
+func TestUndefined(t *testing.T) {
+	path := writeProject(t)
+	cmd := newCmd(t, &ComposeOptions{Action: ActionCheck, ComposeFile: path})
+
+	undefined, referenced, err := cmd.undefined()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]int{"WEB_PORT": {13}}, undefined)
+	assert.Equal(t, 3, referenced)
+
+	t.Setenv("WEB_PORT", "8080")
+	undefined, _, err = cmd.undefined()
+	assert.NoError(t, err)
+	assert.Empty(t, undefined)
+}
+
+func TestInterpolate(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		value, ok := map[string]string{"SET": "value", "EMPTY": ""}[name]
+		return value, ok
+	}
+	tests := map[string]string{
+		"$SET and ${SET}":         "value and value",
+		"${UNSET:-default}":       "default",
+		"${EMPTY:-default}":       "default",
+		"${EMPTY-default}":        "",
+		"${SET:+alt}${UNSET+alt}": "alt",
+		"$$SET":                   "$SET",
+		"${UNSET:-${SET}}":        "value",
+	}
+	for input, want := range tests {
+		t.Run(input, func(t *testing.T) {
+			assert.Equal(t, want, interpolate(input, lookup, func(reference) {}))
+		})
+	}
+}
+
+func TestEdit(t *testing.T) {
+	tests := map[string]struct {
+		options *ComposeOptions
+		want    string
+		wantErr bool
+	}{
+		"add to map": {
+			options: &ComposeOptions{Action: ActionAdd, Service: "web", Keys: []string{"LOG_LEVEL"}, Value: "debug"},
+			want:    "      FROM_SHELL:\n      LOG_LEVEL: \"debug\"\n    ports:\n",
+		},
+		"add to list": {
+			options: &ComposeOptions{Action: ActionAdd, Service: "worker", Keys: []string{"WORKERS"}, Value: "4"},
+			want:    "    - QUEUE=jobs\n    - \"WORKERS=4\"\n  db:\n",
+		},
+		"add without environment": {
+			options: &ComposeOptions{Action: ActionAdd, Service: "db", Keys: []string{"POSTGRES_DB"}, Value: "app"},
+			want:    "  db:\n    environment:\n      POSTGRES_DB: \"app\"\n    image: postgres # no environment yet\n",
+		},
+		"add existing": {
+			options: &ComposeOptions{Action: ActionAdd, Service: "web", Keys: []string{"APP_ENV"}, Value: "x"},
+			wantErr: true,
+		},
+		"update": {
+			options: &ComposeOptions{Action: ActionUpdate, Service: "web", Keys: []string{"APP_ENV"}, Value: "staging"},
+			want:    "    environment:\n      APP_ENV: \"staging\"\n",
+		},
+		"update missing": {
+			options: &ComposeOptions{Action: ActionUpdate, Service: "web", Keys: []string{"NOPE"}, Value: "x"},
+			wantErr: true,
+		},
+		"delete last entry removes environment": {
+			options: &ComposeOptions{Action: ActionDelete, Service: "worker", Keys: []string{"QUEUE"}},
+			want:    "    image: worker\n  db:\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.options.ComposeFile = writeProject(t)
+			cmd := newCmd(t, tt.options)
+			err := cmd.edit()
+			content, _ := os.ReadFile(tt.options.ComposeFile)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, testCompose, string(content))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Contains(t, string(content), tt.want)
+
+			// The edited file still parses.
+			_, err = loadProject(tt.options.ComposeFile)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestEdit_ValueRoundTrip(t *testing.T) {
+	value := `pa$$w0rd${API_HOST}$x "quoted"`
+	lookup := func(name string) (string, bool) { return "interpolated", true }
+
+	for _, service := range []string{"web", "worker"} {
+		t.Run(service, func(t *testing.T) {
+			options := &ComposeOptions{Action: ActionAdd, Service: service, Keys: []string{"DB_PASSWORD"}, Value: value, ComposeFile: writeProject(t)}
+			assert.NoError(t, newCmd(t, options).edit())
+
+			project, err := loadProject(options.ComposeFile)
+			assert.NoError(t, err)
+			s, err := project.service(service)
+			assert.NoError(t, err)
+			idx := slices.IndexFunc(s.entries, func(e envEntry) bool { return e.key == "DB_PASSWORD" })
+			assert.NotEqual(t, -1, idx)
+			assert.Equal(t, value, interpolate(s.entries[idx].value, lookup, func(reference) {}))
+		})
+	}
+}
+
+func TestParseComposeOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *ComposeOptions
+		wantErr bool
+	}{
+		"resolve": {
+			opts:    []string{"resolve", "web", "-c", "compose.prod.yaml", "--show-values"},
+			want:    &ComposeOptions{Action: ActionResolve, ComposeFile: "compose.prod.yaml", Service: "web", ShowValues: true},
+			wantErr: false,
+		},
+		"check": {
+			opts:    []string{"check"},
+			want:    &ComposeOptions{Action: ActionCheck},
+			wantErr: false,
+		},
+		"add": {
+			opts:    []string{"add", "web", "KEY", "value"},
+			want:    &ComposeOptions{Action: ActionAdd, Service: "web", Keys: []string{"KEY"}, Value: "value"},
+			wantErr: false,
+		},
+		"delete several": {
+			opts:    []string{"delete", "web", "A", "B"},
+			want:    &ComposeOptions{Action: ActionDelete, Service: "web", Keys: []string{"A", "B"}},
+			wantErr: false,
+		},
+		"update without value": {
+			opts:    []string{"update", "web", "KEY"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseComposeOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package compose
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+	"gopkg.in/yaml.v3"
+)
+
+// entryPrefix matches the indentation of a map entry, and of the dash of a list item.
+var entryPrefix = regexp.MustCompile(`^\s*(-\s+)?`)
+
+var pastTense = map[string]string{ActionAdd: "added", ActionUpdate: "updated", ActionDelete: "deleted"}
+
+// edit adds, updates or deletes environment entries of a service. The compose file is edited
+// line by line, so its comments and formatting are kept.
+func (c *ComposeCmd) edit() error {
+	s, err := c.project.service(c.Options.Service)
+	if err != nil {
+		return err
+	}
+	if s.node.Kind != yaml.MappingNode || s.node.Style&yaml.FlowStyle != 0 {
+		return fmt.Errorf("service %s is not a block mapping; edit it by hand", s.name)
+	}
+	if s.envNode != nil && s.envNode.Style&yaml.FlowStyle != 0 {
+		return fmt.Errorf("environment of service %s uses flow style; edit it by hand", s.name)
+	}
+
+	orgLines, err := fs.ReadLines(c.project.path)
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", c.project.path, err)
+	}
+
+	var newLines []string
+	switch c.Options.Action {
+	case ActionAdd:
+		newLines, err = c.addEntry(s, orgLines)
+	case ActionUpdate:
+		newLines, err = c.updateEntry(s, orgLines)
+	case ActionDelete:
+		newLines, err = c.deleteEntries(s, orgLines)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := fs.WriteLines(c.project.path, lines.KeepTrailingNewline(orgLines, newLines)); err != nil {
+		return fmt.Errorf("error writing to file %s: %w", c.project.path, err)
+	}
+	fmt.Printf("%s: %s %s in the environment of service %s\n", c.project.path, pastTense[c.Options.Action], strings.Join(c.Options.Keys, ", "), s.name)
+	return nil
+}
+
+// addEntry appends KEY: "value" to the environment of the service, creating it if needed.
+// A list environment gets a - KEY=value item instead.
+func (c *ComposeCmd) addEntry(s *service, orgLines []string) ([]string, error) {
+	key := c.Options.Keys[0]
+	if slices.ContainsFunc(s.entries, func(e envEntry) bool { return e.key == key }) {
+		return nil, fmt.Errorf("%s is already set in the environment of service %s (use update)", key, s.name)
+	}
+	newLines := slices.Clone(orgLines)
+	ensureNewline(newLines)
+
+	if s.envKey == nil {
+		if len(s.node.Content) == 0 {
+			return nil, fmt.Errorf("service %s has no settings; add environment by hand", s.name)
+		}
+		indent := indentOf(orgLines[s.node.Content[0].Line-1])
+		block := []string{
+			indent + "environment:\n",
+			indent + "  " + key + ": " + quote(c.Options.Value) + "\n",
+		}
+		return slices.Insert(newLines, s.keyNode.Line, block...), nil
+	}
+
+	var line string
+	if len(s.entries) > 0 {
+		prefix := entryPrefix.FindString(orgLines[s.entries[0].node.Line-1])
+		line = formatEntry(s.envNode.Kind == yaml.SequenceNode, prefix, key, c.Options.Value)
+	} else {
+		line = formatEntry(false, indentOf(orgLines[s.envKey.Line-1])+"  ", key, c.Options.Value)
+	}
+	return slices.Insert(newLines, blockEnd(s, orgLines)+1, line), nil
+}
+
+// updateEntry replaces the value of an environment entry of the service.
+func (c *ComposeCmd) updateEntry(s *service, orgLines []string) ([]string, error) {
+	key := c.Options.Keys[0]
+	i := slices.IndexFunc(s.entries, func(e envEntry) bool { return e.key == key })
+	if i < 0 {
+		return nil, fmt.Errorf("%s is not set in the environment of service %s (use add)", key, s.name)
+	}
+	index, err := entryLine(s.entries[i])
+	if err != nil {
+		return nil, err
+	}
+
+	newLines := slices.Clone(orgLines)
+	prefix := entryPrefix.FindString(orgLines[index])
+	newLines[index] = formatEntry(s.envNode.Kind == yaml.SequenceNode, prefix, key, c.Options.Value)
+	return newLines, nil
+}
+
+// deleteEntries removes environment entries of the service, and the environment key once it is empty.
+func (c *ComposeCmd) deleteEntries(s *service, orgLines []string) ([]string, error) {
+	remove := []int{}
+	missing := []string{}
+	for _, key := range c.Options.Keys {
+		found := false
+		for _, entry := range s.entries {
+			if entry.key != key {
+				continue
+			}
+			index, err := entryLine(entry)
+			if err != nil {
+				return nil, err
+			}
+			remove = append(remove, index)
+			found = true
+		}
+		if !found {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("not set in the environment of service %s: %s", s.name, strings.Join(missing, ", "))
+	}
+	if len(remove) == len(s.entries) && s.envKey.Line != s.entries[0].node.Line {
+		remove = append(remove, s.envKey.Line-1)
+	}
+
+	newLines := slices.Clone(orgLines)
+	slices.Sort(remove)
+	for _, index := range slices.Backward(slices.Compact(remove)) {
+		newLines = slices.Delete(newLines, index, index+1)
+	}
+	return newLines, nil
+}
+
+// entryLine returns the index of the line holding entry, or an error if it spans several lines.
+func entryLine(entry envEntry) (int, error) {
+	node := entry.node
+	if entry.valueNode != nil {
+		node = entry.valueNode
+	}
+	if node.Kind != yaml.ScalarNode || node.Line != entry.node.Line || node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return 0, fmt.Errorf("environment entry %s spans several lines; edit it by hand", entry.key)
+	}
+	return entry.node.Line - 1, nil
+}
+
+// blockEnd returns the index of the last line of the environment block of the service.
+func blockEnd(s *service, orgLines []string) int {
+	start := s.envKey.Line - 1
+	keyIndent := len(indentOf(orgLines[start]))
+	end := start
+	for i := start + 1; i < len(orgLines); i++ {
+		trimmed := strings.TrimSpace(orgLines[i])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(indentOf(orgLines[i]))
+		// A list may start at the same indentation as its key.
+		if indent < keyIndent || (indent == keyIndent && !(s.envNode.Kind == yaml.SequenceNode && strings.HasPrefix(trimmed, "-"))) {
+			break
+		}
+		end = i
+	}
+	return end
+}
+
+// formatEntry returns an environment entry line: prefix KEY: "value", or prefix "KEY=value" for a list.
+func formatEntry(list bool, prefix, key, value string) string {
+	if list {
+		return prefix + quote(key+"="+value) + "\n"
+	}
+	return prefix + key + ": " + quote(value) + "\n"
+}
+
+// quote returns value as a YAML string that compose reads back unchanged: dollar signs are
+// doubled so that compose does not interpolate them.
+func quote(value string) string {
+	return strconv.Quote(strings.ReplaceAll(value, "$", "$$"))
+}
+
+// ensureNewline makes the last line end with a newline so lines can be appended after it.
+func ensureNewline(fileLines []string) {
+	if lines.EndsWithoutNewline(fileLines) {
+		fileLines[len(fileLines)-1] += "\n"
+	}
+}
+
+func indentOf(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
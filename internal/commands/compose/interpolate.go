@@ -0,0 +1,113 @@
+package compose
+
+import (
+	"strings"
+)
+
+// reference is a variable referenced in a compose file, such as ${VAR:-default}.
+type reference struct {
+	name       string
+	hasDefault bool
+}
+
+// interpolate replaces $VAR and ${VAR} with their values the way compose does: ${VAR:-default}
+// and ${VAR-default} fall back to default, ${VAR:+replacement} and ${VAR+replacement} use the
+// replacement when set, and $$ is a literal dollar sign. Every reference is passed to seen.
+func interpolate(s string, lookup func(string) (string, bool), seen func(reference)) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		next := s[i+1]
+		switch {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := closingBrace(s, i+2)
+			if end < 0 {
+				b.WriteString(s[i:])
+				return b.String()
+			}
+			b.WriteString(expand(s[i+2:end], lookup, seen))
+			i = end
+		case isNameStart(next):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			name := s[i+1 : j]
+			seen(reference{name: name})
+			value, _ := lookup(name)
+			b.WriteString(value)
+			i = j - 1
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String()
+}
+
+// expand returns the value of the body of a ${...} reference.
+func expand(body string, lookup func(string) (string, bool), seen func(reference)) string {
+	j := 0
+	for j < len(body) && isNameChar(body[j]) {
+		j++
+	}
+	name, rest := body[:j], body[j:]
+	value, set := lookup(name)
+
+	for _, op := range []string{":-", "-", ":+", "+", ":?", "?"} {
+		arg, found := strings.CutPrefix(rest, op)
+		if !found {
+			continue
+		}
+		seen(reference{name: name, hasDefault: op == ":-" || op == "-" || op == ":+" || op == "+"})
+		arg = interpolate(arg, lookup, seen)
+		nonEmpty := set && (value != "" || !strings.HasPrefix(op, ":"))
+		switch strings.TrimPrefix(op, ":") {
+		case "-":
+			if !nonEmpty {
+				return arg
+			}
+		case "+":
+			if nonEmpty {
+				return arg
+			}
+			return ""
+		}
+		return value
+	}
+
+	seen(reference{name: name})
+	return value
+}
+
+// closingBrace returns the index of the brace closing a reference opened before start,
+// allowing nested references in defaults, or -1.
+func closingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,196 @@
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFiles are the compose files looked up, in order, when none is given.
+var DefaultFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// project is a parsed compose file.
+type project struct {
+	path     string
+	root     *yaml.Node
+	services map[string]*service
+	order    []string
+}
+
+// service is a service of a compose file with its env_file and environment entries.
+type service struct {
+	name     string
+	keyNode  *yaml.Node
+	node     *yaml.Node
+	envFiles []envFileRef
+	envKey   *yaml.Node
+	envNode  *yaml.Node
+	entries  []envEntry
+}
+
+// envFileRef is an env_file entry. A file that is not required may be missing.
+type envFileRef struct {
+	path     string
+	required bool
+}
+
+// envEntry is an entry of an environment map or list. Without a value, compose passes the
+// variable on from the shell.
+type envEntry struct {
+	key       string
+	value     string
+	hasValue  bool
+	node      *yaml.Node // The key of a map entry or the item of a list.
+	valueNode *yaml.Node
+}
+
+// findComposeFile returns path if set, otherwise the first default compose file that exists.
+func findComposeFile(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	for _, name := range DefaultFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no compose file found (looked for %s)", strings.Join(DefaultFiles, ", "))
+}
+
+// loadProject parses the compose file at path.
+func loadProject(path string) (*project, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid compose file %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid compose file %s: expected a mapping", path)
+	}
+
+	p := &project{path: path, root: doc.Content[0], services: map[string]*service{}}
+	_, services := field(p.root, "services")
+	if services == nil || services.Kind != yaml.MappingNode {
+		return p, nil
+	}
+	for i := 0; i+1 < len(services.Content); i += 2 {
+		s, err := parseService(services.Content[i], services.Content[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		p.services[s.name] = s
+		p.order = append(p.order, s.name)
+	}
+	return p, nil
+}
+
+func parseService(keyNode, node *yaml.Node) (*service, error) {
+	s := &service{name: keyNode.Value, keyNode: keyNode, node: node}
+	if node.Kind != yaml.MappingNode {
+		return s, nil
+	}
+
+	_, envFile := field(node, "env_file")
+	if envFile != nil {
+		items := []*yaml.Node{envFile}
+		if envFile.Kind == yaml.SequenceNode {
+			items = envFile.Content
+		}
+		for _, item := range items {
+			switch item.Kind {
+			case yaml.ScalarNode:
+				s.envFiles = append(s.envFiles, envFileRef{path: item.Value, required: true})
+			case yaml.MappingNode:
+				_, path := field(item, "path")
+				_, required := field(item, "required")
+				if path == nil {
+					return nil, fmt.Errorf("service %s: env_file entry without path", s.name)
+				}
+				s.envFiles = append(s.envFiles, envFileRef{path: path.Value, required: required == nil || required.Value != "false"})
+			}
+		}
+	}
+
+	s.envKey, s.envNode = field(node, "environment")
+	if s.envNode == nil {
+		return s, nil
+	}
+	switch s.envNode.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(s.envNode.Content); i += 2 {
+			keyNode, valueNode := s.envNode.Content[i], s.envNode.Content[i+1]
+			entry := envEntry{key: keyNode.Value, node: keyNode, valueNode: valueNode}
+			if valueNode.Tag != "!!null" {
+				entry.value, entry.hasValue = valueNode.Value, true
+			}
+			s.entries = append(s.entries, entry)
+		}
+	case yaml.SequenceNode:
+		for _, item := range s.envNode.Content {
+			key, value, found := strings.Cut(item.Value, "=")
+			s.entries = append(s.entries, envEntry{key: key, value: value, hasValue: found, node: item})
+		}
+	case yaml.ScalarNode:
+		if s.envNode.Tag != "!!null" {
+			return nil, fmt.Errorf("service %s: environment must be a map or a list", s.name)
+		}
+	}
+	return s, nil
+}
+
+// service returns the service called name.
+func (p *project) service(name string) (*service, error) {
+	s, ok := p.services[name]
+	if !ok {
+		return nil, fmt.Errorf("service %s not found in %s (services: %s)", name, p.path, strings.Join(p.order, ", "))
+	}
+	return s, nil
+}
+
+// dir returns the directory env_file paths and the project .env are relative to.
+func (p *project) dir() string {
+	return filepath.Dir(p.path)
+}
+
+// lookup returns a variable as compose interpolates it: from the shell environment,
+// then from the .env file of the project directory.
+func (p *project) lookup() (func(string) (string, bool), error) {
+	dotenv := map[string]string{}
+	data, err := os.ReadFile(filepath.Join(p.dir(), ".env"))
+	if err == nil {
+		if dotenv, err = godotenv.UnmarshalBytes(data); err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", filepath.Join(p.dir(), ".env"), err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return func(name string) (string, bool) {
+		if value, ok := os.LookupEnv(name); ok {
+			return value, true
+		}
+		value, ok := dotenv[name]
+		return value, ok
+	}, nil
+}
+
+// field returns the key and value nodes of key in a mapping node, or nils.
+func field(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,128 @@
+package decrypt
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/fs"
+)
+
+// DecryptOptions holds the options for decrypting an env file.
+type DecryptOptions struct {
+	FilePath string
+	Output   string
+	Force    bool
+	Keys     crypt.KeyOptions
+}
+
+// DecryptCmd represents the command for decrypting a file written by the encrypt command.
+type DecryptCmd struct {
+	Options DecryptOptions
+}
+
+func Run(args []string) error {
+	options, err := ParseDecryptOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewDecryptCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewDecryptCmd creates a new DecryptCmd instance with the specified options.
+func NewDecryptCmd(options *DecryptOptions) (*DecryptCmd, error) {
+	if options.FilePath == "" {
+		return nil, errors.New("file path is required")
+	}
+	if options.Output == "" && !strings.HasSuffix(options.FilePath, ".enc") {
+		return nil, errors.New("output path is required when the file does not end in .enc")
+	}
+
+	return &DecryptCmd{
+		Options: *options,
+	}, nil
+}
+
+// Exec executes the decrypt command: reads the encrypted file and writes the plaintext
+// with owner-only permissions, or to stdout with -o -.
+func (c *DecryptCmd) Exec() error {
+	data, err := os.ReadFile(c.Options.FilePath)
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", c.Options.FilePath, err)
+	}
+	if _, err := os.Stat(c.output()); err == nil && c.output() != "-" && !c.Options.Force {
+		return fmt.Errorf("file %s already exists (use --force to overwrite)", c.output())
+	}
+
+	keys, err := c.Options.Keys.LoadFile(false)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := crypt.Decrypt(data, keys)
+	if err != nil {
+		return err
+	}
+
+	if c.output() == "-" {
+		_, err := os.Stdout.Write(plaintext)
+		return err
+	}
+	if err := fs.WriteFileAtomic(c.output(), plaintext, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("Decrypted %s to %s\n", c.Options.FilePath, c.output())
+
+	return nil
+}
+
+// output returns the path of the plaintext file, FILE without .enc unless set with -o.
+func (c *DecryptCmd) output() string {
+	if c.Options.Output != "" {
+		return c.Options.Output
+	}
+	return strings.TrimSuffix(c.Options.FilePath, ".enc")
+}
+
+// ParseDecryptOptions parses command-line arguments and returns a DecryptOptions struct.
+func ParseDecryptOptions(opts []string) (*DecryptOptions, error) {
+	flagSet := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	file := flagSet.String("f", "", "Path to the encrypted file")
+	output := flagSet.String("o", "", "Path to the plaintext file, or - for stdout (default FILE without .enc)")
+	force := flagSet.Bool("force", false, "Overwrite the plaintext file if it exists")
+
+	var keys crypt.KeyOptions
+	keys.AddFlags(flagSet)
+	keys.AddRecipientFlags(flagSet)
+
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	if len(flagSet.Args()) > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flagSet.Args(), " "))
+	}
+
+	if *file == "" {
+		fmt.Println("Error: -f flag is required")
+		flagSet.Usage()
+		return nil, errors.New("file path is required")
+	}
+
+	return &DecryptOptions{
+		FilePath: *file,
+		Output:   *output,
+		Force:    *force,
+		Keys:     keys,
+	}, nil
+}
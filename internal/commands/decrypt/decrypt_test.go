@@ -0,0 +1,113 @@
+package decrypt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec_DecryptsWithKeyFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	keyFile := filepath.Join(tmpDir, "team.key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("0123456789abcdef"), 0600))
+	encFile := filepath.Join(tmpDir, ".env.production.enc")
+	data, err := crypt.Encrypt([]byte("SECRET=\"s3cr3t\"\n"), crypt.Keys{KeyFile: []byte("0123456789abcdef")})
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(encFile, data, 0644))
+
+	cmd, err := NewDecryptCmd(&DecryptOptions{FilePath: encFile, Keys: crypt.KeyOptions{KeyFile: keyFile}})
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+
+	plaintext, err := os.ReadFile(filepath.Join(tmpDir, ".env.production"))
+	assert.NoError(t, err)
+	assert.Equal(t, "SECRET=\"s3cr3t\"\n", string(plaintext))
+
+	info, err := os.Stat(filepath.Join(tmpDir, ".env.production"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestExec_OverwritesOnlyWithForce(t *testing.T) {
+	tmpDir := t.TempDir()
+	keyFile := filepath.Join(tmpDir, "team.key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("0123456789abcdef"), 0600))
+	envFile := filepath.Join(tmpDir, ".env")
+	encFile := envFile + ".enc"
+	data, err := crypt.Encrypt([]byte("SECRET=\"s3cr3t\"\n"), crypt.Keys{KeyFile: []byte("0123456789abcdef")})
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(encFile, data, 0600))
+	assert.NoError(t, os.WriteFile(envFile, []byte("LOCAL=1\n"), 0600))
+
+	written := []string{}
+	fs.OnWrite = func(filePath string, before, after []byte) { written = append(written, filePath) }
+	t.Cleanup(func() { fs.OnWrite = nil })
+
+	cmd, err := NewDecryptCmd(&DecryptOptions{FilePath: encFile, Keys: crypt.KeyOptions{KeyFile: keyFile}})
+	assert.NoError(t, err)
+	assert.ErrorContains(t, cmd.Exec(), "already exists")
+	plaintext, err := os.ReadFile(envFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "LOCAL=1\n", string(plaintext))
+	assert.Empty(t, written)
+
+	cmd, err = NewDecryptCmd(&DecryptOptions{FilePath: encFile, Force: true, Keys: crypt.KeyOptions{KeyFile: keyFile}})
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+	plaintext, err = os.ReadFile(envFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "SECRET=\"s3cr3t\"\n", string(plaintext))
+	assert.Equal(t, []string{envFile}, written)
+}
+
+func TestNewDecryptCmd_RequiresOutputWithoutEncSuffix(t *testing.T) {
+	_, err := NewDecryptCmd(&DecryptOptions{FilePath: "secrets"})
+	assert.Error(t, err)
+
+	_, err = NewDecryptCmd(&DecryptOptions{FilePath: "secrets", Output: "-"})
+	assert.NoError(t, err)
+}
+
+func TestParseDecryptOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *DecryptOptions
+		wantErr bool
+	}{
+		"stdout": {
+			opts:    []string{"-f", ".env.enc", "-o", "-"},
+			want:    &DecryptOptions{FilePath: ".env.enc", Output: "-"},
+			wantErr: false,
+		},
+		"passphrase file": {
+			opts:    []string{"-f", ".env.enc", "--passphrase-file", "pass.txt"},
+			want:    &DecryptOptions{FilePath: ".env.enc", Keys: crypt.KeyOptions{PassphraseFile: "pass.txt"}},
+			wantErr: false,
+		},
+		"force": {
+			opts:    []string{"-f", ".env.enc", "--force"},
+			want:    &DecryptOptions{FilePath: ".env.enc", Force: true},
+			wantErr: false,
+		},
+		"missing file": {
+			opts:    []string{"-o", "-"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseDecryptOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package dedupe
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+)
+
+// DedupeOptions holds the options for collapsing duplicate keys.
+type DedupeOptions struct {
+	FilePath string
+	Keep     string
+}
+
+// DedupeCmd represents the command for collapsing keys defined more than once in a file.
+type DedupeCmd struct {
+	Options  DedupeOptions
+	OrgLines []string
+	Removed  []Removal
+}
+
+// Removal describes a duplicate line removed by the dedupe command.
+type Removal struct {
+	Key  string
+	Line int
+}
+
+func Run(args []string) error {
+	options, err := ParseDedupeOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewDedupeCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewDedupeCmd creates a new DedupeCmd instance with the specified options.
+func NewDedupeCmd(options *DedupeOptions) (*DedupeCmd, error) {
+	if options.FilePath == "" {
+		return nil, errors.New("file path is required")
+	}
+
+	return &DedupeCmd{
+		Options:  *options,
+		OrgLines: []string{},
+	}, nil
+}
+
+// Exec executes the dedupe command: reads lines, removes duplicates, writes back and reports the removed lines.
+func (c *DedupeCmd) Exec() error {
+	err := c.readLines()
+	if err != nil {
+		return err
+	}
+
+	newLines := c.makeNewLines()
+	if len(c.Removed) == 0 {
+		fmt.Println("No duplicate keys found.")
+		return nil
+	}
+
+	err = c.apply(newLines)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range c.Removed {
+		fmt.Printf("Removed %s (line %d)\n", r.Key, r.Line)
+	}
+
+	return nil
+}
+
+// readLines reads all lines from the file specified in DedupeCmd and stores them in OrgLines.
+func (c *DedupeCmd) readLines() error {
+	lines, err := fs.ReadLines(c.filePath())
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", c.filePath(), err)
+	}
+	c.OrgLines = lines
+
+	return nil
+}
+
+// makeNewLines returns a new slice of lines keeping a single line per key, chosen by the Keep strategy.
+// The removed lines are recorded in Removed.
+func (c *DedupeCmd) makeNewLines() []string {
+	removed := make([]bool, len(c.OrgLines))
+	seen := map[string]bool{}
+	c.Removed = []Removal{}
+	for _, line := range c.OrgLines {
+		key, ok := lines.Key(line)
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		indexes := lines.KeyIndexes(c.OrgLines, key)
+		if c.Options.Keep == lines.OccurrenceLast {
+			indexes = indexes[:len(indexes)-1]
+		} else {
+			indexes = indexes[1:]
+		}
+		for _, i := range indexes {
+			removed[i] = true
+		}
+	}
+
+	newLines := []string{}
+	for i, line := range c.OrgLines {
+		if removed[i] {
+			key, _ := lines.Key(line)
+			c.Removed = append(c.Removed, Removal{Key: key, Line: i + 1})
+			continue
+		}
+		newLines = append(newLines, line)
+	}
+
+	return lines.KeepTrailingNewline(c.OrgLines, newLines)
+}
+
+// apply writes the new lines to the file, overwriting the original content.
+func (c *DedupeCmd) apply(newLines []string) error {
+	if err := fs.WriteLines(c.filePath(), newLines); err != nil {
+		return fmt.Errorf("error writing to file %s: %w", c.filePath(), err)
+	}
+
+	return nil
+}
+
+// filePath returns the file path from the options.
+func (c *DedupeCmd) filePath() string {
+	return c.Options.FilePath
+}
+
+// ParseDedupeOptions parses command-line arguments and returns a DedupeOptions struct.
+func ParseDedupeOptions(opts []string) (*DedupeOptions, error) {
+	flagSet := flag.NewFlagSet("dedupe", flag.ContinueOnError)
+	file := flagSet.String("f", "", "Path to .env file")
+	keep := flagSet.String("keep", lines.OccurrenceLast, "Which line of a duplicate key to keep: first or last (effective)")
+
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	if len(flagSet.Args()) > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flagSet.Args(), " "))
+	}
+
+	if *file == "" {
+		fmt.Println("Error: -f flag is required")
+		flagSet.Usage()
+		return nil, errors.New("file path is required")
+	}
+
+	if !slices.Contains([]string{lines.OccurrenceFirst, lines.OccurrenceLast}, *keep) {
+		return nil, fmt.Errorf("--keep must be first or last, got %q", *keep)
+	}
+
+	return &DedupeOptions{
+		FilePath: *file,
+		Keep:     *keep,
+	}, nil
+}
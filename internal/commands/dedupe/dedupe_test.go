@@ -0,0 +1,92 @@
+package dedupe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_makeNewLines(t *testing.T) {
+	tests := map[string]struct {
+		orgLines    []string
+		keep        string
+		want        []string
+		wantRemoved []Removal
+	}{
+		"no duplicates": {
+			orgLines:    []string{"FOO=\"bar\"\n", "BAR=\"baz\""},
+			keep:        "last",
+			want:        []string{"FOO=\"bar\"\n", "BAR=\"baz\""},
+			wantRemoved: []Removal{},
+		},
+		"keep last": {
+			orgLines:    []string{"FOO=\"bar\"\n", "BAR=\"baz\"\n", "FOO=\"new\"\n"},
+			keep:        "last",
+			want:        []string{"BAR=\"baz\"\n", "FOO=\"new\"\n"},
+			wantRemoved: []Removal{{Key: "FOO", Line: 1}},
+		},
+		"keep first": {
+			orgLines:    []string{"FOO=\"bar\"\n", "BAR=\"baz\"\n", "FOO=\"new\"\n", "FOO=\"newer\""},
+			keep:        "first",
+			want:        []string{"FOO=\"bar\"\n", "BAR=\"baz\""},
+			wantRemoved: []Removal{{Key: "FOO", Line: 3}, {Key: "FOO", Line: 4}},
+		},
+		"commented duplicates are kept": {
+			orgLines:    []string{"# FOO=\"old\"\n", "FOO=\"bar\"\n"},
+			keep:        "last",
+			want:        []string{"# FOO=\"old\"\n", "FOO=\"bar\"\n"},
+			wantRemoved: []Removal{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmd := &DedupeCmd{
+				Options:  DedupeOptions{Keep: tt.keep},
+				OrgLines: tt.orgLines,
+			}
+			got := cmd.makeNewLines()
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantRemoved, cmd.Removed)
+		})
+	}
+}
+
+func TestParseDedupeOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *DedupeOptions
+		wantErr bool
+	}{
+		"default keep": {
+			opts:    []string{"-f", "test.env"},
+			want:    &DedupeOptions{FilePath: "test.env", Keep: "last"},
+			wantErr: false,
+		},
+		"keep first": {
+			opts:    []string{"-f", "test.env", "--keep", "first"},
+			want:    &DedupeOptions{FilePath: "test.env", Keep: "first"},
+			wantErr: false,
+		},
+		"invalid keep": {
+			opts:    []string{"-f", "test.env", "--keep", "middle"},
+			want:    nil,
+			wantErr: true,
+		},
+		"missing file": {
+			opts:    []string{},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseDedupeOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
@@ -8,12 +8,15 @@ import (
 	"strings"
 
 	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/ba58ajbse/envcraft/internal/lines"
 )
 
 // DeleteOptions holds the options for updating an environment variable.
 type DeleteOptions struct {
-	Key      string
-	FilePath string
+	Keys         []string
+	FilePath     string
+	WithComments bool
+	Occurrence   string
 }
 
 // DeleteCmd represents the command for updating an environment variable in a file.
@@ -84,46 +87,83 @@ func (c *DeleteCmd) readLines() error {
 	return nil
 }
 
-// makeNewLines returns a new slice of lines with the updated value if the key matches.
+// makeNewLines returns a new slice of lines without the lines whose key matches one of the
+// target keys or glob patterns. A key defined more than once requires an occurrence to be chosen.
+// If WithComments is set, the comment block directly above each removed line is removed too.
 // If no matching key is found, returns ErrNoUpdated.
 func (c *DeleteCmd) makeNewLines() ([]string, error) {
 	if len(c.OrgLines) == 0 {
 		return []string{}, errors.New("no lines read from file")
 	}
 
-	newLines := slices.Clone(c.OrgLines)
-
-	updatedFlag := false
-	for i, line := range c.OrgLines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue // Skip empty lines and comments
+	removed := make([]bool, len(c.OrgLines))
+	deletedKeys := map[string]bool{}
+	for _, line := range c.OrgLines {
+		key, ok := lines.Key(line)
+		if !ok || deletedKeys[key] || !c.keyMatch(key) {
+			continue
 		}
-		if !strings.Contains(line, "=") {
-			continue // Skip lines without '='
+		deletedKeys[key] = true
+		indexes, err := lines.SelectOccurrences(key, lines.KeyIndexes(c.OrgLines, key), c.Options.Occurrence)
+		if errors.Is(err, lines.ErrAmbiguousKey) {
+			return slices.Clone(c.OrgLines), fmt.Errorf("%w (use --all, --first or --last)", err)
 		}
-		parts := strings.SplitN(line, "=", 2)
-		key := strings.TrimSpace(parts[0])
-		if c.keyEqual(key) {
-			newLines = slices.Delete(newLines, i, i+1) // Remove the line with the matching key
-			updatedFlag = true
-			break
+		if err != nil {
+			return slices.Clone(c.OrgLines), err
+		}
+		for _, i := range indexes {
+			removed[i] = true
+			if c.Options.WithComments {
+				c.markCommentBlock(removed, i)
+			}
 		}
 	}
 
-	lastLineIndex := len(newLines) - 1
-	if lastLineIndex >= 0 {
-		// Ensure the last line does not end with a newline
-		newLines[lastLineIndex] = strings.TrimSuffix(newLines[lastLineIndex], "\n")
+	if missing := c.missingKeys(deletedKeys); len(missing) > 0 {
+		return slices.Clone(c.OrgLines), fmt.Errorf("%w: key not found: %s", ErrNoUpdated, strings.Join(missing, ", "))
 	}
 
-	if !updatedFlag {
-		return newLines, ErrNoUpdated
+	newLines := []string{}
+	for i, line := range c.OrgLines {
+		if !removed[i] {
+			newLines = append(newLines, line)
+		}
 	}
 
+	newLines = lines.KeepTrailingNewline(c.OrgLines, newLines)
 	return newLines, nil
 }
 
+// markCommentBlock marks the contiguous comment lines directly above index i as removed.
+func (c *DeleteCmd) markCommentBlock(removed []bool, i int) {
+	for j := i - 1; j >= 0; j-- {
+		if !strings.HasPrefix(strings.TrimSpace(c.OrgLines[j]), "#") {
+			return
+		}
+		removed[j] = true
+	}
+}
+
+// missingKeys returns the target keys that did not match any line.
+// A glob pattern is reported only if it matched nothing at all.
+func (c *DeleteCmd) missingKeys(deletedKeys map[string]bool) []string {
+	missing := []string{}
+	for _, pattern := range c.Options.Keys {
+		found := false
+		for key := range deletedKeys {
+			if lines.MatchKey(pattern, key) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, pattern)
+		}
+	}
+
+	return missing
+}
+
 // apply writes the new lines to the file, overwriting the original content.
 func (c *DeleteCmd) apply(newLines []string) error {
 	if err := fs.WriteLines(c.filePath(), newLines); err != nil {
@@ -138,35 +178,40 @@ func (s *DeleteCmd) filePath() string {
 	return s.Options.FilePath
 }
 
-// keyEqual checks if the given key matches the update target key.
-func (s *DeleteCmd) keyEqual(key string) bool {
-	return s.Options.Key == key
+// keyMatch checks if the given key matches one of the target keys or glob patterns.
+func (s *DeleteCmd) keyMatch(key string) bool {
+	return slices.ContainsFunc(s.Options.Keys, func(pattern string) bool {
+		return lines.MatchKey(pattern, key)
+	})
 }
 
 // ParseDeleteOptions parses command-line arguments and returns an DeleteOptions struct.
+// Several keys or glob patterns may be given to delete them in a single write.
 func ParseDeleteOptions(opts []string) (*DeleteOptions, error) {
 	flagSet := flag.NewFlagSet("delete", flag.ContinueOnError)
 	file := flagSet.String("f", "", "Path to .env file")
-
-	var key string
-
-	if len(opts) >= 1 && !strings.HasPrefix(opts[0], "-") {
-		key = opts[0]
-		if err := flagSet.Parse(opts[1:]); err != nil {
-			return nil, err
-		}
-	} else {
-		if err := flagSet.Parse(opts); err != nil {
-			return nil, err
-		}
-		args := flagSet.Args()
-		if len(args) < 1 {
-			return nil, errors.New("key is required")
-		}
-		key = args[0]
+	withComments := flagSet.Bool("with-comments", false, "Also delete the comment block directly above the key")
+	all := flagSet.Bool("all", false, "Delete every line of a key defined more than once")
+	first := flagSet.Bool("first", false, "Delete only the first line of a key defined more than once")
+	last := flagSet.Bool("last", false, "Delete only the last (effective) line of a key defined more than once")
+	flagSet.BoolVar(last, "effective", false, "Alias for --last")
+
+	keys := []string{}
+	for len(opts) > 0 && !strings.HasPrefix(opts[0], "-") {
+		keys = append(keys, opts[0])
+		opts = opts[1:]
+	}
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	for _, key := range flagSet.Args() {
 		if strings.HasPrefix(key, "-") {
 			return nil, errors.New("key is required")
 		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("key is required")
 	}
 
 	if *file == "" {
@@ -175,8 +220,15 @@ func ParseDeleteOptions(opts []string) (*DeleteOptions, error) {
 		return nil, errors.New("file path is required")
 	}
 
+	occurrence, err := lines.ParseOccurrence(*all, *first, *last)
+	if err != nil {
+		return nil, err
+	}
+
 	return &DeleteOptions{
-		Key:      key,
-		FilePath: *file,
+		Keys:         keys,
+		FilePath:     *file,
+		WithComments: *withComments,
+		Occurrence:   occurrence,
 	}, nil
 }
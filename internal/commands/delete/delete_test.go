@@ -8,53 +8,113 @@ import (
 
 func Test_makeNewLines(t *testing.T) {
 	tests := map[string]struct {
-		orgLines []string
-		key      string
-		want     []string
-		wantErr  bool
+		orgLines     []string
+		keys         []string
+		withComments bool
+		occurrence   string
+		want         []string
+		wantErr      bool
 	}{
 		"delete existing key": {
 			orgLines: []string{"FOO=\"bar\"\n", "BAR=\"baz\""},
-			key:      "FOO",
+			keys:     []string{"FOO"},
 			want:     []string{"BAR=\"baz\""},
 			wantErr:  false,
 		},
 		"delete existing key 2": {
 			orgLines: []string{"FOO=\"bar\"\n", "BAR=\"baz\"\n", "FIZZ=\"bazz\""},
-			key:      "BAR",
+			keys:     []string{"BAR"},
 			want:     []string{"FOO=\"bar\"\n", "FIZZ=\"bazz\""},
 			wantErr:  false,
 		},
 		"delete existing include empty line": {
 			orgLines: []string{"FOO=\"bar\"\n", "BAR=\"baz\"\n", "", "FIZZ=\"bazz\""},
-			key:      "BAR",
+			keys:     []string{"BAR"},
 			want:     []string{"FOO=\"bar\"\n", "", "FIZZ=\"bazz\""},
 			wantErr:  false,
 		},
 		"delete last line": {
 			orgLines: []string{"FOO=\"bar\"\n", "BAR=\"baz\"\n", "FIZZ=\"bazz\""},
-			key:      "FIZZ",
+			keys:     []string{"FIZZ"},
 			want:     []string{"FOO=\"bar\"\n", "BAR=\"baz\""},
 			wantErr:  false,
 		},
 		"no matching key": {
 			orgLines: []string{"FOO=\"bar\"\n"},
-			key:      "BAZ",
+			keys:     []string{"BAZ"},
 			want:     []string{"FOO=\"bar\"\n"},
 			wantErr:  true,
 		},
 		"empty orgLines": {
 			orgLines: []string{},
-			key:      "FOO",
+			keys:     []string{"FOO"},
 			want:     []string{},
 			wantErr:  true,
 		},
+		"keep trailing newline": {
+			orgLines: []string{"FOO=\"bar\"\n", "BAR=\"baz\"\n"},
+			keys:     []string{"BAR"},
+			want:     []string{"FOO=\"bar\"\n"},
+			wantErr:  false,
+		},
+		"delete multiple keys": {
+			orgLines: []string{"FOO=\"bar\"\n", "BAR=\"baz\"\n", "FIZZ=\"bazz\"\n"},
+			keys:     []string{"FOO", "FIZZ"},
+			want:     []string{"BAR=\"baz\"\n"},
+			wantErr:  false,
+		},
+		"delete by glob pattern": {
+			orgLines: []string{"LEGACY_A=\"a\"\n", "FOO=\"bar\"\n", "LEGACY_B=\"b\""},
+			keys:     []string{"LEGACY_*"},
+			want:     []string{"FOO=\"bar\""},
+			wantErr:  false,
+		},
+		"one of multiple keys missing": {
+			orgLines: []string{"FOO=\"bar\"\n", "BAR=\"baz\""},
+			keys:     []string{"FOO", "BAZ"},
+			want:     []string{"FOO=\"bar\"\n", "BAR=\"baz\""},
+			wantErr:  true,
+		},
+		"delete with comments": {
+			orgLines:     []string{"FOO=\"bar\"\n", "\n", "# database password\n", "# rotate monthly\n", "DB_PASSWORD=\"secret\"\n", "BAR=\"baz\"\n"},
+			keys:         []string{"DB_PASSWORD"},
+			withComments: true,
+			want:         []string{"FOO=\"bar\"\n", "\n", "BAR=\"baz\"\n"},
+			wantErr:      false,
+		},
+		"duplicate key without occurrence": {
+			orgLines: []string{"FOO=\"bar\"\n", "FOO=\"baz\""},
+			keys:     []string{"FOO"},
+			wantErr:  true,
+		},
+		"duplicate key, delete last": {
+			orgLines:   []string{"FOO=\"bar\"\n", "BAR=\"baz\"\n", "FOO=\"baz\"\n"},
+			keys:       []string{"FOO"},
+			occurrence: "last",
+			want:       []string{"FOO=\"bar\"\n", "BAR=\"baz\"\n"},
+			wantErr:    false,
+		},
+		"duplicate key, delete all": {
+			orgLines:   []string{"FOO=\"bar\"\n", "BAR=\"baz\"\n", "FOO=\"baz\"\n"},
+			keys:       []string{"FOO"},
+			occurrence: "all",
+			want:       []string{"BAR=\"baz\"\n"},
+			wantErr:    false,
+		},
+		"without comments keeps comment block": {
+			orgLines: []string{"# database password\n", "DB_PASSWORD=\"secret\"\n", "BAR=\"baz\"\n"},
+			keys:     []string{"DB_PASSWORD"},
+			want:     []string{"# database password\n", "BAR=\"baz\"\n"},
+			wantErr:  false,
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			cmd := &DeleteCmd{
 				Options: DeleteOptions{
-					Key: tt.key,
+					Keys:         tt.keys,
+					WithComments: tt.withComments,
+					Occurrence:   tt.occurrence,
 				},
 				OrgLines: tt.orgLines,
 			}
@@ -77,12 +137,17 @@ func TestParseDeleteOptions(t *testing.T) {
 	}{
 		"key before flags": {
 			opts:    []string{"KEY", "-f", "test.env"},
-			want:    &DeleteOptions{Key: "KEY", FilePath: "test.env"},
+			want:    &DeleteOptions{Keys: []string{"KEY"}, FilePath: "test.env"},
 			wantErr: false,
 		},
 		"flags before key": {
 			opts:    []string{"-f", "test.env", "KEY"},
-			want:    &DeleteOptions{Key: "KEY", FilePath: "test.env"},
+			want:    &DeleteOptions{Keys: []string{"KEY"}, FilePath: "test.env"},
+			wantErr: false,
+		},
+		"multiple keys and patterns": {
+			opts:    []string{"KEY", "LEGACY_*", "-f", "test.env", "--with-comments"},
+			want:    &DeleteOptions{Keys: []string{"KEY", "LEGACY_*"}, FilePath: "test.env", WithComments: true},
 			wantErr: false,
 		},
 		"missing key": {
@@ -0,0 +1,252 @@
+package diff
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envdiff"
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/ba58ajbse/envcraft/internal/sensitive"
+)
+
+// Exit codes of the diff command, as with diff(1).
+const (
+	ExitSame      = 0
+	ExitDifferent = 1
+	ExitTrouble   = 2
+)
+
+// ErrDifferent is returned when the files define different variables.
+var ErrDifferent = errors.New("files differ")
+
+// DiffOptions holds the options for comparing two env files.
+type DiffOptions struct {
+	OldFile    string
+	NewFile    string
+	KeysOnly   bool
+	JSON       bool
+	ShowValues bool
+	Patterns   []string
+	Keys       crypt.KeyOptions
+}
+
+// DiffCmd represents the command for comparing the variables of two env files.
+type DiffCmd struct {
+	Options DiffOptions
+	stdout  io.Writer
+	hashKey []byte
+}
+
+// exitError carries the exit code of the diff command.
+type exitError struct {
+	err  error
+	code int
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+func (e *exitError) ExitCode() int { return e.code }
+
+// Run compares two env files. It exits with 1 when they differ and 2 on errors, so CI can tell them apart.
+func Run(args []string) error {
+	err := run(args)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrDifferent):
+		return &exitError{err: err, code: ExitDifferent}
+	default:
+		return &exitError{err: err, code: ExitTrouble}
+	}
+}
+
+func run(args []string) error {
+	options, err := ParseDiffOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewDiffCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewDiffCmd creates a new DiffCmd instance with the specified options.
+func NewDiffCmd(options *DiffOptions) (*DiffCmd, error) {
+	if options.OldFile == "" || options.NewFile == "" {
+		return nil, errors.New("two files are required")
+	}
+
+	// Hashes are keyed per run: they show whether two values differ without letting
+	// anyone who sees the output guess a weak secret offline.
+	hashKey := make([]byte, 32)
+	if _, err := rand.Read(hashKey); err != nil {
+		return nil, fmt.Errorf("error generating hash key: %w", err)
+	}
+
+	return &DiffCmd{
+		Options: *options,
+		stdout:  os.Stdout,
+		hashKey: hashKey,
+	}, nil
+}
+
+// Exec executes the diff command: compares the resolved variables of both files, so reordering,
+// quoting and comments do not count as changes, and prints what was added, removed or changed.
+func (c *DiffCmd) Exec() error {
+	oldEnv, err := envfile.Load(c.Options.OldFile, c.Options.Keys)
+	if err != nil {
+		return err
+	}
+	newEnv, err := envfile.Load(c.Options.NewFile, c.Options.Keys)
+	if err != nil {
+		return err
+	}
+
+	secret := sensitive.Keys(oldEnv.Lines, c.Options.Patterns)
+	for key, isSecret := range sensitive.Keys(newEnv.Lines, c.Options.Patterns) {
+		secret[key] = secret[key] || isSecret
+	}
+
+	changes := envdiff.Compare(oldEnv.Values, newEnv.Values)
+	for i, change := range changes {
+		if secret[change.Key] && !c.Options.ShowValues {
+			changes[i].Old, changes[i].New = c.mask(change.Old), c.mask(change.New)
+		}
+	}
+
+	if c.Options.JSON {
+		err = c.printJSON(changes)
+	} else {
+		c.printText(changes)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(changes) > 0 {
+		return fmt.Errorf("%w: %d key(s)", ErrDifferent, len(changes))
+	}
+	return nil
+}
+
+// mask returns a keyed hash standing in for a secret value.
+func (c *DiffCmd) mask(value string) string {
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, c.hashKey)
+	mac.Write([]byte(value))
+	return "hash:" + hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+func (c *DiffCmd) printText(changes []envdiff.Change) {
+	if len(changes) == 0 {
+		fmt.Fprintf(c.stdout, "No differences between %s and %s.\n", c.Options.OldFile, c.Options.NewFile)
+		return
+	}
+	fmt.Fprintf(c.stdout, "--- %s\n+++ %s\n", c.Options.OldFile, c.Options.NewFile)
+	if c.Options.KeysOnly {
+		envdiff.PrintMasked(c.stdout, changes)
+		return
+	}
+	for _, change := range changes {
+		switch change.Kind {
+		case envdiff.Added:
+			fmt.Fprintf(c.stdout, "  + %s=%s\n", change.Key, format(change.New))
+		case envdiff.Removed:
+			fmt.Fprintf(c.stdout, "  - %s=%s\n", change.Key, format(change.Old))
+		case envdiff.Changed:
+			fmt.Fprintf(c.stdout, "  ~ %s: %s -> %s\n", change.Key, format(change.Old), format(change.New))
+		}
+	}
+}
+
+// format quotes a value, leaving masked values as they are.
+func format(value string) string {
+	if strings.HasPrefix(value, "hash:") {
+		return value
+	}
+	return strconv.Quote(value)
+}
+
+// jsonChange is a change in the --json output.
+type jsonChange struct {
+	Key string  `json:"key"`
+	Old *string `json:"old,omitempty"`
+	New *string `json:"new,omitempty"`
+}
+
+func (c *DiffCmd) printJSON(changes []envdiff.Change) error {
+	out := map[string][]jsonChange{"added": {}, "removed": {}, "changed": {}}
+	names := map[string]string{envdiff.Added: "added", envdiff.Removed: "removed", envdiff.Changed: "changed"}
+	for _, change := range changes {
+		entry := jsonChange{Key: change.Key}
+		if !c.Options.KeysOnly {
+			if change.Kind != envdiff.Added {
+				entry.Old = &change.Old
+			}
+			if change.Kind != envdiff.Removed {
+				entry.New = &change.New
+			}
+		}
+		out[names[change.Kind]] = append(out[names[change.Kind]], entry)
+	}
+
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// ParseDiffOptions parses command-line arguments and returns a DiffOptions struct.
+func ParseDiffOptions(opts []string) (*DiffOptions, error) {
+	flagSet := flag.NewFlagSet("diff", flag.ContinueOnError)
+	keysOnly := flagSet.Bool("keys-only", false, "Only report which keys differ, without values")
+	jsonOutput := flagSet.Bool("json", false, "Print the differences as JSON")
+	showValues := flagSet.Bool("show-values", false, "Show secret values instead of hashes")
+	patterns := flagSet.String("secret-pattern", strings.Join(sensitive.DefaultPatterns, ","), "Comma separated glob patterns of secret keys, unless annotated with "+sensitive.SecretAnnotation+" or "+sensitive.PublicAnnotation)
+
+	var keys crypt.KeyOptions
+	keys.AddFlags(flagSet)
+	keys.AddRecipientFlags(flagSet)
+
+	args := []string{}
+	for len(opts) > 0 && !strings.HasPrefix(opts[0], "-") {
+		args = append(args, opts[0])
+		opts = opts[1:]
+	}
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	args = append(args, flagSet.Args()...)
+	if len(args) != 2 {
+		return nil, errors.New("usage: envcraft diff OLD_FILE NEW_FILE [--keys-only] [--json]")
+	}
+
+	return &DiffOptions{
+		OldFile:    args[0],
+		NewFile:    args[1],
+		KeysOnly:   *keysOnly,
+		JSON:       *jsonOutput,
+		ShowValues: *showValues,
+		Patterns:   input.SplitList(*patterns),
+		Keys:       keys,
+	}, nil
+}
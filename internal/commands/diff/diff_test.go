@@ -0,0 +1,110 @@
+package diff
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/sensitive"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeEnvFiles(t *testing.T, oldContent, newContent string) (string, string) {
+	dir := t.TempDir()
+	oldFile, newFile := filepath.Join(dir, ".env.staging"), filepath.Join(dir, ".env.production")
+	assert.NoError(t, os.WriteFile(oldFile, []byte(oldContent), 0644))
+	assert.NoError(t, os.WriteFile(newFile, []byte(newContent), 0644))
+	return oldFile, newFile
+}
+
+func TestExec(t *testing.T) {
+	oldFile, newFile := writeEnvFiles(t,
+		"# staging\nHOST=staging.example.com\nDB_PASSWORD=one\nDEBUG=true\nSAME='x'\n",
+		"SAME=\"x\"\nHOST=example.com\nDB_PASSWORD=two\nWORKERS=4\n",
+	)
+
+	tests := map[string]struct {
+		options DiffOptions
+		want    *regexp.Regexp
+	}{
+		"text": {
+			options: DiffOptions{},
+			want: regexp.MustCompile(`^--- \S+\n\+\+\+ \S+\n` +
+				`  ~ DB_PASSWORD: hash:[0-9a-f]{12} -> hash:[0-9a-f]{12}\n` +
+				`  - DEBUG="true"\n` +
+				`  ~ HOST: "staging.example.com" -> "example.com"\n` +
+				`  \+ WORKERS="4"\n$`),
+		},
+		"keys only": {
+			options: DiffOptions{KeysOnly: true},
+			want:    regexp.MustCompile(`\n  ~ DB_PASSWORD\n  - DEBUG\n  ~ HOST\n  \+ WORKERS\n$`),
+		},
+		"json": {
+			options: DiffOptions{JSON: true, ShowValues: true},
+			want:    regexp.MustCompile(`"changed": \[\n\s+\{\n\s+"key": "DB_PASSWORD",\n\s+"old": "one",\n\s+"new": "two"\n`),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.options.OldFile, tt.options.NewFile = oldFile, newFile
+			tt.options.Patterns = sensitive.DefaultPatterns
+			cmd, err := NewDiffCmd(&tt.options)
+			assert.NoError(t, err)
+			var stdout bytes.Buffer
+			cmd.stdout = &stdout
+
+			assert.ErrorIs(t, cmd.Exec(), ErrDifferent)
+			assert.Regexp(t, tt.want, stdout.String())
+		})
+	}
+}
+
+func TestRun_ExitCodes(t *testing.T) {
+	oldFile, newFile := writeEnvFiles(t, "A=1\nB=2\n", "B='2'\nA=1\n")
+
+	assert.NoError(t, Run([]string{oldFile, newFile}))
+
+	assert.NoError(t, os.WriteFile(newFile, []byte("A=2\n"), 0644))
+	var exit interface{ ExitCode() int }
+	err := Run([]string{oldFile, newFile})
+	assert.ErrorAs(t, err, &exit)
+	assert.Equal(t, ExitDifferent, exit.ExitCode())
+
+	err = Run([]string{oldFile, filepath.Join(t.TempDir(), "missing")})
+	assert.ErrorAs(t, err, &exit)
+	assert.Equal(t, ExitTrouble, exit.ExitCode())
+}
+
+func TestParseDiffOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *DiffOptions
+		wantErr bool
+	}{
+		"files and flags": {
+			opts:    []string{".env.staging", ".env.production", "--keys-only", "--json"},
+			want:    &DiffOptions{OldFile: ".env.staging", NewFile: ".env.production", KeysOnly: true, JSON: true, Patterns: sensitive.DefaultPatterns},
+			wantErr: false,
+		},
+		"one file": {
+			opts:    []string{".env"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseDiffOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
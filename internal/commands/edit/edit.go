@@ -0,0 +1,306 @@
+package edit
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envdiff"
+	"github.com/ba58ajbse/envcraft/internal/envedit"
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+	"github.com/joho/godotenv"
+)
+
+// validKey matches the variable names accepted by POSIX shells.
+var validKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// EditOptions holds the options for editing an env file in an editor.
+type EditOptions struct {
+	FilePath string
+	Keys     crypt.KeyOptions
+}
+
+// EditCmd represents the command for editing an env file, decrypted if needed, in $EDITOR.
+type EditCmd struct {
+	Options EditOptions
+	editor  func(path string) error
+	confirm func(prompt string) (bool, error)
+	values  *envedit.Editor
+}
+
+func Run(args []string) error {
+	options, err := ParseEditOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewEditCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewEditCmd creates a new EditCmd instance with the specified options.
+func NewEditCmd(options *EditOptions) (*EditCmd, error) {
+	if options.FilePath == "" {
+		return nil, errors.New("file path is required")
+	}
+
+	return &EditCmd{
+		Options: *options,
+		editor:  openEditor,
+		confirm: func(prompt string) (bool, error) { return input.Confirm(prompt, true) },
+	}, nil
+}
+
+// Exec executes the edit command: opens a copy of the file, decrypted if it is encrypted, in the
+// editor and writes it back, re-encrypted with the same keys, once it is valid. Encrypted values
+// are decrypted in the copy too, and every value of their keys is encrypted again when saved.
+// The copy is only readable by the owner and removed when the command ends.
+func (c *EditCmd) Exec() error {
+	data, err := os.ReadFile(c.Options.FilePath)
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", c.Options.FilePath, err)
+	}
+	info, err := os.Stat(c.Options.FilePath)
+	if err != nil {
+		return err
+	}
+
+	var envelope *crypt.Envelope
+	var dataKey []byte
+	plaintext := data
+	if crypt.IsEncrypted(data) {
+		if envelope, err = crypt.Parse(data); err != nil {
+			return err
+		}
+		keys, err := c.Options.Keys.LoadFile(false)
+		if err != nil {
+			return err
+		}
+		if dataKey, err = envelope.DataKey(keys); err != nil {
+			return err
+		}
+		if plaintext, err = envelope.Open(dataKey); err != nil {
+			return err
+		}
+	}
+
+	fileLines := strings.SplitAfter(string(plaintext), "\n")
+	c.values = &envedit.Editor{LoadKeys: func() (crypt.Keys, error) {
+		return envfile.LoadValueKeys(fileLines, c.Options.Keys, false)
+	}}
+	encryptedKeys := encryptedValueKeys(fileLines)
+	shownLines := fileLines
+	for _, key := range encryptedKeys {
+		if shownLines, err = c.values.Decrypt(shownLines, key); err != nil {
+			return err
+		}
+	}
+	shown := []byte(strings.Join(shownLines, ""))
+
+	dir, err := os.MkdirTemp("", "envcraft-edit-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	tmpFile := filepath.Join(dir, strings.TrimSuffix(filepath.Base(c.Options.FilePath), ".enc"))
+	if err := os.WriteFile(tmpFile, shown, 0600); err != nil {
+		return fmt.Errorf("error writing temporary file: %w", err)
+	}
+
+	var edited []byte
+	for {
+		if err := c.editor(tmpFile); err != nil {
+			return fmt.Errorf("error running editor: %w", err)
+		}
+		if edited, err = os.ReadFile(tmpFile); err != nil {
+			return fmt.Errorf("error reading temporary file: %w", err)
+		}
+
+		err := validate(edited)
+		if err == nil {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", c.Options.FilePath, err)
+		reopen, confirmErr := c.confirm("Re-open the editor?")
+		if confirmErr != nil {
+			return confirmErr
+		}
+		if !reopen {
+			return fmt.Errorf("%w; %s was not changed", err, c.Options.FilePath)
+		}
+	}
+
+	if bytes.Equal(edited, shown) {
+		fmt.Println("No changes.")
+		return nil
+	}
+
+	for _, warning := range lint(edited) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	oldEnv, _ := godotenv.UnmarshalBytes(shown)
+	newEnv, _ := godotenv.UnmarshalBytes(edited)
+	for _, key := range encryptedKeys {
+		if _, ok := newEnv[key]; !ok {
+			fmt.Fprintf(os.Stderr, "Warning: %s held an encrypted value and was removed; a key it was renamed to is saved unencrypted\n", key)
+		}
+	}
+	changes := envdiff.Compare(oldEnv, newEnv)
+	if len(changes) > 0 {
+		fmt.Printf("Changes to %s:\n", c.Options.FilePath)
+		envdiff.PrintMasked(os.Stdout, changes)
+	} else {
+		fmt.Printf("Changes to %s: comments or formatting only\n", c.Options.FilePath)
+	}
+
+	output, err := c.encryptValues(edited, encryptedKeys, fileLines, shownLines)
+	if err != nil {
+		return err
+	}
+	if envelope != nil {
+		if output, err = crypt.Seal(output, dataKey, envelope.Stanzas); err != nil {
+			return err
+		}
+	}
+	return fs.WriteFileAtomic(c.Options.FilePath, output, info.Mode().Perm())
+}
+
+// encryptValues returns edited with every value of encryptedKeys encrypted. Lines left as they
+// were shown get their encrypted line back, so that unchanged values keep their ciphertext.
+func (c *EditCmd) encryptValues(edited []byte, encryptedKeys, fileLines, shownLines []string) ([]byte, error) {
+	if len(encryptedKeys) == 0 {
+		return edited, nil
+	}
+	encryptedLines := map[string]string{}
+	for i, line := range shownLines {
+		if line != fileLines[i] {
+			encryptedLines[strings.TrimSuffix(line, "\n")] = strings.TrimSuffix(fileLines[i], "\n")
+		}
+	}
+
+	editedLines := strings.SplitAfter(string(edited), "\n")
+	for i, line := range editedLines {
+		text, newline := strings.CutSuffix(line, "\n")
+		if encrypted, ok := encryptedLines[text]; ok {
+			editedLines[i] = encrypted
+			if newline {
+				editedLines[i] += "\n"
+			}
+		}
+	}
+	var err error
+	for _, key := range encryptedKeys {
+		if editedLines, err = c.values.Encrypt(editedLines, key); err != nil {
+			return nil, err
+		}
+	}
+	return []byte(strings.Join(editedLines, "")), nil
+}
+
+// encryptedValueKeys returns the keys with an encrypted value in fileLines.
+func encryptedValueKeys(fileLines []string) []string {
+	keys := []string{}
+	for _, line := range fileLines {
+		key, ok := lines.Key(line)
+		if !ok || slices.Contains(keys, key) {
+			continue
+		}
+		if value, _ := lines.Value(line); crypt.IsEncryptedValue(value) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// validate returns an error if data cannot be parsed or defines a key that is not a valid name.
+func validate(data []byte) error {
+	env, err := godotenv.UnmarshalBytes(data)
+	if err != nil {
+		return fmt.Errorf("invalid env file: %w", err)
+	}
+	invalid := []string{}
+	for key := range env {
+		if !validKey.MatchString(key) {
+			invalid = append(invalid, key)
+		}
+	}
+	if len(invalid) > 0 {
+		slices.Sort(invalid)
+		return fmt.Errorf("invalid key: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// lint returns warnings about a valid file, such as keys defined more than once.
+func lint(data []byte) []string {
+	fileLines := strings.SplitAfter(string(data), "\n")
+	warnings := []string{}
+	seen := map[string]bool{}
+	for _, line := range fileLines {
+		key, ok := lines.Key(line)
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if indexes := lines.KeyIndexes(fileLines, key); len(indexes) > 1 {
+			warnings = append(warnings, fmt.Sprintf("%s is defined %d times; the last one wins", key, len(indexes)))
+		}
+	}
+	return warnings
+}
+
+// openEditor opens path in $VISUAL or $EDITOR, falling back to vi.
+func openEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// ParseEditOptions parses command-line arguments and returns an EditOptions struct.
+func ParseEditOptions(opts []string) (*EditOptions, error) {
+	flagSet := flag.NewFlagSet("edit", flag.ContinueOnError)
+	file := flagSet.String("f", ".env", "Path to the .env file, encrypted or not")
+
+	var keys crypt.KeyOptions
+	keys.AddFlags(flagSet)
+	keys.AddRecipientFlags(flagSet)
+
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	if len(flagSet.Args()) > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flagSet.Args(), " "))
+	}
+
+	return &EditOptions{
+		FilePath: *file,
+		Keys:     keys,
+	}, nil
+}
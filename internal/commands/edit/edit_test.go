@@ -0,0 +1,189 @@
+package edit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeEditor returns an editor that replaces the file with each of contents in turn.
+func writeEditor(t *testing.T, contents ...string) func(string) error {
+	calls := 0
+	return func(path string) error {
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		err = os.WriteFile(path, []byte(contents[calls]), 0600)
+		calls++
+		return err
+	}
+}
+
+func TestExec_EncryptedFile(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), ".env.enc")
+	keys := crypt.Keys{KeyFile: []byte("team key")}
+	data, err := crypt.Encrypt([]byte("A=1\n"), keys)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(envFile, data, 0644))
+
+	keyFile := filepath.Join(t.TempDir(), "team.key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("team key"), 0600))
+
+	cmd, err := NewEditCmd(&EditOptions{FilePath: envFile, Keys: crypt.KeyOptions{KeyFile: keyFile}})
+	assert.NoError(t, err)
+	cmd.editor = func(path string) error {
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "A=1\n", string(content))
+		return os.WriteFile(path, []byte("A=2\nB=3\n"), 0600)
+	}
+	assert.NoError(t, cmd.Exec())
+
+	data, err = os.ReadFile(envFile)
+	assert.NoError(t, err)
+	plaintext, err := crypt.Decrypt(data, keys)
+	assert.NoError(t, err)
+	assert.Equal(t, "A=2\nB=3\n", string(plaintext))
+}
+
+func TestExec_EncryptedValues(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	keyFile := filepath.Join(dir, "team.key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("team key"), 0600))
+	cipher := crypt.NewValueCipher(crypt.Keys{KeyFile: []byte("team key")})
+	token, err := cipher.Encrypt("TOKEN", "old $HOME")
+	assert.NoError(t, err)
+	password, err := cipher.Encrypt("PASSWORD", "unchanged")
+	assert.NoError(t, err)
+	passwordLine := "PASSWORD=\"" + password + "\" # kept\n"
+	assert.NoError(t, os.WriteFile(envFile, []byte("A=1\nTOKEN=\""+token+"\"\n"+passwordLine), 0600))
+
+	cmd, err := NewEditCmd(&EditOptions{FilePath: envFile, Keys: crypt.KeyOptions{KeyFile: keyFile}})
+	assert.NoError(t, err)
+	cmd.editor = func(path string) error {
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "A=1\nTOKEN=\"old \\$HOME\"\nPASSWORD=\"unchanged\"\n", string(content))
+		return os.WriteFile(path, []byte("A=2\nTOKEN=\"new\"\nPASSWORD=\"unchanged\"\nTOKEN=added\n"), 0600)
+	}
+	assert.NoError(t, cmd.Exec())
+
+	data, err := os.ReadFile(envFile)
+	assert.NoError(t, err)
+	fileLines := strings.SplitAfter(string(data), "\n")
+	assert.Equal(t, "A=2\n", fileLines[0])
+	assert.Equal(t, passwordLine, fileLines[2], "unchanged values keep their ciphertext")
+	for i, want := range map[int]string{1: "new", 3: "added"} {
+		value, _ := lines.Value(fileLines[i])
+		assert.True(t, crypt.IsEncryptedValue(value))
+		plaintext, err := cipher.Decrypt("TOKEN", value)
+		assert.NoError(t, err)
+		assert.Equal(t, want, plaintext)
+	}
+}
+
+func TestExec_InvalidReopens(t *testing.T) {
+	tests := map[string]struct {
+		reopen  bool
+		want    string
+		wantErr bool
+	}{
+		"reopen and fix": {
+			reopen:  true,
+			want:    "A=1\nB=2\n",
+			wantErr: false,
+		},
+		"give up": {
+			reopen:  false,
+			want:    "A=1\n",
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			envFile := filepath.Join(t.TempDir(), ".env")
+			assert.NoError(t, os.WriteFile(envFile, []byte("A=1\n"), 0644))
+
+			cmd, err := NewEditCmd(&EditOptions{FilePath: envFile})
+			assert.NoError(t, err)
+			cmd.editor = writeEditor(t, "A=1\n1B=2\n", "A=1\nB=2\n")
+			cmd.confirm = func(string) (bool, error) { return tt.reopen, nil }
+
+			err = cmd.Exec()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			content, _ := os.ReadFile(envFile)
+			assert.Equal(t, tt.want, string(content))
+		})
+	}
+}
+
+func TestParseEditOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *EditOptions
+		wantErr bool
+	}{
+		"default file": {
+			opts:    []string{},
+			want:    &EditOptions{FilePath: ".env"},
+			wantErr: false,
+		},
+		"file and identity": {
+			opts:    []string{"-f", ".env.enc", "--identity", "id"},
+			want:    &EditOptions{FilePath: ".env.enc", Keys: crypt.KeyOptions{Identity: "id"}},
+			wantErr: false,
+		},
+		"unexpected argument": {
+			opts:    []string{"extra"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseEditOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExec_InvalidWithoutTerminalStops(t *testing.T) {
+	orgStdin := input.Stdin
+	input.Stdin = strings.NewReader("")
+	t.Cleanup(func() { input.Stdin = orgStdin })
+
+	envFile := filepath.Join(t.TempDir(), ".env")
+	assert.NoError(t, os.WriteFile(envFile, []byte("A=1\n"), 0644))
+
+	cmd, err := NewEditCmd(&EditOptions{FilePath: envFile})
+	assert.NoError(t, err)
+	calls := 0
+	cmd.editor = func(path string) error {
+		calls++
+		return os.WriteFile(path, []byte("1B=2\n"), 0600)
+	}
+
+	assert.ErrorIs(t, cmd.Exec(), input.ErrNoAnswer)
+	assert.Equal(t, 1, calls)
+	content, _ := os.ReadFile(envFile)
+	assert.Equal(t, "A=1\n", string(content))
+}
@@ -0,0 +1,124 @@
+package encrypt
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/fs"
+)
+
+// EncryptOptions holds the options for encrypting an env file.
+type EncryptOptions struct {
+	FilePath string
+	Output   string
+	Force    bool
+	Keys     crypt.KeyOptions
+}
+
+// EncryptCmd represents the command for encrypting a whole env file.
+type EncryptCmd struct {
+	Options EncryptOptions
+}
+
+func Run(args []string) error {
+	options, err := ParseEncryptOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewEncryptCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewEncryptCmd creates a new EncryptCmd instance with the specified options.
+func NewEncryptCmd(options *EncryptOptions) (*EncryptCmd, error) {
+	if options.FilePath == "" {
+		return nil, errors.New("file path is required")
+	}
+
+	return &EncryptCmd{
+		Options: *options,
+	}, nil
+}
+
+// Exec executes the encrypt command: reads the plaintext file and writes the encrypted copy.
+func (c *EncryptCmd) Exec() error {
+	plaintext, err := os.ReadFile(c.Options.FilePath)
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", c.Options.FilePath, err)
+	}
+	if crypt.IsEncrypted(plaintext) {
+		return fmt.Errorf("file %s is already encrypted", c.Options.FilePath)
+	}
+
+	if _, err := os.Stat(c.output()); err == nil && !c.Options.Force {
+		return fmt.Errorf("file %s already exists (use --force to overwrite)", c.output())
+	}
+
+	keys, err := c.Options.Keys.LoadFile(true)
+	if err != nil {
+		return err
+	}
+
+	data, err := crypt.Encrypt(plaintext, keys)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.WriteFileAtomic(c.output(), data, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("Encrypted %s to %s\n", c.Options.FilePath, c.output())
+
+	return nil
+}
+
+// output returns the path of the encrypted file, FILE.enc unless set with -o.
+func (c *EncryptCmd) output() string {
+	if c.Options.Output != "" {
+		return c.Options.Output
+	}
+	return c.Options.FilePath + ".enc"
+}
+
+// ParseEncryptOptions parses command-line arguments and returns an EncryptOptions struct.
+func ParseEncryptOptions(opts []string) (*EncryptOptions, error) {
+	flagSet := flag.NewFlagSet("encrypt", flag.ContinueOnError)
+	file := flagSet.String("f", "", "Path to .env file")
+	output := flagSet.String("o", "", "Path to the encrypted file (default FILE.enc)")
+	force := flagSet.Bool("force", false, "Overwrite the encrypted file if it exists")
+
+	var keys crypt.KeyOptions
+	keys.AddFlags(flagSet)
+	keys.AddRecipientFlags(flagSet)
+
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	if len(flagSet.Args()) > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flagSet.Args(), " "))
+	}
+
+	if *file == "" {
+		fmt.Println("Error: -f flag is required")
+		flagSet.Usage()
+		return nil, errors.New("file path is required")
+	}
+
+	return &EncryptOptions{
+		FilePath: *file,
+		Output:   *output,
+		Force:    *force,
+		Keys:     keys,
+	}, nil
+}
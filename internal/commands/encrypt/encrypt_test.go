@@ -0,0 +1,113 @@
+package encrypt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec_EncryptsToEncFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	envFile := filepath.Join(tmpDir, ".env.production")
+	assert.NoError(t, os.WriteFile(envFile, []byte("SECRET=\"s3cr3t\"\n"), 0600))
+	t.Setenv(crypt.EnvPassphrase, "correct horse")
+
+	cmd, err := NewEncryptCmd(&EncryptOptions{FilePath: envFile})
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+
+	data, err := os.ReadFile(envFile + ".enc")
+	assert.NoError(t, err)
+	assert.True(t, crypt.IsEncrypted(data))
+
+	plaintext, err := crypt.Decrypt(data, crypt.Keys{Passphrase: []byte("correct horse")})
+	assert.NoError(t, err)
+	assert.Equal(t, "SECRET=\"s3cr3t\"\n", string(plaintext))
+
+	info, err := os.Stat(envFile + ".enc")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Encrypting an encrypted file is refused.
+	cmd, err = NewEncryptCmd(&EncryptOptions{FilePath: envFile + ".enc"})
+	assert.NoError(t, err)
+	assert.Error(t, cmd.Exec())
+}
+
+func TestExec_OverwritesOnlyWithForce(t *testing.T) {
+	tmpDir := t.TempDir()
+	envFile := filepath.Join(tmpDir, ".env")
+	encFile := envFile + ".enc"
+	assert.NoError(t, os.WriteFile(envFile, []byte("SECRET=\"s3cr3t\"\n"), 0600))
+	assert.NoError(t, os.WriteFile(encFile, []byte("keep me\n"), 0600))
+	t.Setenv(crypt.EnvPassphrase, "correct horse")
+
+	written := []string{}
+	fs.OnWrite = func(filePath string, before, after []byte) { written = append(written, filePath) }
+	t.Cleanup(func() { fs.OnWrite = nil })
+
+	cmd, err := NewEncryptCmd(&EncryptOptions{FilePath: envFile})
+	assert.NoError(t, err)
+	assert.ErrorContains(t, cmd.Exec(), "already exists")
+	data, err := os.ReadFile(encFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "keep me\n", string(data))
+	assert.Empty(t, written)
+
+	cmd, err = NewEncryptCmd(&EncryptOptions{FilePath: envFile, Force: true})
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+	data, err = os.ReadFile(encFile)
+	assert.NoError(t, err)
+	assert.True(t, crypt.IsEncrypted(data))
+	assert.Equal(t, []string{encFile}, written)
+}
+
+func TestParseEncryptOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *EncryptOptions
+		wantErr bool
+	}{
+		"file only": {
+			opts:    []string{"-f", ".env"},
+			want:    &EncryptOptions{FilePath: ".env"},
+			wantErr: false,
+		},
+		"output and key file": {
+			opts:    []string{"-f", ".env", "-o", "out.enc", "--key-file", "team.key"},
+			want:    &EncryptOptions{FilePath: ".env", Output: "out.enc", Keys: crypt.KeyOptions{KeyFile: "team.key"}},
+			wantErr: false,
+		},
+		"force": {
+			opts:    []string{"-f", ".env", "--force"},
+			want:    &EncryptOptions{FilePath: ".env", Force: true},
+			wantErr: false,
+		},
+		"missing file": {
+			opts:    []string{},
+			want:    nil,
+			wantErr: true,
+		},
+		"unexpected argument": {
+			opts:    []string{"-f", ".env", "extra"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseEncryptOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
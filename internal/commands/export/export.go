@@ -0,0 +1,130 @@
+package export
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+)
+
+// ExportOptions holds the options for exporting an env file to another format.
+type ExportOptions struct {
+	FilePath string
+	Format   string
+	Output   string
+	Only     []string
+	Exclude  []string
+	Profile  envfile.ProfileOptions
+	Keys     crypt.KeyOptions
+}
+
+// ExportCmd represents the command for rendering the variables of an env file for other tools.
+type ExportCmd struct {
+	Options ExportOptions
+}
+
+func Run(args []string) error {
+	options, err := ParseExportOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewExportCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewExportCmd creates a new ExportCmd instance with the specified options.
+func NewExportCmd(options *ExportOptions) (*ExportCmd, error) {
+	if options.FilePath == "" {
+		return nil, errors.New("file path is required")
+	}
+	if !slices.Contains(Formats, options.Format) {
+		return nil, fmt.Errorf("unknown format %q (expected one of %s)", options.Format, strings.Join(Formats, ", "))
+	}
+
+	return &ExportCmd{
+		Options: *options,
+	}, nil
+}
+
+// Exec executes the export command: resolves the variables of the file or profile as run would, keeps the
+// selected ones and writes them in the chosen format to stdout or to the output file.
+func (c *ExportCmd) Exec() error {
+	env, err := c.Options.Profile.Load(c.Options.FilePath, c.Options.Keys)
+	if err != nil {
+		return err
+	}
+	env.Keys = slices.DeleteFunc(env.Keys, func(key string) bool { return !c.selected(key) })
+
+	out, err := render(c.Options.Format, env)
+	if err != nil {
+		return err
+	}
+
+	if c.Options.Output == "" || c.Options.Output == "-" {
+		_, err := os.Stdout.WriteString(out)
+		return err
+	}
+	// The output holds the same secrets as the env file, so it is only readable by the owner.
+	if err := os.WriteFile(c.Options.Output, []byte(out), 0600); err != nil {
+		return fmt.Errorf("error writing to file %s: %w", c.Options.Output, err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d variable(s) to %s\n", len(env.Keys), c.Options.Output)
+
+	return nil
+}
+
+// selected reports whether key matches --only, if given, and none of --exclude.
+func (c *ExportCmd) selected(key string) bool {
+	if len(c.Options.Only) > 0 && !slices.ContainsFunc(c.Options.Only, func(pattern string) bool { return lines.MatchKey(pattern, key) }) {
+		return false
+	}
+	return !slices.ContainsFunc(c.Options.Exclude, func(pattern string) bool { return lines.MatchKey(pattern, key) })
+}
+
+// ParseExportOptions parses command-line arguments and returns an ExportOptions struct.
+func ParseExportOptions(opts []string) (*ExportOptions, error) {
+	flagSet := flag.NewFlagSet("export", flag.ContinueOnError)
+	file := flagSet.String("f", ".env", "Path to the .env file, encrypted or not")
+	format := flagSet.String("format", FormatShell, "Output format: "+strings.Join(Formats, ", "))
+	output := flagSet.String("o", "", "Path to the output file (default stdout)")
+	only := flagSet.String("only", "", "Comma separated keys or glob patterns to export")
+	exclude := flagSet.String("exclude", "", "Comma separated keys or glob patterns not to export")
+
+	var profile envfile.ProfileOptions
+	profile.AddFlags(flagSet)
+
+	var keys crypt.KeyOptions
+	keys.AddFlags(flagSet)
+	keys.AddRecipientFlags(flagSet)
+
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	if len(flagSet.Args()) > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flagSet.Args(), " "))
+	}
+
+	return &ExportOptions{
+		FilePath: *file,
+		Format:   *format,
+		Output:   *output,
+		Only:     input.SplitList(*only),
+		Exclude:  input.SplitList(*exclude),
+		Profile:  profile,
+		Keys:     keys,
+	}, nil
+}
@@ -0,0 +1,150 @@
+package export
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	env := &envfile.Env{
+		Keys:   []string{"NAME", "QUOTE", "PRICE"},
+		Values: map[string]string{"NAME": "app", "QUOTE": `it's "ok"`, "PRICE": "$5 #1"},
+	}
+
+	tests := map[string]struct {
+		format  string
+		want    string
+		wantErr bool
+	}{
+		"json": {
+			format: FormatJSON,
+			want:   "{\n  \"NAME\": \"app\",\n  \"QUOTE\": \"it's \\\"ok\\\"\",\n  \"PRICE\": \"$5 #1\"\n}\n",
+		},
+		"yaml": {
+			format: FormatYAML,
+			want:   "NAME: app\nQUOTE: it's \"ok\"\nPRICE: '$5 #1'\n",
+		},
+		"shell": {
+			format: FormatShell,
+			want:   "export NAME='app'\nexport QUOTE='it'\\''s \"ok\"'\nexport PRICE='$5 #1'\n",
+		},
+		"fish": {
+			format: FormatFish,
+			want:   "set -gx NAME 'app'\nset -gx QUOTE 'it\\'s \"ok\"'\nset -gx PRICE '$5 #1'\n",
+		},
+		"powershell": {
+			format: FormatPowerShell,
+			want:   "$env:NAME = 'app'\n$env:QUOTE = 'it''s \"ok\"'\n$env:PRICE = '$5 #1'\n",
+		},
+		"docker": {
+			format: FormatDocker,
+			want:   "NAME=app\nQUOTE=it's \"ok\"\nPRICE=$5 #1\n",
+		},
+		"systemd": {
+			format: FormatSystemd,
+			want:   "NAME=\"app\"\nQUOTE=\"it's \\\"ok\\\"\"\nPRICE=\"\\$5 #1\"\n",
+		},
+		"makefile": {
+			format: FormatMakefile,
+			want:   "export NAME := app\nexport QUOTE := it's \"ok\"\nexport PRICE := $$5 \\#1\n",
+		},
+		"properties": {
+			format: FormatProperties,
+			want:   "NAME=app\nQUOTE=it's \"ok\"\nPRICE=$5 #1\n",
+		},
+		"unknown format": {
+			format:  "toml",
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := render(tt.format, env)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRender_MultiLine(t *testing.T) {
+	env := &envfile.Env{Keys: []string{"KEY"}, Values: map[string]string{"KEY": "line 1\nline 2 é"}}
+
+	_, err := render(FormatDocker, env)
+	assert.Error(t, err)
+	_, err = render(FormatMakefile, env)
+	assert.Error(t, err)
+
+	got, err := render(FormatProperties, env)
+	assert.NoError(t, err)
+	assert.Equal(t, "KEY=line 1\\nline 2 \\u00E9\n", got)
+
+	// Shell output reads back the exact value.
+	got, err = render(FormatShell, env)
+	assert.NoError(t, err)
+	out, err := exec.Command("sh", "-c", got+`printf %s "$KEY"`).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "line 1\nline 2 é", string(out))
+}
+
+func TestExec_Filters(t *testing.T) {
+	tmpDir := t.TempDir()
+	envFile := filepath.Join(tmpDir, ".env")
+	output := filepath.Join(tmpDir, "out.json")
+	assert.NoError(t, os.WriteFile(envFile, []byte("DB_HOST=db\nDB_PASSWORD=secret\nAPP=web\n"), 0644))
+
+	cmd, err := NewExportCmd(&ExportOptions{FilePath: envFile, Format: FormatJSON, Output: output, Only: []string{"DB_*"}, Exclude: []string{"*PASSWORD"}})
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+
+	content, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"DB_HOST\": \"db\"\n}\n", string(content))
+	info, _ := os.Stat(output)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestParseExportOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *ExportOptions
+		wantErr bool
+	}{
+		"defaults": {
+			opts:    []string{},
+			want:    &ExportOptions{FilePath: ".env", Format: FormatShell, Only: []string{}, Exclude: []string{}},
+			wantErr: false,
+		},
+		"format, output and filters": {
+			opts:    []string{"-f", ".env.prod", "--format", "yaml", "-o", "out.yaml", "--only", "DB_*, APP", "--exclude", "DB_PASSWORD"},
+			want:    &ExportOptions{FilePath: ".env.prod", Format: FormatYAML, Output: "out.yaml", Only: []string{"DB_*", "APP"}, Exclude: []string{"DB_PASSWORD"}},
+			wantErr: false,
+		},
+		"unexpected argument": {
+			opts:    []string{"json"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseExportOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
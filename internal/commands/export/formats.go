@@ -0,0 +1,177 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats the variables can be exported to.
+const (
+	FormatJSON       = "json"
+	FormatYAML       = "yaml"
+	FormatShell      = "shell"
+	FormatFish       = "fish"
+	FormatPowerShell = "powershell"
+	FormatDocker     = "docker"
+	FormatSystemd    = "systemd"
+	FormatMakefile   = "makefile"
+	FormatProperties = "properties"
+)
+
+// Formats lists the supported formats.
+var Formats = []string{FormatJSON, FormatYAML, FormatShell, FormatFish, FormatPowerShell, FormatDocker, FormatSystemd, FormatMakefile, FormatProperties}
+
+// render returns the variables of env in format, quoted so the target reads back the exact values.
+func render(format string, env *envfile.Env) (string, error) {
+	switch format {
+	case FormatJSON:
+		return renderJSON(env)
+	case FormatYAML:
+		return renderYAML(env)
+	}
+
+	line, ok := map[string]func(key, value string) (string, error){
+		FormatShell:      shellLine,
+		FormatFish:       fishLine,
+		FormatPowerShell: powerShellLine,
+		FormatDocker:     dockerLine,
+		FormatSystemd:    systemdLine,
+		FormatMakefile:   makefileLine,
+		FormatProperties: propertiesLine,
+	}[format]
+	if !ok {
+		return "", fmt.Errorf("unknown format %q (expected one of %s)", format, strings.Join(Formats, ", "))
+	}
+
+	var b strings.Builder
+	for _, key := range env.Keys {
+		s, err := line(key, env.Values[key])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s + "\n")
+	}
+	return b.String(), nil
+}
+
+func renderJSON(env *envfile.Env) (string, error) {
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, key := range env.Keys {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n  " + jsonString(key) + ": " + jsonString(env.Values[key]))
+	}
+	if len(env.Keys) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+// jsonString returns s as a JSON string, leaving <, > and & unescaped.
+func jsonString(s string) string {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func renderYAML(env *envfile.Env) (string, error) {
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range env.Keys {
+		mapping.Content = append(mapping.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: env.Values[key]},
+		)
+	}
+	if len(mapping.Content) == 0 {
+		return "{}\n", nil
+	}
+	out, err := yaml.Marshal(mapping)
+	if err != nil {
+		return "", fmt.Errorf("error rendering yaml: %w", err)
+	}
+	return string(out), nil
+}
+
+// shellLine uses single quotes, in which POSIX shells expand nothing.
+func shellLine(key, value string) (string, error) {
+	return "export " + key + "='" + strings.ReplaceAll(value, "'", `'\''`) + "'", nil
+}
+
+// fishLine uses single quotes, in which fish only unescapes \' and \\.
+func fishLine(key, value string) (string, error) {
+	value = strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value)
+	return "set -gx " + key + " '" + value + "'", nil
+}
+
+// powerShellLine uses a verbatim string, in which a single quote is escaped by doubling it.
+func powerShellLine(key, value string) (string, error) {
+	return "$env:" + key + " = '" + strings.ReplaceAll(value, "'", "''") + "'", nil
+}
+
+// dockerLine writes the value as is: docker --env-file does not support quoting.
+func dockerLine(key, value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", fmt.Errorf("%s: multi-line values are not supported by docker env files", key)
+	}
+	return key + "=" + value, nil
+}
+
+// systemdLine uses double quotes, in which systemd unescapes \\, \", \$ and \` and keeps newlines.
+func systemdLine(key, value string) (string, error) {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`").Replace(value)
+	return key + `="` + value + `"`, nil
+}
+
+// makefileLine escapes $ and # which make would otherwise expand or treat as a comment.
+func makefileLine(key, value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", fmt.Errorf("%s: multi-line values are not supported by makefiles", key)
+	}
+	value = strings.NewReplacer("$", "$$", "#", `\#`).Replace(value)
+	return "export " + key + " := " + value, nil
+}
+
+// propertiesLine escapes as java.util.Properties reads the file: ISO-8859-1 with \uXXXX escapes.
+func propertiesLine(key, value string) (string, error) {
+	return escapeProperty(key, true) + "=" + escapeProperty(value, false), nil
+}
+
+func escapeProperty(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == ' ' && (isKey || i == 0):
+			b.WriteString(`\ `)
+		case strings.ContainsRune("=:#!", r) && (isKey || i == 0):
+			b.WriteString(`\` + string(r))
+		case r < 0x20 || r > 0x7e:
+			for _, unit := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&b, `\u%04X`, unit)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
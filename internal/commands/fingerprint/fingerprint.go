@@ -0,0 +1,238 @@
+package fingerprint
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+)
+
+// EnvSalt holds the team salt when --salt-file is not given.
+const EnvSalt = "ENVCRAFT_FINGERPRINT_SALT"
+
+// defaultSalt is used without a team salt. Anyone can compute fingerprints with it, so a
+// weak value can be guessed from its fingerprint.
+const defaultSalt = "envcraft fingerprint v1"
+
+// ErrMismatch is returned by --compare when the fingerprints differ.
+var ErrMismatch = errors.New("fingerprints do not match")
+
+// FingerprintOptions holds the options for fingerprinting the values of an env file.
+type FingerprintOptions struct {
+	FilePath string
+	Only     []string
+	SaltFile string
+	JSON     bool
+	Compare  string
+	Keys     crypt.KeyOptions
+}
+
+// FingerprintCmd represents the command for printing and comparing keyed hashes of values.
+type FingerprintCmd struct {
+	Options FingerprintOptions
+	stdout  io.Writer
+}
+
+// Fingerprints is the --json output, which --compare reads back.
+type Fingerprints struct {
+	Version      int               `json:"version"`
+	Salt         string            `json:"salt"`
+	Fingerprints map[string]string `json:"fingerprints"`
+}
+
+func Run(args []string) error {
+	options, err := ParseFingerprintOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewFingerprintCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewFingerprintCmd creates a new FingerprintCmd instance with the specified options.
+func NewFingerprintCmd(options *FingerprintOptions) (*FingerprintCmd, error) {
+	if options.FilePath == "" {
+		return nil, errors.New("file path is required")
+	}
+	if options.JSON && options.Compare != "" {
+		return nil, errors.New("--json and --compare cannot be used together")
+	}
+
+	return &FingerprintCmd{
+		Options: *options,
+		stdout:  os.Stdout,
+	}, nil
+}
+
+// Exec executes the fingerprint command: prints a keyed hash of every value, or compares
+// them with fingerprints saved with --json on another machine.
+func (c *FingerprintCmd) Exec() error {
+	salt, err := c.salt()
+	if err != nil {
+		return err
+	}
+	env, err := envfile.Load(c.Options.FilePath, c.Options.Keys)
+	if err != nil {
+		return err
+	}
+
+	mine := Fingerprints{Version: 1, Salt: saltID(salt), Fingerprints: map[string]string{}}
+	keys := []string{}
+	for _, key := range env.Keys {
+		if len(c.Options.Only) > 0 && !slices.Contains(c.Options.Only, key) {
+			continue
+		}
+		keys = append(keys, key)
+		mine.Fingerprints[key] = fingerprint(salt, key, env.Values[key])
+	}
+	if missing := slices.DeleteFunc(slices.Clone(c.Options.Only), func(key string) bool { return slices.Contains(keys, key) }); len(missing) > 0 {
+		return fmt.Errorf("key not found: %s", strings.Join(missing, ", "))
+	}
+
+	switch {
+	case c.Options.Compare != "":
+		return c.compare(mine, keys)
+	case c.Options.JSON:
+		encoder := json.NewEncoder(c.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(mine)
+	default:
+		width := 0
+		for _, key := range keys {
+			width = max(width, len(key))
+		}
+		for _, key := range keys {
+			fmt.Fprintf(c.stdout, "%-*s  %s\n", width, key, mine.Fingerprints[key])
+		}
+		return nil
+	}
+}
+
+// compare reports the keys whose fingerprints differ from, or are missing in, the saved ones.
+func (c *FingerprintCmd) compare(mine Fingerprints, keys []string) error {
+	data, err := os.ReadFile(c.Options.Compare)
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", c.Options.Compare, err)
+	}
+	var theirs Fingerprints
+	if err := json.Unmarshal(data, &theirs); err != nil {
+		return fmt.Errorf("invalid fingerprint file %s: %w", c.Options.Compare, err)
+	}
+	if theirs.Salt != mine.Salt {
+		return fmt.Errorf("%s was made with a different salt; use the same team salt on both machines", c.Options.Compare)
+	}
+
+	problems := 0
+	for _, key := range keys {
+		fp, ok := theirs.Fingerprints[key]
+		switch {
+		case !ok:
+			fmt.Fprintf(c.stdout, "  - %s: only in %s\n", key, c.Options.FilePath)
+			problems++
+		case fp != mine.Fingerprints[key]:
+			fmt.Fprintf(c.stdout, "  ~ %s: differs\n", key)
+			problems++
+		default:
+			fmt.Fprintf(c.stdout, "  = %s\n", key)
+		}
+	}
+	theirKeys := []string{}
+	for key := range theirs.Fingerprints {
+		if _, ok := mine.Fingerprints[key]; !ok && len(c.Options.Only) == 0 {
+			theirKeys = append(theirKeys, key)
+		}
+	}
+	slices.Sort(theirKeys)
+	for _, key := range theirKeys {
+		fmt.Fprintf(c.stdout, "  + %s: only in %s\n", key, c.Options.Compare)
+		problems++
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%w: %d key(s)", ErrMismatch, problems)
+	}
+	return nil
+}
+
+// salt returns the team salt from --salt-file or the environment, or the default salt with a warning.
+func (c *FingerprintCmd) salt() ([]byte, error) {
+	if c.Options.SaltFile != "" {
+		data, err := os.ReadFile(c.Options.SaltFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading salt file %s: %w", c.Options.SaltFile, err)
+		}
+		return []byte(strings.TrimSpace(string(data))), nil
+	}
+	if salt := os.Getenv(EnvSalt); salt != "" {
+		return []byte(salt), nil
+	}
+	fmt.Fprintf(os.Stderr, "Warning: no team salt set (--salt-file or $%s); fingerprints of weak values can be guessed\n", EnvSalt)
+	return []byte(defaultSalt), nil
+}
+
+// fingerprint returns a keyed hash of the value of key. The key name is part of the hash,
+// so equal values of different keys have different fingerprints.
+func fingerprint(salt []byte, key, value string) string {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(key))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// saltID identifies a salt without revealing it, so fingerprints made with different salts are not compared.
+func saltID(salt []byte) string {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte("envcraft salt id"))
+	return hex.EncodeToString(mac.Sum(nil))[:8]
+}
+
+// ParseFingerprintOptions parses command-line arguments and returns a FingerprintOptions struct.
+// Keys given as arguments limit the fingerprints to those keys.
+func ParseFingerprintOptions(opts []string) (*FingerprintOptions, error) {
+	flagSet := flag.NewFlagSet("fingerprint", flag.ContinueOnError)
+	file := flagSet.String("f", ".env", "Path to the .env file, encrypted or not")
+	saltFile := flagSet.String("salt-file", "", "Path to a file holding the team salt (default $"+EnvSalt+")")
+	jsonOutput := flagSet.Bool("json", false, "Print the fingerprints as JSON, to compare on another machine")
+	compare := flagSet.String("compare", "", "Compare with fingerprints saved with --json")
+
+	var keys crypt.KeyOptions
+	keys.AddFlags(flagSet)
+	keys.AddRecipientFlags(flagSet)
+
+	only := []string{}
+	for len(opts) > 0 && !strings.HasPrefix(opts[0], "-") {
+		only = append(only, opts[0])
+		opts = opts[1:]
+	}
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	only = append(only, flagSet.Args()...)
+
+	return &FingerprintOptions{
+		FilePath: *file,
+		Only:     only,
+		SaltFile: *saltFile,
+		JSON:     *jsonOutput,
+		Compare:  *compare,
+		Keys:     keys,
+	}, nil
+}
@@ -0,0 +1,105 @@
+package fingerprint
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	salt := []byte("team salt")
+	assert.Equal(t, fingerprint(salt, "A", "x"), fingerprint(salt, "A", "x"))
+	assert.NotEqual(t, fingerprint(salt, "A", "x"), fingerprint(salt, "B", "x"))
+	assert.NotEqual(t, fingerprint(salt, "A", "x"), fingerprint([]byte("other"), "A", "x"))
+	assert.Len(t, fingerprint(salt, "A", "x"), 16)
+}
+
+func TestExec_Compare(t *testing.T) {
+	dir := t.TempDir()
+	mineFile, theirFile, saved := filepath.Join(dir, "mine.env"), filepath.Join(dir, "theirs.env"), filepath.Join(dir, "theirs.json")
+	assert.NoError(t, os.WriteFile(mineFile, []byte("DB_PASSWORD=one\nHOST=db\nLOCAL_ONLY=1\n"), 0644))
+	assert.NoError(t, os.WriteFile(theirFile, []byte("DB_PASSWORD=two\nHOST='db'\nCI_ONLY=1\n"), 0644))
+	t.Setenv(EnvSalt, "team salt")
+
+	// Save the fingerprints of the other machine.
+	cmd, err := NewFingerprintCmd(&FingerprintOptions{FilePath: theirFile, JSON: true})
+	assert.NoError(t, err)
+	var out bytes.Buffer
+	cmd.stdout = &out
+	assert.NoError(t, cmd.Exec())
+	assert.NoError(t, os.WriteFile(saved, out.Bytes(), 0644))
+	assert.NotContains(t, out.String(), "two")
+
+	tests := map[string]struct {
+		only    []string
+		want    string
+		wantErr bool
+	}{
+		"all keys": {
+			want:    "  ~ DB_PASSWORD: differs\n  = HOST\n  - LOCAL_ONLY: only in " + mineFile + "\n  + CI_ONLY: only in " + saved + "\n",
+			wantErr: true,
+		},
+		"matching key only": {
+			only:    []string{"HOST"},
+			want:    "  = HOST\n",
+			wantErr: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmd, err := NewFingerprintCmd(&FingerprintOptions{FilePath: mineFile, Only: tt.only, Compare: saved})
+			assert.NoError(t, err)
+			var out bytes.Buffer
+			cmd.stdout = &out
+			err = cmd.Exec()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrMismatch)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+
+	// Fingerprints made with another salt are not compared.
+	t.Setenv(EnvSalt, "other salt")
+	cmd, err = NewFingerprintCmd(&FingerprintOptions{FilePath: mineFile, Compare: saved})
+	assert.NoError(t, err)
+	cmd.stdout = &bytes.Buffer{}
+	assert.ErrorContains(t, cmd.Exec(), "different salt")
+}
+
+func TestParseFingerprintOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *FingerprintOptions
+		wantErr bool
+	}{
+		"defaults": {
+			opts:    []string{},
+			want:    &FingerprintOptions{FilePath: ".env", Only: []string{}},
+			wantErr: false,
+		},
+		"keys and compare": {
+			opts:    []string{"DB_PASSWORD", "-f", ".env.local", "--compare", "ci.json", "--salt-file", "salt"},
+			want:    &FingerprintOptions{FilePath: ".env.local", Only: []string{"DB_PASSWORD"}, Compare: "ci.json", SaltFile: "salt"},
+			wantErr: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseFingerprintOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package gha
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+	"github.com/ba58ajbse/envcraft/internal/sensitive"
+)
+
+// Environment variables naming the files GitHub Actions reads after a step.
+const (
+	EnvGitHubEnv    = "GITHUB_ENV"
+	EnvGitHubOutput = "GITHUB_OUTPUT"
+)
+
+// GhaOptions holds the options for passing an env file to later steps of a GitHub Actions job.
+type GhaOptions struct {
+	FilePath string
+	Outputs  []string
+	Patterns []string
+	Keys     crypt.KeyOptions
+}
+
+// GhaCmd represents the command for writing variables to $GITHUB_ENV and $GITHUB_OUTPUT.
+type GhaCmd struct {
+	Options GhaOptions
+	stdout  io.Writer
+}
+
+func Run(args []string) error {
+	options, err := ParseGhaOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewGhaCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewGhaCmd creates a new GhaCmd instance with the specified options.
+func NewGhaCmd(options *GhaOptions) (*GhaCmd, error) {
+	if options.FilePath == "" {
+		return nil, errors.New("file path is required")
+	}
+
+	return &GhaCmd{
+		Options: *options,
+		stdout:  os.Stdout,
+	}, nil
+}
+
+// Exec executes the gha command: masks every secret value in the job log, then appends all
+// variables to $GITHUB_ENV and the selected ones to $GITHUB_OUTPUT.
+func (c *GhaCmd) Exec() error {
+	envPath := os.Getenv(EnvGitHubEnv)
+	if envPath == "" {
+		return fmt.Errorf("%s is not set (run inside GitHub Actions, or point it at a file to test locally)", EnvGitHubEnv)
+	}
+	outputPath := os.Getenv(EnvGitHubOutput)
+	if len(c.Options.Outputs) > 0 && outputPath == "" {
+		return fmt.Errorf("%s is not set", EnvGitHubOutput)
+	}
+
+	env, err := envfile.Load(c.Options.FilePath, c.Options.Keys)
+	if err != nil {
+		return err
+	}
+
+	// Masks must be registered before the values can show up in the log of a later step.
+	secret := sensitive.Keys(env.Lines, c.Options.Patterns)
+	masked := 0
+	for _, key := range env.Keys {
+		if secret[key] {
+			masked += c.mask(env.Values[key])
+		}
+	}
+
+	var envContent, outputContent strings.Builder
+	outputs := 0
+	for _, key := range env.Keys {
+		entry, err := formatEntry(key, env.Values[key])
+		if err != nil {
+			return err
+		}
+		envContent.WriteString(entry)
+		if c.isOutput(key) {
+			outputContent.WriteString(entry)
+			outputs++
+		}
+	}
+
+	if err := appendFile(envPath, envContent.String()); err != nil {
+		return err
+	}
+	if outputs > 0 {
+		if err := appendFile(outputPath, outputContent.String()); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d variable(s) to %s, %d output(s), masked %d value(s)\n", len(env.Keys), EnvGitHubEnv, outputs, masked)
+	return nil
+}
+
+// mask registers every line of value as a secret, as GitHub matches masks line by line.
+// It returns the number of masks written.
+func (c *GhaCmd) mask(value string) int {
+	count := 0
+	for _, line := range strings.Split(strings.ReplaceAll(value, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fmt.Fprintf(c.stdout, "::add-mask::%s\n", escapeCommand(line))
+		count++
+	}
+	return count
+}
+
+// isOutput reports whether key matches one of the --output keys or glob patterns.
+func (c *GhaCmd) isOutput(key string) bool {
+	return slices.ContainsFunc(c.Options.Outputs, func(pattern string) bool {
+		return lines.MatchKey(pattern, key)
+	})
+}
+
+// formatEntry returns key and value in the syntax of $GITHUB_ENV and $GITHUB_OUTPUT:
+// KEY=value, or a heredoc with a random delimiter for multi-line values.
+func formatEntry(key, value string) (string, error) {
+	if !strings.ContainsAny(value, "\r\n") {
+		return key + "=" + value + "\n", nil
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating delimiter: %w", err)
+	}
+	delimiter := "ghadelimiter_" + hex.EncodeToString(b)
+	return key + "<<" + delimiter + "\n" + value + "\n" + delimiter + "\n", nil
+}
+
+// escapeCommand escapes the characters GitHub treats specially in workflow command data.
+func escapeCommand(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// appendFile appends content to the file GitHub Actions provides for the step.
+func appendFile(filePath, content string) error {
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening file %s: %w", filePath, err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(content); err != nil {
+		return fmt.Errorf("error writing to file %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// ParseGhaOptions parses command-line arguments and returns a GhaOptions struct.
+func ParseGhaOptions(opts []string) (*GhaOptions, error) {
+	flagSet := flag.NewFlagSet("gha", flag.ContinueOnError)
+	file := flagSet.String("f", ".env", "Path to the .env file, encrypted or not")
+	outputs := flagSet.String("output", "", "Comma separated keys or glob patterns to also write to $"+EnvGitHubOutput)
+	patterns := flagSet.String("secret-pattern", strings.Join(sensitive.DefaultPatterns, ","), "Comma separated glob patterns of secret keys to mask, unless annotated with "+sensitive.SecretAnnotation+" or "+sensitive.PublicAnnotation)
+
+	var keys crypt.KeyOptions
+	keys.AddFlags(flagSet)
+	keys.AddRecipientFlags(flagSet)
+
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	if len(flagSet.Args()) > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flagSet.Args(), " "))
+	}
+
+	return &GhaOptions{
+		FilePath: *file,
+		Outputs:  input.SplitList(*outputs),
+		Patterns: input.SplitList(*patterns),
+		Keys:     keys,
+	}, nil
+}
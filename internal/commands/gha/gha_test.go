@@ -0,0 +1,80 @@
+package gha
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/sensitive"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec(t *testing.T) {
+	tmpDir := t.TempDir()
+	envFile := filepath.Join(tmpDir, ".env.ci")
+	githubEnv := filepath.Join(tmpDir, "github_env")
+	githubOutput := filepath.Join(tmpDir, "github_output")
+	assert.NoError(t, os.WriteFile(envFile, []byte("APP_NAME=web\nAPI_TOKEN=t0ken\nPRIVATE_KEY=\"line 1\nline 2\"\n"), 0644))
+	assert.NoError(t, os.WriteFile(githubEnv, []byte("EXISTING=1\n"), 0644))
+	t.Setenv(EnvGitHubEnv, githubEnv)
+	t.Setenv(EnvGitHubOutput, githubOutput)
+
+	cmd, err := NewGhaCmd(&GhaOptions{FilePath: envFile, Outputs: []string{"APP_*"}, Patterns: sensitive.DefaultPatterns})
+	assert.NoError(t, err)
+	var stdout bytes.Buffer
+	cmd.stdout = &stdout
+	assert.NoError(t, cmd.Exec())
+
+	assert.Equal(t, "::add-mask::t0ken\n::add-mask::line 1\n::add-mask::line 2\n", stdout.String())
+
+	content, _ := os.ReadFile(githubEnv)
+	assert.Regexp(t, regexp.MustCompile(`^EXISTING=1\nAPP_NAME=web\nAPI_TOKEN=t0ken\nPRIVATE_KEY<<(ghadelimiter_[0-9a-f]{32})\nline 1\nline 2\n(ghadelimiter_[0-9a-f]{32})\n$`), string(content))
+
+	content, _ = os.ReadFile(githubOutput)
+	assert.Equal(t, "APP_NAME=web\n", string(content))
+}
+
+func TestExec_RequiresGitHubEnv(t *testing.T) {
+	t.Setenv(EnvGitHubEnv, "")
+	cmd, err := NewGhaCmd(&GhaOptions{FilePath: ".env"})
+	assert.NoError(t, err)
+	assert.Error(t, cmd.Exec())
+}
+
+func TestParseGhaOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *GhaOptions
+		wantErr bool
+	}{
+		"defaults": {
+			opts:    []string{},
+			want:    &GhaOptions{FilePath: ".env", Outputs: []string{}, Patterns: sensitive.DefaultPatterns},
+			wantErr: false,
+		},
+		"file and outputs": {
+			opts:    []string{"-f", ".env.ci", "--output", "IMAGE_TAG, APP_*"},
+			want:    &GhaOptions{FilePath: ".env.ci", Outputs: []string{"IMAGE_TAG", "APP_*"}, Patterns: sensitive.DefaultPatterns},
+			wantErr: false,
+		},
+		"unexpected argument": {
+			opts:    []string{"extra"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseGhaOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package git
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/gitexec"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/ba58ajbse/envcraft/internal/sensitive"
+)
+
+// Actions of the git command.
+const (
+	ActionInstallMergeDriver = "install-merge-driver"
+	ActionInstallDiffDriver  = "install-diff-driver"
+	ActionTextconv           = "textconv"
+)
+
+// DriverName is the name envcraft is registered under in git config and .gitattributes.
+const DriverName = "envcraft"
+
+// DefaultPattern selects the env files handled by envcraft in .gitattributes.
+const DefaultPattern = "*.env*"
+
+// GitOptions holds the options for integrating envcraft with git.
+type GitOptions struct {
+	Action   string
+	File     string
+	Pattern  string
+	Global   bool
+	Command  string
+	Patterns []string
+	Keys     crypt.KeyOptions
+}
+
+// GitCmd represents the command for integrating envcraft with git: the drivers git runs
+// for env files and their registration in the git config and .gitattributes.
+type GitCmd struct {
+	Options GitOptions
+	stdout  io.Writer
+	git     func(args ...string) (string, error)
+}
+
+func Run(args []string) error {
+	options, err := ParseGitOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewGitCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewGitCmd creates a new GitCmd instance with the specified options.
+func NewGitCmd(options *GitOptions) (*GitCmd, error) {
+	switch options.Action {
+	case ActionTextconv:
+		if options.File == "" {
+			return nil, errors.New("file path is required")
+		}
+	case ActionInstallMergeDriver, ActionInstallDiffDriver:
+		if options.Pattern == "" {
+			return nil, errors.New("pattern is required")
+		}
+	default:
+		return nil, fmt.Errorf("unknown action %q (expected %s, %s or %s)", options.Action, ActionTextconv, ActionInstallDiffDriver, ActionInstallMergeDriver)
+	}
+
+	return &GitCmd{
+		Options: *options,
+		stdout:  os.Stdout,
+		git:     gitexec.Run,
+	}, nil
+}
+
+// Exec executes the git command for the chosen action.
+func (c *GitCmd) Exec() error {
+	switch c.Options.Action {
+	case ActionTextconv:
+		return c.textconv()
+	case ActionInstallDiffDriver:
+		return c.installDiffDriver()
+	default:
+		return c.installMergeDriver()
+	}
+}
+
+// installMergeDriver registers envcraft merge as the merge driver of the matching files:
+//
+//	[merge "envcraft"]
+//		name = envcraft key-level merge
+//		driver = envcraft merge %O %A %B
+//
+// and in .gitattributes at the root of the repository:
+//
+//	*.env* merge=envcraft
+func (c *GitCmd) installMergeDriver() error {
+	section := "merge." + DriverName
+	if err := c.setConfig(section+".name", "envcraft key-level merge"); err != nil {
+		return err
+	}
+	if err := c.setConfig(section+".driver", c.Options.Command+" merge %O %A %B"); err != nil {
+		return err
+	}
+	return c.addAttribute("merge=" + DriverName)
+}
+
+// installDiffDriver registers envcraft git textconv for the matching files, so that
+// git diff and git log -p show their masked view:
+//
+//	[diff "envcraft"]
+//		textconv = envcraft git textconv
+//		cachetextconv = false
+//
+// and in .gitattributes at the root of the repository:
+//
+//	*.env* diff=envcraft
+//
+// The output is never cached, as git would store decrypted views in the repository.
+func (c *GitCmd) installDiffDriver() error {
+	section := "diff." + DriverName
+	if err := c.setConfig(section+".textconv", c.Options.Command+" git textconv"); err != nil {
+		return err
+	}
+	if err := c.setConfig(section+".cachetextconv", "false"); err != nil {
+		return err
+	}
+	return c.addAttribute("diff=" + DriverName)
+}
+
+// setConfig sets a git config entry in the repository, or globally with --global.
+func (c *GitCmd) setConfig(name, value string) error {
+	args := []string{"config"}
+	if c.Options.Global {
+		args = append(args, "--global")
+	}
+	if _, err := c.git(append(args, name, value)...); err != nil {
+		return err
+	}
+	fmt.Printf("Set git config %s = %s\n", name, value)
+	return nil
+}
+
+// addAttribute adds "PATTERN attribute" to the .gitattributes at the root of the repository
+// unless the line is already there.
+func (c *GitCmd) addAttribute(attribute string) error {
+	root, err := c.git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(strings.TrimSpace(root), ".gitattributes")
+	line := c.Options.Pattern + " " + attribute
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error reading file %s: %w", path, err)
+	}
+	content := string(data)
+	if slices.ContainsFunc(strings.Split(content, "\n"), func(l string) bool { return strings.TrimSpace(l) == line }) {
+		fmt.Printf("%s already contains %s\n", path, line)
+		return nil
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if err := os.WriteFile(path, []byte(content+line+"\n"), 0644); err != nil {
+		return fmt.Errorf("error writing to file %s: %w", path, err)
+	}
+	fmt.Printf("Added %s to %s\n", line, path)
+	return nil
+}
+
+// ParseGitOptions parses command-line arguments and returns a GitOptions struct.
+func ParseGitOptions(opts []string) (*GitOptions, error) {
+	if len(opts) == 0 || strings.HasPrefix(opts[0], "-") {
+		return nil, fmt.Errorf("action is required (%s, %s or %s)", ActionTextconv, ActionInstallDiffDriver, ActionInstallMergeDriver)
+	}
+	action := opts[0]
+	opts = opts[1:]
+
+	flagSet := flag.NewFlagSet("git "+action, flag.ContinueOnError)
+	pattern := flagSet.String("pattern", DefaultPattern, "Pattern of the files in .gitattributes")
+	global := flagSet.Bool("global", false, "Set the git config globally instead of in the repository")
+	command := flagSet.String("command", "envcraft", "Command git runs to invoke envcraft")
+	patterns := flagSet.String("secret-pattern", strings.Join(sensitive.DefaultPatterns, ","), "Comma separated glob patterns of secret keys masked by textconv, unless annotated with "+sensitive.SecretAnnotation+" or "+sensitive.PublicAnnotation)
+
+	var keys crypt.KeyOptions
+	keys.AddFlags(flagSet)
+	keys.AddRecipientFlags(flagSet)
+
+	args := []string{}
+	for len(opts) > 0 && !strings.HasPrefix(opts[0], "-") {
+		args = append(args, opts[0])
+		opts = opts[1:]
+	}
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	args = append(args, flagSet.Args()...)
+
+	file := ""
+	if action == ActionTextconv {
+		if len(args) != 1 {
+			return nil, errors.New("file path is required")
+		}
+		file, args = args[0], args[1:]
+	}
+	if len(args) > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	return &GitOptions{
+		Action:   action,
+		File:     file,
+		Pattern:  *pattern,
+		Global:   *global,
+		Command:  *command,
+		Patterns: input.SplitList(*patterns),
+		Keys:     keys,
+	}, nil
+}
@@ -0,0 +1,216 @@
+package git
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/sensitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGitOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *GitOptions
+		wantErr bool
+	}{
+		"defaults": {
+			opts:    []string{"install-merge-driver"},
+			want:    &GitOptions{Action: ActionInstallMergeDriver, Pattern: DefaultPattern, Command: "envcraft", Patterns: sensitive.DefaultPatterns},
+			wantErr: false,
+		},
+		"global with pattern": {
+			opts:    []string{"install-merge-driver", "--global", "--pattern", ".env.example", "--command", "/usr/local/bin/envcraft"},
+			want:    &GitOptions{Action: ActionInstallMergeDriver, Pattern: ".env.example", Global: true, Command: "/usr/local/bin/envcraft", Patterns: sensitive.DefaultPatterns},
+			wantErr: false,
+		},
+		"textconv with key file": {
+			opts:    []string{"textconv", ".env", "--key-file", "key", "--secret-pattern", "*_URL"},
+			want:    &GitOptions{Action: ActionTextconv, File: ".env", Pattern: DefaultPattern, Command: "envcraft", Patterns: []string{"*_URL"}, Keys: crypt.KeyOptions{KeyFile: "key"}},
+			wantErr: false,
+		},
+		"textconv without file": {
+			opts:    []string{"textconv"},
+			want:    nil,
+			wantErr: true,
+		},
+		"install with file": {
+			opts:    []string{"install-diff-driver", ".env"},
+			want:    nil,
+			wantErr: true,
+		},
+		"missing action": {
+			opts:    []string{"--global"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseGitOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestInstallMergeDriver(t *testing.T) {
+	root := t.TempDir()
+	attributes := filepath.Join(root, ".gitattributes")
+	require.NoError(t, os.WriteFile(attributes, []byte("*.png binary"), 0o644))
+
+	calls := []string{}
+	cmd, err := NewGitCmd(&GitOptions{Action: ActionInstallMergeDriver, Pattern: DefaultPattern, Command: "envcraft"})
+	require.NoError(t, err)
+	cmd.git = func(args ...string) (string, error) {
+		calls = append(calls, strings.Join(args, " "))
+		return root + "\n", nil
+	}
+
+	require.NoError(t, cmd.Exec())
+	require.NoError(t, cmd.Exec())
+
+	assert.Equal(t, []string{
+		"config merge.envcraft.name envcraft key-level merge",
+		"config merge.envcraft.driver envcraft merge %O %A %B",
+		"rev-parse --show-toplevel",
+	}, calls[:3])
+	got, err := os.ReadFile(attributes)
+	require.NoError(t, err)
+	assert.Equal(t, "*.png binary\n*.env* merge=envcraft\n", string(got))
+}
+
+func TestInstallDiffDriver(t *testing.T) {
+	root := t.TempDir()
+
+	calls := []string{}
+	cmd, err := NewGitCmd(&GitOptions{Action: ActionInstallDiffDriver, Pattern: DefaultPattern, Global: true, Command: "envcraft"})
+	require.NoError(t, err)
+	cmd.git = func(args ...string) (string, error) {
+		calls = append(calls, strings.Join(args, " "))
+		return root + "\n", nil
+	}
+
+	require.NoError(t, cmd.Exec())
+
+	assert.Equal(t, []string{
+		"config --global diff.envcraft.textconv envcraft git textconv",
+		"config --global diff.envcraft.cachetextconv false",
+		"rev-parse --show-toplevel",
+	}, calls)
+	got, err := os.ReadFile(filepath.Join(root, ".gitattributes"))
+	require.NoError(t, err)
+	assert.Equal(t, "*.env* diff=envcraft\n", string(got))
+}
+
+func TestTextconv(t *testing.T) {
+	keys := crypt.Keys{KeyFile: []byte("key")}
+	encrypted, err := crypt.NewValueCipher(keys).Encrypt("API_TOKEN", "t0ken")
+	require.NoError(t, err)
+	envelope, err := crypt.Encrypt([]byte("APP_NAME=envcraft\nAPI_TOKEN=t0ken\n"), keys)
+	require.NoError(t, err)
+	macKey, err := keys.MACKey("textconv")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		content string
+		keyFile bool
+		want    string
+	}{
+		"masks secrets": {
+			content: "# app\nAPP_NAME=envcraft\nexport DB_PASSWORD=\"p@ss\"\n",
+			want:    "APP_NAME=\"envcraft\"\nDB_PASSWORD=<secret>\n",
+		},
+		"masks secrets with key": {
+			content: "APP_NAME=envcraft\nDB_PASSWORD=\"p@ss\"\n",
+			keyFile: true,
+			want:    "APP_NAME=\"envcraft\"\nDB_PASSWORD=" + mask(macKey, "DB_PASSWORD", "p@ss") + "\n",
+		},
+		"public annotation": {
+			content: sensitive.PublicAnnotation + "\nPUBLIC_KEY=abc\n",
+			want:    "PUBLIC_KEY=\"abc\"\n",
+		},
+		"decrypts values with key": {
+			content: "API_TOKEN=" + encrypted + "\n",
+			keyFile: true,
+			want:    "API_TOKEN=" + mask(macKey, "API_TOKEN", "t0ken") + "\n",
+		},
+		"encrypted values without key": {
+			content: "API_TOKEN=" + encrypted + "\n",
+			want:    "API_TOKEN=" + mask(nil, "API_TOKEN", encrypted) + "\n",
+		},
+		"decrypts file with key, all keys secret": {
+			content: string(envelope),
+			keyFile: true,
+			want:    "APP_NAME=" + mask(macKey, "APP_NAME", "envcraft") + "\nAPI_TOKEN=" + mask(macKey, "API_TOKEN", "t0ken") + "\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv(crypt.EnvKeyFile, "")
+			t.Setenv(crypt.EnvPassphrase, "")
+			// No default identity either.
+			t.Setenv("XDG_CONFIG_HOME", dir)
+			t.Setenv("HOME", dir)
+			filePath := filepath.Join(dir, ".env")
+			require.NoError(t, os.WriteFile(filePath, []byte(tt.content), 0o600))
+			options := GitOptions{Action: ActionTextconv, File: filePath, Patterns: sensitive.DefaultPatterns}
+			if tt.keyFile {
+				options.Keys.KeyFile = filepath.Join(dir, "key")
+				require.NoError(t, os.WriteFile(options.Keys.KeyFile, keys.KeyFile, 0o600))
+			}
+
+			cmd, err := NewGitCmd(&options)
+			require.NoError(t, err)
+			var stdout bytes.Buffer
+			cmd.stdout = &stdout
+
+			require.NoError(t, cmd.Exec())
+			assert.Equal(t, tt.want, stdout.String())
+		})
+	}
+}
+
+func TestMask(t *testing.T) {
+	encrypted, err := crypt.NewValueCipher(crypt.Keys{KeyFile: []byte("key")}).Encrypt("API_TOKEN", "t0ken")
+	require.NoError(t, err)
+
+	keyed := mask([]byte("key one"), "API_TOKEN", "t0ken")
+	assert.True(t, strings.HasPrefix(keyed, "<secret hmac:"))
+	assert.Equal(t, keyed, mask([]byte("key one"), "API_TOKEN", "t0ken"))
+	assert.NotEqual(t, keyed, mask([]byte("key two"), "API_TOKEN", "t0ken"))
+	assert.NotEqual(t, keyed, mask([]byte("key one"), "API_TOKEN", "t0ken2"))
+	assert.Equal(t, "<secret>", mask(nil, "API_TOKEN", "t0ken"))
+	assert.True(t, strings.HasPrefix(mask(nil, "API_TOKEN", encrypted), "<encrypted sha256:"))
+}
+
+func TestTextconvEncryptedFileWithoutKey(t *testing.T) {
+	t.Setenv(crypt.EnvKeyFile, "")
+	t.Setenv(crypt.EnvPassphrase, "")
+	t.Setenv(crypt.EnvIdentity, filepath.Join(t.TempDir(), "identity"))
+	envelope, err := crypt.Encrypt([]byte("API_TOKEN=t0ken\n"), crypt.Keys{KeyFile: []byte("key")})
+	require.NoError(t, err)
+	filePath := filepath.Join(t.TempDir(), ".env.enc")
+	require.NoError(t, os.WriteFile(filePath, envelope, 0o600))
+
+	cmd, err := NewGitCmd(&GitOptions{Action: ActionTextconv, File: filePath})
+	require.NoError(t, err)
+	var stdout bytes.Buffer
+	cmd.stdout = &stdout
+
+	require.NoError(t, cmd.Exec())
+	assert.Contains(t, stdout.String(), "# envcraft: encrypted file")
+	assert.NotContains(t, stdout.String(), "t0ken")
+}
@@ -0,0 +1,87 @@
+package git
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+	"github.com/ba58ajbse/envcraft/internal/sensitive"
+)
+
+// textconv prints the variables of the file one per line in file order, quoted the same way,
+// with secret values masked. Every variable of an encrypted file is a secret. Encrypted files
+// and values are decrypted first when a key is available without prompting; otherwise they are
+// shown as they are, masked.
+//
+// A mask is an HMAC of the key and value, keyed from the key file, passphrase or identity, so
+// that a diff shows which secrets changed without letting anyone guess them from the hash.
+// Without a key, secrets are only shown as <secret>, and encrypted values by a hash of their
+// ciphertext, which reveals nothing of the value.
+func (c *GitCmd) textconv() error {
+	data, err := os.ReadFile(c.Options.File)
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", c.Options.File, err)
+	}
+
+	keys := c.Options.Keys
+	keys.NoPrompt = true
+	env, err := envfile.Load(c.Options.File, keys)
+	if err != nil {
+		if crypt.IsEncrypted(data) {
+			sum := sha256.Sum256(data)
+			fmt.Fprintf(c.stdout, "# envcraft: encrypted file, no key to decrypt it (%v)\n", err)
+			fmt.Fprintf(c.stdout, "# sha256:%s\n", hex.EncodeToString(sum[:]))
+			return nil
+		}
+		if env, err = envfile.Parse(data); err != nil {
+			return fmt.Errorf("error parsing file %s: %w", c.Options.File, err)
+		}
+	}
+
+	secret := sensitive.Keys(env.Lines, c.Options.Patterns)
+	macKey := maskKey(keys)
+	for _, key := range env.Keys {
+		value := strconv.Quote(env.Values[key])
+		if secret[key] || crypt.IsEncrypted(data) {
+			value = mask(macKey, key, env.Values[key])
+		}
+		fmt.Fprintf(c.stdout, "%s=%s\n", key, value)
+	}
+	return nil
+}
+
+// maskKey returns the key secrets are masked with, or nil if no key is available without prompting.
+func maskKey(options crypt.KeyOptions) []byte {
+	keys, err := options.Load(false)
+	if err != nil {
+		keys = crypt.Keys{}
+	}
+	if identities, err := options.LoadIdentities(); err == nil {
+		keys.Identities = identities
+	}
+	macKey, err := keys.MACKey("textconv")
+	if err != nil {
+		return nil
+	}
+	return macKey
+}
+
+// mask returns the masked form of a secret value: a short HMAC of the key and value keyed with
+// macKey, a hash of the ciphertext of a value left encrypted, or <secret> without a key.
+func mask(macKey []byte, key, value string) string {
+	switch {
+	case crypt.IsEncryptedValue(value):
+		sum := sha256.Sum256([]byte(value))
+		return "<encrypted sha256:" + hex.EncodeToString(sum[:4]) + ">"
+	case macKey == nil:
+		return "<secret>"
+	}
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write([]byte(key + "\x00" + value))
+	return "<secret hmac:" + hex.EncodeToString(mac.Sum(nil)[:6]) + ">"
+}
@@ -0,0 +1,252 @@
+package guard
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/gitexec"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/ba58ajbse/envcraft/internal/sensitive"
+)
+
+// ActionInstall installs the guard as the pre-commit hook of the repository.
+const ActionInstall = "install"
+
+// DefaultAllowlist is the allowlist file at the root of the repository.
+const DefaultAllowlist = ".envcraft-guard-allow"
+
+// hookMarker identifies a pre-commit hook installed by guard install.
+const hookMarker = "# envcraft guard"
+
+var (
+	// ErrBlocked is returned when staged changes have findings that are not allowed.
+	ErrBlocked = errors.New("commit blocked")
+	// ErrHookExists is returned by guard install when another pre-commit hook is installed.
+	ErrHookExists = errors.New("pre-commit hook already exists")
+)
+
+// GuardOptions holds the options for checking staged env files.
+type GuardOptions struct {
+	Action    string
+	Allowlist string
+	Patterns  []string
+	Command   string
+	Force     bool
+}
+
+// GuardCmd represents the command for checking the changes staged in git before a commit:
+// real env files, real values in templates and secrets added to env files block the commit.
+type GuardCmd struct {
+	Options GuardOptions
+	stdout  io.Writer
+	git     func(args ...string) (string, error)
+}
+
+func Run(args []string) error {
+	options, err := ParseGuardOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewGuardCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewGuardCmd creates a new GuardCmd instance with the specified options.
+func NewGuardCmd(options *GuardOptions) (*GuardCmd, error) {
+	if options.Action != "" && options.Action != ActionInstall {
+		return nil, fmt.Errorf("unknown action %q (expected %s)", options.Action, ActionInstall)
+	}
+	if options.Allowlist == "" {
+		return nil, errors.New("allowlist path is required")
+	}
+
+	return &GuardCmd{
+		Options: *options,
+		stdout:  os.Stdout,
+		git:     gitexec.Run,
+	}, nil
+}
+
+// Exec executes the guard command: checks the staged changes, or installs the hook.
+func (c *GuardCmd) Exec() error {
+	if c.Options.Action == ActionInstall {
+		return c.install()
+	}
+
+	root, err := c.git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	root = strings.TrimSpace(root)
+
+	allowlist := c.Options.Allowlist
+	if !filepath.IsAbs(allowlist) {
+		allowlist = filepath.Join(root, allowlist)
+	}
+	allowed, err := readAllowlist(allowlist)
+	if err != nil {
+		return err
+	}
+
+	// The raw staged content: no textconv, which would mask the values to check.
+	diff, err := c.git("-c", "core.quotePath=false", "diff", "--cached", "-U0", "--no-color", "--no-renames", "--no-ext-diff", "--no-textconv")
+	if err != nil {
+		return err
+	}
+
+	blocked := 0
+	for _, file := range parseDiff(diff) {
+		if !isEnvFile(file.path) {
+			continue
+		}
+		content, err := c.git("show", ":"+file.path)
+		if err != nil {
+			return err
+		}
+		for _, finding := range check(file, []byte(content), c.Options.Patterns) {
+			if allowed.allows(finding) {
+				continue
+			}
+			fmt.Fprintln(c.stdout, finding)
+			blocked++
+		}
+	}
+
+	if blocked > 0 {
+		fmt.Fprintf(os.Stderr, "Add FILE or FILE:KEY to %s to allow a finding.\n", allowlist)
+		return fmt.Errorf("%w: %d finding(s) in staged env files", ErrBlocked, blocked)
+	}
+	return nil
+}
+
+// install writes the pre-commit hook running the guard. An existing hook is kept unless
+// it was installed by guard or --force is given.
+func (c *GuardCmd) install() error {
+	hook, err := c.git("rev-parse", "--git-path", "hooks/pre-commit")
+	if err != nil {
+		return err
+	}
+	hook = strings.TrimSpace(hook)
+
+	data, err := os.ReadFile(hook)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error reading file %s: %w", hook, err)
+	}
+	if err == nil && !strings.Contains(string(data), hookMarker) && !c.Options.Force {
+		return fmt.Errorf("%w: %s (add %q to it, or use --force to replace it)", ErrHookExists, hook, c.Options.Command+" guard")
+	}
+
+	script := "#!/bin/sh\n" + hookMarker + ": blocks commits adding secrets to env files.\nexec " + c.Options.Command + " guard\n"
+	if err := os.MkdirAll(filepath.Dir(hook), 0755); err != nil {
+		return fmt.Errorf("error creating directory %s: %w", filepath.Dir(hook), err)
+	}
+	if err := os.WriteFile(hook, []byte(script), 0755); err != nil {
+		return fmt.Errorf("error writing to file %s: %w", hook, err)
+	}
+	// WriteFile keeps the mode of an existing file.
+	if err := os.Chmod(hook, 0755); err != nil {
+		return fmt.Errorf("error writing to file %s: %w", hook, err)
+	}
+	fmt.Printf("Installed pre-commit hook %s\n", hook)
+	return nil
+}
+
+// allowlist holds the entries of the allowlist file: FILE allows every finding in matching
+// files, FILE:KEY only the findings of matching keys. FILE is a glob matched against the path
+// from the root of the repository, or against the base name when it has no slash.
+type allowlist []string
+
+// readAllowlist reads the allowlist file, ignoring blank lines and comments.
+// A missing file allows nothing.
+func readAllowlist(filePath string) (allowlist, error) {
+	file, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return allowlist{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", filePath, err)
+	}
+	defer file.Close()
+
+	entries := allowlist{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry != "" && !strings.HasPrefix(entry, "#") {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", filePath, err)
+	}
+	return entries, nil
+}
+
+// allows reports whether an entry of the allowlist covers finding.
+func (a allowlist) allows(finding Finding) bool {
+	for _, entry := range a {
+		fileGlob, keyGlob, hasKey := strings.Cut(entry, ":")
+		if !matchPath(fileGlob, finding.File) {
+			continue
+		}
+		if !hasKey {
+			return true
+		}
+		if matched, _ := path.Match(keyGlob, finding.Key); matched && finding.Key != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPath(glob, filePath string) bool {
+	name := filePath
+	if !strings.Contains(glob, "/") {
+		name = path.Base(filePath)
+	}
+	matched, _ := path.Match(glob, name)
+	return matched
+}
+
+// ParseGuardOptions parses command-line arguments and returns a GuardOptions struct.
+func ParseGuardOptions(opts []string) (*GuardOptions, error) {
+	action := ""
+	if len(opts) > 0 && !strings.HasPrefix(opts[0], "-") {
+		action, opts = opts[0], opts[1:]
+	}
+
+	flagSet := flag.NewFlagSet(strings.TrimSpace("guard "+action), flag.ContinueOnError)
+	allowlist := flagSet.String("allowlist", DefaultAllowlist, "Path of the allowlist file, relative to the root of the repository")
+	patterns := flagSet.String("secret-pattern", strings.Join(sensitive.DefaultPatterns, ","), "Comma separated glob patterns of secret keys, unless annotated with "+sensitive.SecretAnnotation+" or "+sensitive.PublicAnnotation)
+	command := flagSet.String("command", "envcraft", "Command the hook runs to invoke envcraft")
+	force := flagSet.Bool("force", false, "Replace an existing pre-commit hook")
+
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	if len(flagSet.Args()) > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flagSet.Args(), " "))
+	}
+
+	return &GuardOptions{
+		Action:    action,
+		Allowlist: *allowlist,
+		Patterns:  input.SplitList(*patterns),
+		Command:   *command,
+		Force:     *force,
+	}, nil
+}
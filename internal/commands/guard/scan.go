@@ -0,0 +1,273 @@
+package guard
+
+import (
+	"math"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+	"github.com/ba58ajbse/envcraft/internal/sensitive"
+)
+
+// Rules reported by the guard.
+const (
+	RuleEnvFile     = "env-file"
+	RulePlaceholder = "placeholder"
+	RuleToken       = "token"
+	RuleEntropy     = "entropy"
+)
+
+// Thresholds of the entropy rule, in bits per character. Hex strings use fewer symbols,
+// so a random one scores lower than a random base64 string of the same length.
+const (
+	MinEntropyLength = 20
+	EntropyThreshold = 4.0
+	HexThreshold     = 3.0
+)
+
+// templateSuffixes mark env files that are committed on purpose, such as .env.example.
+var templateSuffixes = []string{"example", "sample", "template", "dist"}
+
+// tokens are the well-known token formats, with the names used in findings.
+var tokens = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"an AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"a GitHub token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"a private key", regexp.MustCompile(`-----BEGIN ([A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----`)},
+}
+
+// placeholder matches the values an env template uses instead of a real one.
+var placeholder = regexp.MustCompile(`(?i)^(<.*>|\$\{.*\}|\{\{.*\}\}|\[.*\]|x+|\*+|\.\.\.|change[-_]?me|replace[-_]?me|your[-_ .].*|.*[-_]here|example.*|placeholder|todo|dummy|secret|password|none|null)$`)
+
+// words splits values into the candidates of the entropy rule, so that a URL is not taken as
+// one random string.
+var words = regexp.MustCompile(`[A-Za-z0-9+/=_-]+`)
+
+// Finding is a reason for blocking the commit. Line is 0 for findings about the whole file.
+type Finding struct {
+	File    string
+	Line    int
+	Key     string
+	Rule    string
+	Message string
+}
+
+func (f Finding) String() string {
+	return f.File + ":" + strconv.Itoa(f.Line) + ": " + f.Message + " (" + f.Rule + ")"
+}
+
+// addedLine is a line added to a staged file, numbered in the staged file.
+type addedLine struct {
+	number int
+	text   string
+}
+
+// stagedFile is a file with added lines in the staged diff.
+type stagedFile struct {
+	path  string
+	added []addedLine
+}
+
+// parseDiff returns the files of a unified diff made with -U0 and their added lines.
+// Deleted files are skipped. A "+++ " line names a file only right after the "--- " line
+// of the header that follows "diff --git", as an added line such as "++ x" reads the same.
+func parseDiff(diff string) []stagedFile {
+	files := []stagedFile{}
+	var current *stagedFile
+	inHeader := false
+	previous := ""
+	number := 0
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			current = nil
+			inHeader = true
+		case inHeader && strings.HasPrefix(line, "+++ ") && strings.HasPrefix(previous, "--- "):
+			inHeader = false
+			name := strings.TrimSuffix(strings.TrimPrefix(line, "+++ "), "\t")
+			if name == "/dev/null" {
+				break
+			}
+			files = append(files, stagedFile{path: strings.TrimPrefix(name, "b/")})
+			current = &files[len(files)-1]
+		case inHeader:
+		case current == nil:
+		case strings.HasPrefix(line, "@@ "):
+			number = hunkStart(line)
+		case strings.HasPrefix(line, "+"):
+			current.added = append(current.added, addedLine{number: number, text: line[1:]})
+			number++
+		case strings.HasPrefix(line, " "):
+			number++
+		}
+		previous = line
+	}
+	return files
+}
+
+// hunkStart returns the first line number in the new file of a hunk header such as
+// "@@ -3,2 +4,5 @@".
+func hunkStart(header string) int {
+	fields := strings.Fields(header)
+	if len(fields) < 3 {
+		return 0
+	}
+	start, _, _ := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+	number, err := strconv.Atoi(start)
+	if err != nil {
+		return 0
+	}
+	return number
+}
+
+// isEnvFile reports whether filePath is an env file: .env, .env.* or *.env.
+func isEnvFile(filePath string) bool {
+	base := path.Base(filePath)
+	return base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env")
+}
+
+// isTemplate reports whether filePath is an env template such as .env.example.
+func isTemplate(filePath string) bool {
+	return slices.ContainsFunc(strings.Split(path.Base(filePath), "."), func(part string) bool {
+		return slices.Contains(templateSuffixes, part)
+	})
+}
+
+// isPlaceholder reports whether value is empty or stands in for a real value.
+func isPlaceholder(value string) bool {
+	return strings.TrimSpace(value) == "" || placeholder.MatchString(strings.TrimSpace(value))
+}
+
+// check returns the findings of a staged env file, given its staged content and added lines.
+//
+//   - A real env file is only allowed encrypted, as a whole or with all its secrets encrypted.
+//   - A template must not give secret keys real values.
+//   - No added line may contain a well-known token or a high-entropy string, unless encrypted.
+func check(file stagedFile, content []byte, patterns []string) []Finding {
+	if crypt.IsEncrypted(content) {
+		return nil
+	}
+	findings := []Finding{}
+	env, err := envfile.Parse(content)
+	if err != nil {
+		return append(findings, Finding{File: file.path, Rule: RuleEnvFile, Message: "cannot parse env file: " + err.Error()})
+	}
+	secret := sensitive.Keys(env.Lines, patterns)
+
+	template := isTemplate(file.path)
+	if !template && !protected(env, secret) {
+		findings = append(findings, Finding{File: file.path, Rule: RuleEnvFile, Message: "real env file staged: commit it encrypted or as a template such as .env.example"})
+	}
+
+	for _, added := range file.added {
+		key, value, ok := assignment(added.text, env)
+		if ok && crypt.IsEncryptedValue(value) {
+			continue
+		}
+		subject := "line"
+		if ok {
+			subject = key
+		} else {
+			value = added.text
+		}
+		finding := Finding{File: file.path, Line: added.number, Key: key}
+
+		if name := token(value); name != "" {
+			finding.Rule, finding.Message = RuleToken, subject+" contains "+name
+		} else if isPlaceholder(value) {
+			continue
+		} else if template && ok && secret[key] {
+			finding.Rule, finding.Message = RulePlaceholder, key+" has a real value in a template, use a placeholder such as <"+strings.ToLower(key)+">"
+		} else if highEntropy(value) {
+			finding.Rule, finding.Message = RuleEntropy, subject+" contains a high-entropy string"
+		} else {
+			continue
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// protected reports whether a real env file is safe to commit: all its secrets are encrypted,
+// and it has at least one.
+func protected(env *envfile.Env, secret map[string]bool) bool {
+	encrypted := false
+	for _, key := range env.Keys {
+		if !secret[key] {
+			continue
+		}
+		if !crypt.IsEncryptedValue(env.Values[key]) {
+			return false
+		}
+		encrypted = true
+	}
+	return encrypted
+}
+
+// assignment returns the key of an added assignment line and its value in the staged file.
+func assignment(text string, env *envfile.Env) (string, string, bool) {
+	key, ok := lines.Key(text)
+	if !ok {
+		return "", "", false
+	}
+	key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+	if value, ok := lines.Value(text); ok {
+		return key, value, true
+	}
+	if value, ok := env.Values[key]; ok {
+		return key, value, true
+	}
+	return key, "", true
+}
+
+// token returns the name of the well-known token format found in value, if any.
+func token(value string) string {
+	for _, t := range tokens {
+		if t.pattern.MatchString(value) {
+			return t.name
+		}
+	}
+	return ""
+}
+
+// highEntropy reports whether value contains a long word that looks random.
+func highEntropy(value string) bool {
+	for _, word := range words.FindAllString(value, -1) {
+		if len(word) < MinEntropyLength {
+			continue
+		}
+		threshold := EntropyThreshold
+		if isHex(word) {
+			threshold = HexThreshold
+		}
+		if entropy(word) >= threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+	bits := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(len(s))
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
+
+func isHex(s string) bool {
+	return strings.Trim(s, "0123456789abcdefABCDEF") == ""
+}
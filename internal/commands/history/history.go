@@ -0,0 +1,158 @@
+package history
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ba58ajbse/envcraft/internal/journal"
+)
+
+// Actions of the history command.
+const (
+	ActionHistory = "history"
+	ActionUndo    = "undo"
+	ActionRedo    = "redo"
+)
+
+// HistoryOptions holds the options for listing, undoing or redoing the journaled operations on a file.
+type HistoryOptions struct {
+	Action   string
+	FilePath string
+}
+
+// HistoryCmd represents the command for browsing the journal of a file and moving back and forth in it.
+type HistoryCmd struct {
+	Options HistoryOptions
+	stdout  io.Writer
+}
+
+// Run lists the journaled operations on a file.
+func Run(args []string) error {
+	return run(ActionHistory, args)
+}
+
+// Undo restores a file to its content before the last journaled operation.
+func Undo(args []string) error {
+	return run(ActionUndo, args)
+}
+
+// Redo applies the last undone operation on a file again.
+func Redo(args []string) error {
+	return run(ActionRedo, args)
+}
+
+func run(action string, args []string) error {
+	options, err := ParseHistoryOptions(action, args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewHistoryCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewHistoryCmd creates a new HistoryCmd instance with the specified options.
+func NewHistoryCmd(options *HistoryOptions) (*HistoryCmd, error) {
+	switch options.Action {
+	case ActionHistory, ActionUndo, ActionRedo:
+	default:
+		return nil, fmt.Errorf("unknown action %q", options.Action)
+	}
+	if options.FilePath == "" {
+		return nil, errors.New("file path is required")
+	}
+
+	return &HistoryCmd{
+		Options: *options,
+		stdout:  os.Stdout,
+	}, nil
+}
+
+// Exec executes the history command for the chosen action.
+func (c *HistoryCmd) Exec() error {
+	j, err := journal.Load(c.Options.FilePath)
+	if err != nil {
+		return err
+	}
+
+	switch c.Options.Action {
+	case ActionUndo:
+		entry, err := j.Undo(c.Options.FilePath)
+		if err != nil {
+			return err
+		}
+		if err := j.Save(c.Options.FilePath); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "Undid %s of %s (%s)\n", entry.Command, c.Options.FilePath, describeKeys(entry.Keys))
+	case ActionRedo:
+		entry, err := j.Redo(c.Options.FilePath)
+		if err != nil {
+			return err
+		}
+		if err := j.Save(c.Options.FilePath); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "Redid %s of %s (%s)\n", entry.Command, c.Options.FilePath, describeKeys(entry.Keys))
+	default:
+		return c.print(j)
+	}
+	return nil
+}
+
+// print lists the operations newest first, marking the ones that have been undone.
+func (c *HistoryCmd) print(j *journal.Journal) error {
+	if len(j.Entries) == 0 {
+		fmt.Fprintf(c.stdout, "No history for %s\n", c.Options.FilePath)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tTIME\tCOMMAND\tKEYS")
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		entry := j.Entries[i]
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s", i+1, entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Command, describeKeys(entry.Keys))
+		if i >= j.Position {
+			fmt.Fprint(w, "\t(undone)")
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+// describeKeys returns the keys touched by an operation as a comma-separated list.
+func describeKeys(keys []string) string {
+	if len(keys) == 0 {
+		return "no keys"
+	}
+	return strings.Join(keys, ", ")
+}
+
+// ParseHistoryOptions parses command-line arguments for the given action and returns a HistoryOptions struct.
+func ParseHistoryOptions(action string, opts []string) (*HistoryOptions, error) {
+	flagSet := flag.NewFlagSet(action, flag.ContinueOnError)
+	file := flagSet.String("f", ".env", "Path to .env file")
+
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	if len(flagSet.Args()) > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flagSet.Args(), " "))
+	}
+
+	return &HistoryOptions{
+		Action:   action,
+		FilePath: *file,
+	}, nil
+}
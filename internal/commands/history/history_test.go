@@ -0,0 +1,93 @@
+package history
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/ba58ajbse/envcraft/internal/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHistoryOptions(t *testing.T) {
+	tests := map[string]struct {
+		action  string
+		opts    []string
+		want    *HistoryOptions
+		wantErr bool
+	}{
+		"defaults": {
+			action:  ActionHistory,
+			opts:    []string{},
+			want:    &HistoryOptions{Action: ActionHistory, FilePath: ".env"},
+			wantErr: false,
+		},
+		"undo file": {
+			action:  ActionUndo,
+			opts:    []string{"-f", "config/.env"},
+			want:    &HistoryOptions{Action: ActionUndo, FilePath: "config/.env"},
+			wantErr: false,
+		},
+		"unexpected argument": {
+			action:  ActionRedo,
+			opts:    []string{"2"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseHistoryOptions(tt.action, tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHistoryExec(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(filePath, []byte("A=1\nB=2\n"), 0o600))
+
+	fs.OnWrite = journal.Recorder("delete")
+	t.Cleanup(func() { fs.OnWrite = nil })
+	require.NoError(t, fs.WriteLines(filePath, []string{"A=1\n"}))
+	fs.OnWrite = journal.Recorder("add")
+	require.NoError(t, fs.WriteLines(filePath, []string{"A=1\n", "C=\"3\"\n"}))
+
+	exec := func(action string) (string, error) {
+		cmd, err := NewHistoryCmd(&HistoryOptions{Action: action, FilePath: filePath})
+		require.NoError(t, err)
+		var out bytes.Buffer
+		cmd.stdout = &out
+		err = cmd.Exec()
+		return out.String(), err
+	}
+	run := func(action string) string {
+		out, err := exec(action)
+		require.NoError(t, err)
+		return out
+	}
+
+	assert.Contains(t, run(ActionUndo), "Undid add")
+	assert.Contains(t, run(ActionUndo), "Undid delete")
+	got, _ := os.ReadFile(filePath)
+	assert.Equal(t, "A=1\nB=2\n", string(got))
+	_, err := exec(ActionUndo)
+	assert.ErrorIs(t, err, journal.ErrNothingToUndo)
+
+	assert.Contains(t, run(ActionRedo), "Redid delete of "+filePath+" (B)")
+	got, _ = os.ReadFile(filePath)
+	assert.Equal(t, "A=1\n", string(got))
+
+	history := run(ActionHistory)
+	assert.Regexp(t, `(?m)^2 .* add +C +\(undone\)$`, history)
+	assert.Regexp(t, `(?m)^1 .* delete +B$`, history)
+}
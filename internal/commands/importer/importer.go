@@ -0,0 +1,271 @@
+package importer
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/envdiff"
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+)
+
+// Conflict strategies for keys that are already set to a different value.
+const (
+	ConflictKeep      = "keep"
+	ConflictOverwrite = "overwrite"
+	ConflictFail      = "fail"
+)
+
+// ErrConflict is returned with --on-conflict fail when an imported key is already set to another value.
+var ErrConflict = errors.New("key already set to a different value")
+
+// ImportOptions holds the options for importing variables into an env file.
+type ImportOptions struct {
+	Input      string
+	Format     string
+	FilePath   string
+	Separator  string
+	OnConflict string
+	Yes        bool
+	DryRun     bool
+}
+
+// ImportCmd represents the command for merging variables from another format into an env file.
+type ImportCmd struct {
+	Options  ImportOptions
+	OrgLines []string
+	confirm  func(prompt string) (bool, error)
+}
+
+func Run(args []string) error {
+	options, err := ParseImportOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewImportCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewImportCmd creates a new ImportCmd instance with the specified options.
+func NewImportCmd(options *ImportOptions) (*ImportCmd, error) {
+	if options.Input == "" {
+		return nil, errors.New("input is required")
+	}
+	if options.FilePath == "" {
+		return nil, errors.New("file path is required")
+	}
+	if !slices.Contains(Formats, options.Format) {
+		return nil, fmt.Errorf("unknown format %q (expected one of %s)", options.Format, strings.Join(Formats, ", "))
+	}
+	if !slices.Contains([]string{ConflictKeep, ConflictOverwrite, ConflictFail}, options.OnConflict) {
+		return nil, fmt.Errorf("unknown conflict strategy %q (expected keep, overwrite or fail)", options.OnConflict)
+	}
+	if options.Input == "-" && !options.Yes && !options.DryRun {
+		return nil, errors.New("--yes or --dry-run is required when reading the input from stdin")
+	}
+
+	return &ImportCmd{
+		Options:  *options,
+		OrgLines: []string{},
+		confirm:  func(prompt string) (bool, error) { return input.Confirm(prompt, false) },
+	}, nil
+}
+
+// Exec executes the import command: parses the input, shows which keys would be added or
+// changed without their values, and merges them into the file once confirmed.
+func (c *ImportCmd) Exec() error {
+	data, err := c.readInput()
+	if err != nil {
+		return err
+	}
+	vars, err := parse(c.Options.Format, data, c.Options.Separator)
+	if err != nil {
+		return err
+	}
+
+	if err := c.readLines(); err != nil {
+		return err
+	}
+
+	newLines, changes, kept, err := c.makeNewLines(vars)
+	if err != nil {
+		return err
+	}
+
+	if len(kept) > 0 {
+		fmt.Printf("Kept existing values of %s\n", strings.Join(kept, ", "))
+	}
+	if len(changes) == 0 {
+		fmt.Println("Nothing to import.")
+		return nil
+	}
+	fmt.Printf("Changes to %s:\n", c.Options.FilePath)
+	envdiff.PrintMasked(os.Stdout, changes)
+
+	if c.Options.DryRun {
+		return nil
+	}
+	if !c.Options.Yes {
+		ok, err := c.confirm("Apply these changes?")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Import cancelled.")
+			return nil
+		}
+	}
+
+	return c.apply(newLines)
+}
+
+// readInput reads the input file, or stdin for -.
+func (c *ImportCmd) readInput() ([]byte, error) {
+	if c.Options.Input == "-" {
+		data, err := io.ReadAll(input.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("error reading stdin: %w", err)
+		}
+		return data, nil
+	}
+	data, err := os.ReadFile(c.Options.Input)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", c.Options.Input, err)
+	}
+	return data, nil
+}
+
+// readLines reads all lines of the env file into OrgLines. A missing file is created on apply.
+func (c *ImportCmd) readLines() error {
+	if _, err := os.Stat(c.Options.FilePath); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	lines, err := fs.ReadLines(c.Options.FilePath)
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", c.Options.FilePath, err)
+	}
+	c.OrgLines = lines
+
+	return nil
+}
+
+// makeNewLines merges vars into the lines of the file. A key imported more than once takes its
+// last value. Keys already set are replaced on their effective line, kept or reported as conflicts.
+func (c *ImportCmd) makeNewLines(vars []Variable) ([]string, []envdiff.Change, []string, error) {
+	order := []string{}
+	values := map[string]string{}
+	for _, v := range vars {
+		if _, ok := values[v.Key]; !ok {
+			order = append(order, v.Key)
+		}
+		values[v.Key] = v.Value
+	}
+
+	newLines := slices.Clone(c.OrgLines)
+	changes := []envdiff.Change{}
+	kept := []string{}
+	conflicts := []string{}
+	appended := []string{}
+	for _, key := range order {
+		value := values[key]
+		quoted, err := lines.Quote(value)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%s: %w", key, err)
+		}
+		indexes := lines.KeyIndexes(c.OrgLines, key)
+		if len(indexes) == 0 {
+			appended = append(appended, key+"="+quoted+"\n")
+			changes = append(changes, envdiff.Change{Kind: envdiff.Added, Key: key, New: value})
+			continue
+		}
+
+		last := indexes[len(indexes)-1]
+		current, _ := lines.Value(c.OrgLines[last])
+		if current == value {
+			continue
+		}
+		switch c.Options.OnConflict {
+		case ConflictKeep:
+			kept = append(kept, key)
+		case ConflictFail:
+			conflicts = append(conflicts, key)
+		case ConflictOverwrite:
+			newLines[last] = key + "=" + quoted + "\n"
+			changes = append(changes, envdiff.Change{Kind: envdiff.Changed, Key: key, Old: current, New: value})
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, nil, nil, fmt.Errorf("%w: %s (use --on-conflict keep or overwrite)", ErrConflict, strings.Join(conflicts, ", "))
+	}
+
+	if lines.IsEmptyOrBlank(newLines) {
+		newLines = []string{}
+	} else if lines.EndsWithoutNewline(newLines) {
+		newLines[len(newLines)-1] += "\n"
+	}
+	newLines = append(newLines, appended...)
+	if !lines.IsEmptyOrBlank(c.OrgLines) {
+		newLines = lines.KeepTrailingNewline(c.OrgLines, newLines)
+	}
+
+	return newLines, changes, kept, nil
+}
+
+// apply writes the new lines to the file, creating it if needed.
+func (c *ImportCmd) apply(newLines []string) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(c.Options.FilePath); err == nil {
+		perm = info.Mode().Perm()
+	}
+	return fs.WriteFileAtomic(c.Options.FilePath, []byte(strings.Join(newLines, "")), perm)
+}
+
+// ParseImportOptions parses command-line arguments and returns an ImportOptions struct.
+func ParseImportOptions(opts []string) (*ImportOptions, error) {
+	flagSet := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := flagSet.String("f", ".env", "Path to the .env file to merge into")
+	format := flagSet.String("format", "", "Input format: "+strings.Join(Formats, ", "))
+	separator := flagSet.String("separator", "__", "Separator joining nested keys, as in DB__HOST")
+	onConflict := flagSet.String("on-conflict", ConflictFail, "What to do with keys already set to another value: keep, overwrite or fail")
+	yes := flagSet.Bool("yes", false, "Apply without asking for confirmation")
+	dryRun := flagSet.Bool("dry-run", false, "Show the changes without applying them")
+
+	args := []string{}
+	if len(opts) > 0 && (opts[0] == "-" || !strings.HasPrefix(opts[0], "-")) {
+		args = append(args, opts[0])
+		opts = opts[1:]
+	}
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	args = append(args, flagSet.Args()...)
+	if len(args) != 1 {
+		return nil, errors.New("usage: envcraft import --format FORMAT INPUT [-f .env] (use - for stdin)")
+	}
+	if *format == "" {
+		return nil, errors.New("--format is required")
+	}
+
+	return &ImportOptions{
+		Input:      args[0],
+		Format:     *format,
+		FilePath:   *file,
+		Separator:  *separator,
+		OnConflict: *onConflict,
+		Yes:        *yes,
+		DryRun:     *dryRun,
+	}, nil
+}
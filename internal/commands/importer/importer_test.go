@@ -0,0 +1,205 @@
+package importer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		format  string
+		data    string
+		want    []Variable
+		wantErr bool
+	}{
+		"json nested": {
+			format: FormatJSON,
+			data:   `{"db": {"host": "localhost", "port": 5432}, "debug": true, "tags": ["a", "b"], "empty": null}`,
+			want: []Variable{
+				{Key: "DB__HOST", Value: "localhost"}, {Key: "DB__PORT", Value: "5432"}, {Key: "DEBUG", Value: "true"},
+				{Key: "TAGS__0", Value: "a"}, {Key: "TAGS__1", Value: "b"}, {Key: "EMPTY", Value: ""},
+			},
+		},
+		"yaml": {
+			format: FormatYAML,
+			data:   "app-name: web\ndb:\n  password: \"s3cr3t\"\n",
+			want:   []Variable{{Key: "APP_NAME", Value: "web"}, {Key: "DB__PASSWORD", Value: "s3cr3t"}},
+		},
+		"shell": {
+			format: FormatShell,
+			data:   "#!/bin/sh\nexport NAME='it is'\nPORT=8080\n",
+			want:   []Variable{{Key: "NAME", Value: "it is"}, {Key: "PORT", Value: "8080"}},
+		},
+		"properties": {
+			format: FormatProperties,
+			data:   "# comment\ndb.host = localhost\ngreeting: caf\\u00E9\nlong=a\\\n    b\n",
+			want:   []Variable{{Key: "DB__HOST", Value: "localhost"}, {Key: "GREETING", Value: "café"}, {Key: "LONG", Value: "ab"}},
+		},
+		"docker inspect": {
+			format: FormatDockerInspect,
+			data:   `[{"Config": {"Env": ["PATH=/usr/bin", "OPTS=a=b"]}}]`,
+			want:   []Variable{{Key: "PATH", Value: "/usr/bin"}, {Key: "OPTS", Value: "a=b"}},
+		},
+		"docker inspect invalid key": {
+			format:  FormatDockerInspect,
+			data:    `[{"Config": {"Env": ["PATH=/usr/bin", "# x=1"]}}]`,
+			wantErr: true,
+		},
+		"docker inspect empty key": {
+			format:  FormatDockerInspect,
+			data:    `[{"Config": {"Env": ["=1"]}}]`,
+			wantErr: true,
+		},
+		"json array at top level": {
+			format:  FormatJSON,
+			data:    `[1, 2]`,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parse(tt.format, []byte(tt.data), "__")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExec_Conflicts(t *testing.T) {
+	tests := map[string]struct {
+		onConflict string
+		want       string
+		wantErr    error
+	}{
+		"keep": {
+			onConflict: ConflictKeep,
+			want:       "# app\nHOST=\"old\"\nPORT=\"80\"\nNEW=\"1\"\n",
+		},
+		"overwrite": {
+			onConflict: ConflictOverwrite,
+			want:       "# app\nHOST=\"new\"\nPORT=\"80\"\nNEW=\"1\"\n",
+		},
+		"fail": {
+			onConflict: ConflictFail,
+			want:       "# app\nHOST=\"old\"\nPORT=\"80\"\n",
+			wantErr:    ErrConflict,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			envFile := filepath.Join(tmpDir, ".env")
+			inputFile := filepath.Join(tmpDir, "input.json")
+			assert.NoError(t, os.WriteFile(envFile, []byte("# app\nHOST=\"old\"\nPORT=\"80\"\n"), 0644))
+			assert.NoError(t, os.WriteFile(inputFile, []byte(`{"host": "new", "port": "80", "new": 1}`), 0644))
+
+			cmd, err := NewImportCmd(&ImportOptions{Input: inputFile, Format: FormatJSON, FilePath: envFile, Separator: "__", OnConflict: tt.onConflict, Yes: true})
+			assert.NoError(t, err)
+			err = cmd.Exec()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			content, _ := os.ReadFile(envFile)
+			assert.Equal(t, tt.want, string(content))
+		})
+	}
+}
+
+func TestExec_ValuesReadBackUnchanged(t *testing.T) {
+	tmpDir := t.TempDir()
+	envFile := filepath.Join(tmpDir, ".env")
+	inputFile := filepath.Join(tmpDir, "input.json")
+	assert.NoError(t, os.WriteFile(envFile, []byte("HOST=\"old\"\n"), 0644))
+	want := map[string]string{
+		"HOST":   "db$HOST",
+		"PW":     "x$A${B}\\",
+		"TABBED": "a\tb",
+		"QUOTED": `say "hi"`,
+		"LINES":  "one\ntwo",
+	}
+	data, err := json.Marshal(want)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(inputFile, data, 0644))
+
+	cmd, err := NewImportCmd(&ImportOptions{Input: inputFile, Format: FormatJSON, FilePath: envFile, OnConflict: ConflictOverwrite, Yes: true})
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+
+	got, err := godotenv.Read(envFile)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestExec_ConfirmAndCreate(t *testing.T) {
+	tmpDir := t.TempDir()
+	envFile := filepath.Join(tmpDir, ".env")
+	inputFile := filepath.Join(tmpDir, "input.yaml")
+	assert.NoError(t, os.WriteFile(inputFile, []byte("db:\n  host: localhost\n"), 0644))
+
+	cmd, err := NewImportCmd(&ImportOptions{Input: inputFile, Format: FormatYAML, FilePath: envFile, Separator: "_", OnConflict: ConflictFail})
+	assert.NoError(t, err)
+
+	cmd.confirm = func(string) (bool, error) { return false, nil }
+	assert.NoError(t, cmd.Exec())
+	assert.NoFileExists(t, envFile)
+
+	cmd.confirm = func(string) (bool, error) { return true, nil }
+	assert.NoError(t, cmd.Exec())
+	content, _ := os.ReadFile(envFile)
+	assert.Equal(t, "DB_HOST=\"localhost\"\n", string(content))
+}
+
+func TestParseImportOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *ImportOptions
+		wantErr bool
+	}{
+		"input first": {
+			opts:    []string{"config.json", "--format", "json", "-f", ".env.local"},
+			want:    &ImportOptions{Input: "config.json", Format: FormatJSON, FilePath: ".env.local", Separator: "__", OnConflict: ConflictFail},
+			wantErr: false,
+		},
+		"stdin with flags first": {
+			opts:    []string{"--format", "docker-inspect", "--on-conflict", "overwrite", "--yes", "-"},
+			want:    &ImportOptions{Input: "-", Format: FormatDockerInspect, FilePath: ".env", Separator: "__", OnConflict: ConflictOverwrite, Yes: true},
+			wantErr: false,
+		},
+		"missing format": {
+			opts:    []string{"config.json"},
+			want:    nil,
+			wantErr: true,
+		},
+		"missing input": {
+			opts:    []string{"--format", "json"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseImportOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats that variables can be imported from.
+const (
+	FormatJSON          = "json"
+	FormatYAML          = "yaml"
+	FormatShell         = "shell"
+	FormatProperties    = "properties"
+	FormatDockerInspect = "docker-inspect"
+)
+
+// Formats lists the supported formats.
+var Formats = []string{FormatJSON, FormatYAML, FormatShell, FormatProperties, FormatDockerInspect}
+
+// invalidKeyChars matches the characters that cannot appear in a variable name.
+var invalidKeyChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// validKey matches the keys that can be written to an env file and read back.
+var validKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Variable is an imported key and value.
+type Variable struct {
+	Key   string
+	Value string
+}
+
+// parse reads the variables of data in format, in the order they appear. Nested keys are joined
+// with separator and every key is turned into an upper-case variable name: db.host becomes DB__HOST.
+func parse(format string, data []byte, separator string) ([]Variable, error) {
+	switch format {
+	case FormatJSON, FormatYAML:
+		// JSON is a subset of YAML, and the YAML node tree keeps the order of the keys.
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", format, err)
+		}
+		if len(doc.Content) == 0 {
+			return []Variable{}, nil
+		}
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("invalid %s: expected an object at the top level", format)
+		}
+		return flatten(root, nil, separator), nil
+	case FormatShell:
+		return parseShell(data)
+	case FormatProperties:
+		return parseProperties(data, separator)
+	case FormatDockerInspect:
+		return parseDockerInspect(data)
+	default:
+		return nil, fmt.Errorf("unknown format %q (expected one of %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// flatten returns the scalars below node, keyed by their path joined with separator.
+// Items of a sequence are keyed by their index.
+func flatten(node *yaml.Node, path []string, separator string) []Variable {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return flatten(node.Alias, path, separator)
+	case yaml.MappingNode:
+		vars := []Variable{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			vars = append(vars, flatten(node.Content[i+1], append(path, node.Content[i].Value), separator)...)
+		}
+		return vars
+	case yaml.SequenceNode:
+		vars := []Variable{}
+		for i, item := range node.Content {
+			vars = append(vars, flatten(item, append(path, strconv.Itoa(i)), separator)...)
+		}
+		return vars
+	default:
+		value := node.Value
+		if node.Tag == "!!null" {
+			value = ""
+		}
+		return []Variable{{Key: variableName(path, separator), Value: value}}
+	}
+}
+
+// variableName joins path with separator into an upper-case variable name.
+func variableName(path []string, separator string) string {
+	parts := make([]string, len(path))
+	for i, part := range path {
+		parts[i] = invalidKeyChars.ReplaceAllString(strings.ToUpper(part), "_")
+	}
+	return strings.Join(parts, separator)
+}
+
+// parseShell reads assignments such as export KEY='value', as written by export --format shell.
+func parseShell(data []byte) ([]Variable, error) {
+	env, err := godotenv.UnmarshalBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shell script: %w", err)
+	}
+	vars := []Variable{}
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "export ")
+		key, _, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if value, ok := env[key]; found && ok && !seen[key] {
+			seen[key] = true
+			vars = append(vars, Variable{Key: key, Value: value})
+		}
+	}
+	return vars, nil
+}
+
+// parseProperties reads a java.util.Properties file. The dots of keys such as db.host
+// separate nested keys.
+func parseProperties(data []byte, separator string) ([]Variable, error) {
+	vars := []Variable{}
+	logical := ""
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		line = strings.TrimLeft(line, " \t\f")
+		if logical == "" && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+		// An odd number of trailing backslashes continues the line.
+		trailing := len(line) - len(strings.TrimRight(line, `\`))
+		if trailing%2 == 1 {
+			logical += line[:len(line)-1]
+			continue
+		}
+		logical += line
+
+		key, value, err := splitProperty(logical)
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, Variable{Key: variableName(strings.Split(key, "."), separator), Value: value})
+		logical = ""
+	}
+	return vars, nil
+}
+
+// splitProperty splits a logical line at the first unescaped =, : or whitespace and unescapes both parts.
+func splitProperty(line string) (string, string, error) {
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.ContainsRune("=: \t\f", rune(line[i])) {
+			end = i
+			break
+		}
+	}
+	key := line[:end]
+	rest := strings.TrimLeft(line[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+
+	key, err := unescapeProperty(key)
+	if err != nil {
+		return "", "", err
+	}
+	value, err := unescapeProperty(rest)
+	if err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+func unescapeProperty(s string) (string, error) {
+	var b strings.Builder
+	var units []uint16
+	flush := func() {
+		if len(units) > 0 {
+			b.WriteString(string(utf16.Decode(units)))
+			units = nil
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			flush()
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			flush()
+			b.WriteByte('\n')
+		case 'r':
+			flush()
+			b.WriteByte('\r')
+		case 't':
+			flush()
+			b.WriteByte('\t')
+		case 'f':
+			flush()
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", errors.New("invalid properties file: malformed \\u escape")
+			}
+			unit, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", errors.New("invalid properties file: malformed \\u escape")
+			}
+			units = append(units, uint16(unit))
+			i += 4
+		default:
+			flush()
+			b.WriteByte(s[i])
+		}
+	}
+	flush()
+	return b.String(), nil
+}
+
+// parseDockerInspect reads Config.Env of the containers or images in docker inspect output.
+// Its keys are taken as they are, so a key that is not a variable name is rejected.
+func parseDockerInspect(data []byte) ([]Variable, error) {
+	var objects []struct {
+		Config struct {
+			Env []string
+		}
+	}
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return nil, fmt.Errorf("invalid docker inspect output: %w", err)
+	}
+	vars := []Variable{}
+	for _, object := range objects {
+		for _, entry := range object.Config.Env {
+			key, value, _ := strings.Cut(entry, "=")
+			if !validKey.MatchString(key) {
+				return nil, fmt.Errorf("invalid docker inspect output: invalid key %q", key)
+			}
+			vars = append(vars, Variable{Key: key, Value: value})
+		}
+	}
+	return vars, nil
+}
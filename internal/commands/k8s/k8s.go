@@ -0,0 +1,258 @@
+package k8s
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+	"github.com/ba58ajbse/envcraft/internal/sensitive"
+)
+
+// Actions of the k8s command.
+const (
+	ActionSecret    = "secret"
+	ActionConfigMap = "configmap"
+	ActionToEnv     = "to-env"
+)
+
+// validKey matches the keys that can be written to an env file.
+var validKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// K8sOptions holds the options for converting between env files and Kubernetes manifests.
+type K8sOptions struct {
+	Action    string
+	FilePath  string
+	Input     string
+	Name      string
+	Namespace string
+	Output    string
+	All       bool
+	Patterns  []string
+	Force     bool
+	Keys      crypt.KeyOptions
+}
+
+// K8sCmd represents the command for generating Secrets and ConfigMaps from an env file and back.
+type K8sCmd struct {
+	Options K8sOptions
+}
+
+func Run(args []string) error {
+	options, err := ParseK8sOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewK8sCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewK8sCmd creates a new K8sCmd instance with the specified options.
+func NewK8sCmd(options *K8sOptions) (*K8sCmd, error) {
+	switch options.Action {
+	case ActionSecret, ActionConfigMap:
+		if options.FilePath == "" {
+			return nil, errors.New("file path is required")
+		}
+		if !validName.MatchString(options.Name) || len(options.Name) > 253 {
+			return nil, fmt.Errorf("invalid name %q: --name must be a lower case DNS subdomain such as app-env", options.Name)
+		}
+	case ActionToEnv:
+		if options.Input == "" {
+			return nil, errors.New("manifest file is required")
+		}
+	default:
+		return nil, fmt.Errorf("unknown action %q", options.Action)
+	}
+
+	return &K8sCmd{
+		Options: *options,
+	}, nil
+}
+
+// Exec executes the k8s command.
+func (c *K8sCmd) Exec() error {
+	if c.Options.Action == ActionToEnv {
+		return c.toEnv()
+	}
+	return c.manifest()
+}
+
+// manifest writes a Secret with the secret keys of the file, or a ConfigMap with the others.
+// With --all every key goes into the one manifest.
+func (c *K8sCmd) manifest() error {
+	env, err := envfile.Load(c.Options.FilePath, c.Options.Keys)
+	if err != nil {
+		return err
+	}
+
+	kind := KindConfigMap
+	if c.Options.Action == ActionSecret {
+		kind = KindSecret
+	}
+	secret := sensitive.Keys(env.Lines, c.Options.Patterns)
+	keys := slices.DeleteFunc(slices.Clone(env.Keys), func(key string) bool {
+		return !c.Options.All && secret[key] != (kind == KindSecret)
+	})
+	if len(keys) == 0 {
+		fmt.Fprintf(os.Stderr, "Warning: no keys of %s belong in a %s\n", c.Options.FilePath, kind)
+	}
+
+	out, err := renderManifest(kind, c.Options.Name, c.Options.Namespace, keys, env.Values)
+	if err != nil {
+		return err
+	}
+
+	if c.Options.Output == "" || c.Options.Output == "-" {
+		_, err := os.Stdout.WriteString(out)
+		return err
+	}
+	// base64 is no protection, so a Secret manifest is only readable by the owner.
+	perm := os.FileMode(0644)
+	if kind == KindSecret {
+		perm = 0600
+	}
+	if err := os.WriteFile(c.Options.Output, []byte(out), perm); err != nil {
+		return fmt.Errorf("error writing to file %s: %w", c.Options.Output, err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s %s with %d key(s) to %s\n", kind, c.Options.Name, len(keys), c.Options.Output)
+
+	return nil
+}
+
+// toEnv writes the entries of the Secrets and ConfigMaps in the manifest file as an env file,
+// annotating the keys that came from a Secret.
+func (c *K8sCmd) toEnv() error {
+	var data []byte
+	var err error
+	if c.Options.Input == "-" {
+		data, err = io.ReadAll(input.Stdin)
+	} else {
+		data, err = os.ReadFile(c.Options.Input)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading manifest %s: %w", c.Options.Input, err)
+	}
+
+	entries, err := parseManifests(data)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no Secret or ConfigMap found in %s", c.Options.Input)
+	}
+
+	// A key defined more than once takes its last value, in the position it first appeared.
+	order := []string{}
+	byKey := map[string]Entry{}
+	for _, entry := range entries {
+		if !validKey.MatchString(entry.Key) {
+			fmt.Fprintf(os.Stderr, "Skipped %s: not a valid variable name\n", entry.Key)
+			continue
+		}
+		if _, ok := byKey[entry.Key]; !ok {
+			order = append(order, entry.Key)
+		}
+		byKey[entry.Key] = entry
+	}
+
+	var b strings.Builder
+	for _, key := range order {
+		if byKey[key].Secret {
+			b.WriteString(sensitive.SecretAnnotation + "\n")
+		}
+		value, err := lines.Quote(byKey[key].Value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		b.WriteString(key + "=" + value + "\n")
+	}
+
+	if c.Options.Output == "" || c.Options.Output == "-" {
+		_, err := os.Stdout.WriteString(b.String())
+		return err
+	}
+	if _, err := os.Stat(c.Options.Output); err == nil && !c.Options.Force {
+		return fmt.Errorf("file %s already exists (use --force to overwrite, or import to merge)", c.Options.Output)
+	}
+	if err := fs.WriteFileAtomic(c.Options.Output, []byte(b.String()), 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d variable(s) to %s\n", len(order), c.Options.Output)
+
+	return nil
+}
+
+// ParseK8sOptions parses command-line arguments and returns a K8sOptions struct.
+func ParseK8sOptions(opts []string) (*K8sOptions, error) {
+	flagSet := flag.NewFlagSet("k8s", flag.ContinueOnError)
+	file := flagSet.String("f", ".env", "Path to the .env file, encrypted or not")
+	name := flagSet.String("name", "", "Name of the Secret or ConfigMap")
+	namespace := flagSet.String("namespace", "", "Namespace of the Secret or ConfigMap (optional)")
+	output := flagSet.String("o", "", "Path to the output file (default stdout)")
+	all := flagSet.Bool("all", false, "Put every key in the manifest instead of splitting secret and non-secret keys")
+	patterns := flagSet.String("secret-pattern", strings.Join(sensitive.DefaultPatterns, ","), "Comma separated glob patterns of secret keys, unless annotated with "+sensitive.SecretAnnotation+" or "+sensitive.PublicAnnotation)
+	force := flagSet.Bool("force", false, "Overwrite an existing output file (to-env only)")
+
+	var keys crypt.KeyOptions
+	keys.AddFlags(flagSet)
+	keys.AddRecipientFlags(flagSet)
+
+	if len(opts) == 0 || strings.HasPrefix(opts[0], "-") {
+		return nil, errors.New("usage: envcraft k8s [secret|configmap|to-env] [flags]")
+	}
+	action := opts[0]
+	if !slices.Contains([]string{ActionSecret, ActionConfigMap, ActionToEnv}, action) {
+		return nil, fmt.Errorf("unknown action %q (expected secret, configmap or to-env)", action)
+	}
+	opts = opts[1:]
+
+	args := []string{}
+	if len(opts) > 0 && (opts[0] == "-" || !strings.HasPrefix(opts[0], "-")) {
+		args = append(args, opts[0])
+		opts = opts[1:]
+	}
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	args = append(args, flagSet.Args()...)
+
+	var manifest string
+	if action == ActionToEnv {
+		if len(args) != 1 {
+			return nil, errors.New("usage: envcraft k8s to-env MANIFEST [-o .env]")
+		}
+		manifest = args[0]
+	} else if len(args) > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	return &K8sOptions{
+		Action:    action,
+		FilePath:  *file,
+		Input:     manifest,
+		Name:      *name,
+		Namespace: *namespace,
+		Output:    *output,
+		All:       *all,
+		Patterns:  input.SplitList(*patterns),
+		Force:     *force,
+		Keys:      keys,
+	}, nil
+}
@@ -0,0 +1,171 @@
+package k8s
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/ba58ajbse/envcraft/internal/sensitive"
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/assert"
+)
+
+const testEnv = `APP_NAME=web
+DB_PASSWORD="p@ss"
+# envcraft:secret
+SESSION_SALT=salt
+`
+
+func TestExec_SplitsSecretAndConfigMap(t *testing.T) {
+	tests := map[string]struct {
+		action string
+		all    bool
+		want   string
+	}{
+		"secret": {
+			action: ActionSecret,
+			want: `apiVersion: v1
+kind: Secret
+metadata:
+  name: app-env
+  namespace: prod
+type: Opaque
+data:
+  DB_PASSWORD: cEBzcw==
+  SESSION_SALT: c2FsdA==
+`,
+		},
+		"configmap": {
+			action: ActionConfigMap,
+			want: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-env
+  namespace: prod
+data:
+  APP_NAME: web
+`,
+		},
+		"configmap with all keys": {
+			action: ActionConfigMap,
+			all:    true,
+			want: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-env
+  namespace: prod
+data:
+  APP_NAME: web
+  DB_PASSWORD: p@ss
+  SESSION_SALT: salt
+`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			envFile := filepath.Join(tmpDir, ".env")
+			output := filepath.Join(tmpDir, "manifest.yaml")
+			assert.NoError(t, os.WriteFile(envFile, []byte(testEnv), 0644))
+
+			cmd, err := NewK8sCmd(&K8sOptions{Action: tt.action, FilePath: envFile, Name: "app-env", Namespace: "prod", Output: output, All: tt.all, Patterns: sensitive.DefaultPatterns})
+			assert.NoError(t, err)
+			assert.NoError(t, cmd.Exec())
+
+			content, err := os.ReadFile(output)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(content))
+		})
+	}
+}
+
+func TestExec_ToEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+	manifest := filepath.Join(tmpDir, "manifest.yaml")
+	output := filepath.Join(tmpDir, ".env")
+	assert.NoError(t, os.WriteFile(manifest, []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  APP_NAME: web
+  config.json: "{}"
+  URL: "http://$HOST\t\\"
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-env
+data:
+  DB_PASSWORD: cEBzcw==
+stringData:
+  TOKEN: "line 1\nline 2"
+`), 0644))
+
+	written := []string{}
+	fs.OnWrite = func(filePath string, before, after []byte) { written = append(written, filePath) }
+	t.Cleanup(func() { fs.OnWrite = nil })
+
+	cmd, err := NewK8sCmd(&K8sOptions{Action: ActionToEnv, Input: manifest, Output: output})
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+	assert.Equal(t, []string{output}, written)
+
+	content, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "APP_NAME=\"web\"\nURL=http://\\$HOST\t\\\n# envcraft:secret\nDB_PASSWORD=\"p@ss\"\n# envcraft:secret\nTOKEN=\"line 1\\nline 2\"\n", string(content))
+	env, err := godotenv.Read(output)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"APP_NAME": "web", "URL": "http://$HOST\t\\", "DB_PASSWORD": "p@ss", "TOKEN": "line 1\nline 2"}, env)
+
+	// An existing file is not overwritten without --force.
+	assert.Error(t, cmd.Exec())
+}
+
+func TestParseK8sOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *K8sOptions
+		wantErr bool
+	}{
+		"secret": {
+			opts:    []string{"secret", "-f", ".env.prod", "--name", "app-env", "--namespace", "x"},
+			want:    &K8sOptions{Action: ActionSecret, FilePath: ".env.prod", Name: "app-env", Namespace: "x", Patterns: sensitive.DefaultPatterns},
+			wantErr: false,
+		},
+		"configmap with patterns": {
+			opts:    []string{"configmap", "--name", "app", "--secret-pattern", "*_PASS, *_TOKEN"},
+			want:    &K8sOptions{Action: ActionConfigMap, FilePath: ".env", Name: "app", Patterns: []string{"*_PASS", "*_TOKEN"}},
+			wantErr: false,
+		},
+		"to-env": {
+			opts:    []string{"to-env", "secret.yaml", "-o", ".env"},
+			want:    &K8sOptions{Action: ActionToEnv, FilePath: ".env", Input: "secret.yaml", Output: ".env", Patterns: sensitive.DefaultPatterns},
+			wantErr: false,
+		},
+		"to-env without manifest": {
+			opts:    []string{"to-env"},
+			want:    nil,
+			wantErr: true,
+		},
+		"unknown action": {
+			opts:    []string{"deployment"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseK8sOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
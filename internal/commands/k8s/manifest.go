@@ -0,0 +1,160 @@
+package k8s
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kinds of manifest generated and read.
+const (
+	KindSecret    = "Secret"
+	KindConfigMap = "ConfigMap"
+)
+
+// validName matches a Kubernetes object name (a DNS subdomain).
+var validName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
+// Entry is a key of a Secret or ConfigMap with its decoded value.
+type Entry struct {
+	Key    string
+	Value  string
+	Secret bool
+}
+
+// renderManifest returns a Secret, with base64-encoded data, or a ConfigMap holding keys.
+func renderManifest(kind, name, namespace string, keys []string, values map[string]string) (string, error) {
+	metadata := mapping("name", scalar(name))
+	if namespace != "" {
+		metadata.Content = append(metadata.Content, scalar("namespace"), scalar(namespace))
+	}
+
+	data := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range keys {
+		value := values[key]
+		if kind == KindSecret {
+			value = base64.StdEncoding.EncodeToString([]byte(value))
+		}
+		data.Content = append(data.Content, scalar(key), scalar(value))
+	}
+	if len(keys) == 0 {
+		data.Style = yaml.FlowStyle
+	}
+
+	manifest := mapping("apiVersion", scalar("v1"), "kind", scalar(kind), "metadata", metadata)
+	if kind == KindSecret {
+		manifest.Content = append(manifest.Content, scalar("type"), scalar("Opaque"))
+	}
+	manifest.Content = append(manifest.Content, scalar("data"), data)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(manifest); err != nil {
+		return "", fmt.Errorf("error rendering manifest: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("error rendering manifest: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// parseManifests returns the entries of every Secret and ConfigMap in data, which may hold
+// several documents or a List, in the order they appear.
+func parseManifests(data []byte) ([]Entry, error) {
+	entries := []Entry{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		docEntries, err := objectEntries(doc.Content[0])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, docEntries...)
+	}
+	return entries, nil
+}
+
+// objectEntries returns the entries of a Secret, a ConfigMap or the items of a List.
+func objectEntries(object *yaml.Node) ([]Entry, error) {
+	entries := []Entry{}
+	switch kind := field(object, "kind").Value; kind {
+	case "List":
+		items := field(object, "items")
+		for _, item := range items.Content {
+			itemEntries, err := objectEntries(item)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, itemEntries...)
+		}
+	case KindConfigMap:
+		for _, pair := range pairs(field(object, "data")) {
+			entries = append(entries, Entry{Key: pair[0], Value: pair[1]})
+		}
+	case KindSecret:
+		for _, pair := range pairs(field(object, "data")) {
+			value, err := base64.StdEncoding.DecodeString(pair[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 value of %s in secret %s: %w", pair[0], field(field(object, "metadata"), "name").Value, err)
+			}
+			entries = append(entries, Entry{Key: pair[0], Value: string(value), Secret: true})
+		}
+		for _, pair := range pairs(field(object, "stringData")) {
+			entries = append(entries, Entry{Key: pair[0], Value: pair[1], Secret: true})
+		}
+	}
+	return entries, nil
+}
+
+// field returns the value of key in a mapping node, or an empty node.
+func field(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return node.Content[i+1]
+			}
+		}
+	}
+	return &yaml.Node{}
+}
+
+// pairs returns the keys and scalar values of a mapping node in order.
+func pairs(node *yaml.Node) [][2]string {
+	result := [][2]string{}
+	if node.Kind != yaml.MappingNode {
+		return result
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		result = append(result, [2]string{node.Content[i].Value, node.Content[i+1].Value})
+	}
+	return result
+}
+
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// mapping returns a mapping node of alternating keys and values.
+func mapping(keysAndValues ...any) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		node.Content = append(node.Content, scalar(keysAndValues[i].(string)), keysAndValues[i+1].(*yaml.Node))
+	}
+	return node
+}
@@ -0,0 +1,146 @@
+package keys
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+)
+
+// Actions of the keys command.
+const (
+	ActionGenerate = "generate"
+	ActionPublic   = "public"
+)
+
+// KeysOptions holds the options for managing X25519 identities.
+type KeysOptions struct {
+	Action   string
+	Identity string
+	Force    bool
+}
+
+// KeysCmd represents the command for generating an identity and printing its recipient.
+type KeysCmd struct {
+	Options KeysOptions
+}
+
+func Run(args []string) error {
+	options, err := ParseKeysOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewKeysCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewKeysCmd creates a new KeysCmd instance with the specified options.
+func NewKeysCmd(options *KeysOptions) (*KeysCmd, error) {
+	if options.Identity == "" {
+		return nil, errors.New("identity path is required")
+	}
+
+	return &KeysCmd{
+		Options: *options,
+	}, nil
+}
+
+// Exec executes the keys command.
+func (c *KeysCmd) Exec() error {
+	switch c.Options.Action {
+	case ActionGenerate:
+		return c.generate()
+	case ActionPublic:
+		return c.public()
+	default:
+		return fmt.Errorf("unknown action %q", c.Options.Action)
+	}
+}
+
+// generate writes a new identity readable only by its owner and prints its recipient,
+// to be added to the recipients file.
+func (c *KeysCmd) generate() error {
+	if _, err := os.Stat(c.Options.Identity); err == nil && !c.Options.Force {
+		return fmt.Errorf("identity file %s already exists (use --force to overwrite)", c.Options.Identity)
+	}
+
+	identity, err := crypt.GenerateIdentity()
+	if err != nil {
+		return err
+	}
+	recipient := crypt.FormatRecipient(identity.PublicKey())
+
+	if err := os.MkdirAll(filepath.Dir(c.Options.Identity), 0700); err != nil {
+		return fmt.Errorf("error creating directory for %s: %w", c.Options.Identity, err)
+	}
+	content := "# recipient: " + recipient + "\n" + crypt.FormatIdentity(identity) + "\n"
+	if err := os.WriteFile(c.Options.Identity, []byte(content), 0600); err != nil {
+		return fmt.Errorf("error writing identity file %s: %w", c.Options.Identity, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Identity written to %s\n", c.Options.Identity)
+	fmt.Println(recipient)
+	return nil
+}
+
+// public prints the recipients of the identities in the identity file.
+func (c *KeysCmd) public() error {
+	data, err := os.ReadFile(c.Options.Identity)
+	if err != nil {
+		return fmt.Errorf("error reading identity file %s: %w", c.Options.Identity, err)
+	}
+	identities, err := crypt.ParseIdentities(data)
+	if err != nil {
+		return err
+	}
+	for _, identity := range identities {
+		fmt.Println(crypt.FormatRecipient(identity.PublicKey()))
+	}
+	return nil
+}
+
+// ParseKeysOptions parses command-line arguments and returns a KeysOptions struct.
+func ParseKeysOptions(opts []string) (*KeysOptions, error) {
+	flagSet := flag.NewFlagSet("keys", flag.ContinueOnError)
+	identity := flagSet.String("identity", "", "Path to the identity file (default $"+crypt.EnvIdentity+" or "+crypt.DefaultIdentityPath()+")")
+	flagSet.StringVar(identity, "o", "", "Alias for --identity")
+	force := flagSet.Bool("force", false, "Overwrite an existing identity file")
+
+	if len(opts) == 0 || strings.HasPrefix(opts[0], "-") {
+		return nil, errors.New("usage: envcraft keys [generate|public] [flags]")
+	}
+	action := opts[0]
+	if action != ActionGenerate && action != ActionPublic {
+		return nil, fmt.Errorf("unknown action %q (expected generate or public)", action)
+	}
+	if err := flagSet.Parse(opts[1:]); err != nil {
+		return nil, err
+	}
+	if len(flagSet.Args()) > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flagSet.Args(), " "))
+	}
+
+	if *identity == "" {
+		*identity = os.Getenv(crypt.EnvIdentity)
+	}
+	if *identity == "" {
+		*identity = crypt.DefaultIdentityPath()
+	}
+
+	return &KeysOptions{
+		Action:   action,
+		Identity: *identity,
+		Force:    *force,
+	}, nil
+}
@@ -0,0 +1,32 @@
+package keys
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec_Generate(t *testing.T) {
+	identityFile := filepath.Join(t.TempDir(), "envcraft", "identity")
+
+	cmd, err := NewKeysCmd(&KeysOptions{Action: ActionGenerate, Identity: identityFile})
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+
+	info, err := os.Stat(identityFile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	data, _ := os.ReadFile(identityFile)
+	identities, err := crypt.ParseIdentities(data)
+	assert.NoError(t, err)
+	assert.Len(t, identities, 1)
+
+	// An existing identity is not overwritten without --force.
+	assert.Error(t, cmd.Exec())
+	cmd.Options.Force = true
+	assert.NoError(t, cmd.Exec())
+}
@@ -0,0 +1,253 @@
+package matrix
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+)
+
+// Output formats of the report.
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatCSV      = "csv"
+)
+
+// States of a key in a file.
+const (
+	StatePresent = "present"
+	StateMissing = "missing"
+	StateEmpty   = "empty"
+	StateDefault = "default" // Same value as in the default file.
+)
+
+// MatrixOptions holds the options for reporting keys across env files.
+type MatrixOptions struct {
+	Files   []string
+	Default string
+	Format  string
+	Keys    crypt.KeyOptions
+}
+
+// MatrixCmd represents the command for printing which keys each env file defines.
+type MatrixCmd struct {
+	Options MatrixOptions
+	stdout  io.Writer
+}
+
+// Row is the state of a key in every file, with a note for keys defined in only one file.
+type Row struct {
+	Key    string
+	States []string
+	Note   string
+}
+
+func Run(args []string) error {
+	options, err := ParseMatrixOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewMatrixCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewMatrixCmd creates a new MatrixCmd instance with the specified options.
+func NewMatrixCmd(options *MatrixOptions) (*MatrixCmd, error) {
+	if len(options.Files) == 0 {
+		return nil, errors.New("at least one file is required")
+	}
+	if options.Default != "" && !slices.Contains(options.Files, options.Default) {
+		return nil, fmt.Errorf("default file %s is not one of the files", options.Default)
+	}
+	if !slices.Contains([]string{FormatText, FormatMarkdown, FormatCSV}, options.Format) {
+		return nil, fmt.Errorf("unknown format %q (expected text, markdown or csv)", options.Format)
+	}
+
+	return &MatrixCmd{
+		Options: *options,
+		stdout:  os.Stdout,
+	}, nil
+}
+
+// Exec executes the matrix command.
+func (c *MatrixCmd) Exec() error {
+	envs := make([]*envfile.Env, len(c.Options.Files))
+	for i, file := range c.Options.Files {
+		env, err := envfile.Load(file, c.Options.Keys)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		envs[i] = env
+	}
+
+	rows := c.rows(envs)
+	switch c.Options.Format {
+	case FormatMarkdown:
+		c.printMarkdown(rows)
+		return nil
+	case FormatCSV:
+		return c.printCSV(rows)
+	default:
+		c.printText(rows)
+		return nil
+	}
+}
+
+// defaultIndex returns the index of the file other files are compared with: the given default,
+// otherwise .env if it is one of the files, otherwise none.
+func (c *MatrixCmd) defaultIndex() int {
+	if c.Options.Default != "" {
+		return slices.Index(c.Options.Files, c.Options.Default)
+	}
+	return slices.IndexFunc(c.Options.Files, func(file string) bool { return filepath.Base(file) == ".env" })
+}
+
+// rows returns a row for every key of any file, sorted by key.
+func (c *MatrixCmd) rows(envs []*envfile.Env) []Row {
+	keys := []string{}
+	for _, env := range envs {
+		for _, key := range env.Keys {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	slices.Sort(keys)
+
+	def := c.defaultIndex()
+	rows := make([]Row, 0, len(keys))
+	for _, key := range keys {
+		row := Row{Key: key, States: make([]string, len(envs))}
+		definedIn := []string{}
+		for i, env := range envs {
+			value, ok := env.Values[key]
+			switch {
+			case !ok:
+				row.States[i] = StateMissing
+				continue
+			case value == "":
+				row.States[i] = StateEmpty
+			case def >= 0 && i != def && value == envs[def].Values[key]:
+				row.States[i] = StateDefault
+			default:
+				row.States[i] = StatePresent
+			}
+			definedIn = append(definedIn, c.Options.Files[i])
+		}
+		if len(definedIn) == 1 && len(envs) > 1 {
+			row.Note = "only in " + definedIn[0]
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// header returns the column titles.
+func (c *MatrixCmd) header() []string {
+	header := append([]string{"KEY"}, c.Options.Files...)
+	if def := c.defaultIndex(); def >= 0 {
+		header[def+1] += " (default)"
+	}
+	return append(header, "NOTE")
+}
+
+func (c *MatrixCmd) printText(rows []Row) {
+	table := [][]string{c.header()}
+	for _, row := range rows {
+		key := row.Key
+		if row.Note != "" {
+			key = "! " + key
+		}
+		table = append(table, slices.Concat([]string{key}, row.States, []string{row.Note}))
+	}
+
+	widths := make([]int, len(table[0]))
+	for _, cells := range table {
+		for i, cell := range cells {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	for _, cells := range table {
+		padded := make([]string, len(cells))
+		for i, cell := range cells {
+			padded[i] = cell + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+		}
+		fmt.Fprintln(c.stdout, strings.TrimRight(strings.Join(padded, "  "), " "))
+	}
+}
+
+func (c *MatrixCmd) printMarkdown(rows []Row) {
+	header := c.header()
+	fmt.Fprintf(c.stdout, "| %s |\n", strings.Join(header, " | "))
+	fmt.Fprintf(c.stdout, "|%s\n", strings.Repeat(" --- |", len(header)))
+	for _, row := range rows {
+		key := "`" + row.Key + "`"
+		if row.Note != "" {
+			key = "**" + key + "**"
+		}
+		cells := slices.Concat([]string{key}, row.States, []string{row.Note})
+		fmt.Fprintf(c.stdout, "| %s |\n", strings.Join(cells, " | "))
+	}
+}
+
+func (c *MatrixCmd) printCSV(rows []Row) error {
+	writer := csv.NewWriter(c.stdout)
+	if err := writer.Write(c.header()); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := writer.Write(slices.Concat([]string{row.Key}, row.States, []string{row.Note})); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ParseMatrixOptions parses command-line arguments and returns a MatrixOptions struct.
+func ParseMatrixOptions(opts []string) (*MatrixOptions, error) {
+	flagSet := flag.NewFlagSet("matrix", flag.ContinueOnError)
+	def := flagSet.String("default", "", "File other files are compared with for same-as-default (default .env if given)")
+	format := flagSet.String("format", FormatText, "Output format: text, markdown or csv")
+
+	var keys crypt.KeyOptions
+	keys.AddFlags(flagSet)
+	keys.AddRecipientFlags(flagSet)
+
+	files := []string{}
+	for len(opts) > 0 && !strings.HasPrefix(opts[0], "-") {
+		files = append(files, opts[0])
+		opts = opts[1:]
+	}
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	files = append(files, flagSet.Args()...)
+	if len(files) == 0 {
+		return nil, errors.New("usage: envcraft matrix FILE... [--format text|markdown|csv] [--default FILE]")
+	}
+
+	return &MatrixOptions{
+		Files:   files,
+		Default: *def,
+		Format:  *format,
+		Keys:    keys,
+	}, nil
+}
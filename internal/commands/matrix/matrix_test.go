@@ -0,0 +1,92 @@
+package matrix
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	assert.NoError(t, os.WriteFile(".env", []byte("HOST=localhost\nPORT=80\nDEBUG=\n"), 0644))
+	assert.NoError(t, os.WriteFile(".env.production", []byte("HOST=example.com\nPORT=80\nSENTRY_DSN=https://sentry\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".env.staging"), []byte("HOST=staging\nDEBUG=true\n"), 0644))
+	files := []string{".env", ".env.production", ".env.staging"}
+
+	tests := map[string]struct {
+		format string
+		want   string
+	}{
+		"text": {
+			format: FormatText,
+			want: "KEY           .env (default)  .env.production  .env.staging  NOTE\n" +
+				"DEBUG         empty           missing          present\n" +
+				"HOST          present         present          present\n" +
+				"PORT          present         default          missing\n" +
+				"! SENTRY_DSN  missing         present          missing       only in .env.production\n",
+		},
+		"markdown": {
+			format: FormatMarkdown,
+			want: "| KEY | .env (default) | .env.production | .env.staging | NOTE |\n" +
+				"| --- | --- | --- | --- | --- |\n" +
+				"| `DEBUG` | empty | missing | present |  |\n" +
+				"| `HOST` | present | present | present |  |\n" +
+				"| `PORT` | present | default | missing |  |\n" +
+				"| **`SENTRY_DSN`** | missing | present | missing | only in .env.production |\n",
+		},
+		"csv": {
+			format: FormatCSV,
+			want: "KEY,.env (default),.env.production,.env.staging,NOTE\n" +
+				"DEBUG,empty,missing,present,\n" +
+				"HOST,present,present,present,\n" +
+				"PORT,present,default,missing,\n" +
+				"SENTRY_DSN,missing,present,missing,only in .env.production\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmd, err := NewMatrixCmd(&MatrixOptions{Files: files, Format: tt.format})
+			assert.NoError(t, err)
+			var stdout bytes.Buffer
+			cmd.stdout = &stdout
+			assert.NoError(t, cmd.Exec())
+			assert.Equal(t, tt.want, stdout.String())
+		})
+	}
+}
+
+func TestParseMatrixOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *MatrixOptions
+		wantErr bool
+	}{
+		"files and format": {
+			opts:    []string{".env", ".env.production", "--format", "csv", "--default", ".env.production"},
+			want:    &MatrixOptions{Files: []string{".env", ".env.production"}, Default: ".env.production", Format: FormatCSV},
+			wantErr: false,
+		},
+		"no files": {
+			opts:    []string{"--format", "csv"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseMatrixOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
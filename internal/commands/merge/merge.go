@@ -0,0 +1,144 @@
+package merge
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/fs"
+)
+
+// ErrConflict is returned when both sides changed the same key differently.
+var ErrConflict = errors.New("merge conflict")
+
+// MergeOptions holds the options for merging two versions of an env file.
+type MergeOptions struct {
+	BaseFile   string
+	OursFile   string
+	TheirsFile string
+	Stdout     bool
+}
+
+// MergeCmd represents the command for merging the variables changed in two versions of an
+// env file since their common ancestor. Like git merge-file, the result replaces ours, which
+// makes the command usable as a git merge driver.
+type MergeCmd struct {
+	Options MergeOptions
+	stdout  io.Writer
+}
+
+func Run(args []string) error {
+	options, err := ParseMergeOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewMergeCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewMergeCmd creates a new MergeCmd instance with the specified options.
+func NewMergeCmd(options *MergeOptions) (*MergeCmd, error) {
+	if options.BaseFile == "" || options.OursFile == "" || options.TheirsFile == "" {
+		return nil, errors.New("base, ours and theirs files are required")
+	}
+
+	return &MergeCmd{
+		Options: *options,
+		stdout:  os.Stdout,
+	}, nil
+}
+
+// Exec executes the merge command: writes the merged file, with conflict markers around
+// conflicting keys, and returns ErrConflict if there are any.
+func (c *MergeCmd) Exec() error {
+	base, err := readLines(c.Options.BaseFile)
+	if err != nil {
+		return err
+	}
+	ours, err := readLines(c.Options.OursFile)
+	if err != nil {
+		return err
+	}
+	theirs, err := readLines(c.Options.TheirsFile)
+	if err != nil {
+		return err
+	}
+
+	merged, conflicts, err := Merge3(base, ours, theirs)
+	if err != nil {
+		return fmt.Errorf("error parsing env files: %w", err)
+	}
+
+	if c.Options.Stdout {
+		fmt.Fprint(c.stdout, strings.Join(merged, ""))
+	} else if err := c.writeOurs(merged); err != nil {
+		return err
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%w in %s: %s", ErrConflict, c.Options.OursFile, strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// writeOurs replaces the ours file with the merged lines, keeping its permissions. It is
+// written in place and skips fs.OnWrite on purpose: as a merge driver, ours is a temporary
+// file of git (.merge_file_*) that git reads back and removes, so journaling it would only
+// leave journals of files that no longer exist. The merged env file itself is written by git.
+func (c *MergeCmd) writeOurs(merged []string) error {
+	info, err := os.Stat(c.Options.OursFile)
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", c.Options.OursFile, err)
+	}
+	if err := os.WriteFile(c.Options.OursFile, []byte(strings.Join(merged, "")), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("error writing to file %s: %w", c.Options.OursFile, err)
+	}
+	return nil
+}
+
+// readLines reads the lines of a version of the file. Git passes an empty base when
+// both sides added the file.
+func readLines(filePath string) ([]string, error) {
+	fileLines, err := fs.ReadLines(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", filePath, err)
+	}
+	return fileLines, nil
+}
+
+// ParseMergeOptions parses command-line arguments and returns a MergeOptions struct.
+func ParseMergeOptions(opts []string) (*MergeOptions, error) {
+	flagSet := flag.NewFlagSet("merge", flag.ContinueOnError)
+	stdout := flagSet.Bool("p", false, "Print the merged file instead of replacing ours")
+
+	args := []string{}
+	for len(opts) > 0 && !strings.HasPrefix(opts[0], "-") {
+		args = append(args, opts[0])
+		opts = opts[1:]
+	}
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	args = append(args, flagSet.Args()...)
+
+	if len(args) != 3 {
+		return nil, errors.New("base, ours and theirs files are required")
+	}
+
+	return &MergeOptions{
+		BaseFile:   args[0],
+		OursFile:   args[1],
+		TheirsFile: args[2],
+		Stdout:     *stdout,
+	}, nil
+}
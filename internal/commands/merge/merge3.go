@@ -0,0 +1,197 @@
+package merge
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/lines"
+	"github.com/joho/godotenv"
+)
+
+// Conflict markers written around the two sides of a conflicting key.
+const (
+	MarkerOurs   = "<<<<<<< ours\n"
+	MarkerSep    = "=======\n"
+	MarkerTheirs = ">>>>>>> theirs\n"
+)
+
+// side is the parsed variables of one version of a file with its lines.
+type side struct {
+	lines  []string
+	values map[string]string
+}
+
+// newSide parses fileLines without expanding references such as ${HOST}, by escaping every $:
+// a key only changes when its own line does, not when a variable it refers to changes.
+func newSide(fileLines []string) (side, error) {
+	values, err := godotenv.Unmarshal(strings.ReplaceAll(strings.Join(fileLines, ""), "$", `\$`))
+	if err != nil {
+		return side{}, err
+	}
+	return side{lines: fileLines, values: values}, nil
+}
+
+// line returns the effective (last) assignment line of key, ending with a newline.
+func (s side) line(key string) string {
+	indexes := lines.KeyIndexes(s.lines, key)
+	if len(indexes) == 0 {
+		return ""
+	}
+	return withNewline(s.lines[indexes[len(indexes)-1]])
+}
+
+// Merge3 merges the variables changed in theirs since base into ours, keeping the lines,
+// comments and order of ours. A key is only in conflict when both sides changed it
+// differently; conflicts are written between markers and their keys returned.
+func Merge3(baseLines, oursLines, theirsLines []string) ([]string, []string, error) {
+	base, err := newSide(baseLines)
+	if err != nil {
+		return nil, nil, err
+	}
+	ours, err := newSide(oursLines)
+	if err != nil {
+		return nil, nil, err
+	}
+	theirs, err := newSide(theirsLines)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys := []string{}
+	for _, values := range []map[string]string{base.values, ours.values, theirs.values} {
+		for key := range values {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	slices.Sort(keys)
+
+	result := slices.Clone(oursLines)
+	conflicts := []string{}
+	inserts := map[string][]string{}
+	replaces := map[string][]string{}
+	for _, key := range keys {
+		b, inBase := base.values[key]
+		o, inOurs := ours.values[key]
+		t, inTheirs := theirs.values[key]
+		oursChanged := inOurs != inBase || o != b
+		theirsChanged := inTheirs != inBase || t != b
+		if !theirsChanged || (inOurs == inTheirs && o == t) {
+			continue
+		}
+
+		indexes := lines.KeyIndexes(result, key)
+		switch {
+		case !oursChanged && !inTheirs:
+			result = removeIndexes(result, indexes)
+		case !oursChanged && inOurs:
+			last := indexes[len(indexes)-1]
+			replaced := theirs.line(key)
+			if !strings.HasSuffix(result[last], "\n") {
+				replaced = strings.TrimSuffix(replaced, "\n")
+			}
+			result[last] = replaced
+		case !oursChanged:
+			inserts[key] = append(commentBlock(theirs.lines, key), theirs.line(key))
+		default:
+			conflicts = append(conflicts, key)
+			block := []string{MarkerOurs, ours.line(key), MarkerSep, theirs.line(key), MarkerTheirs}
+			block = slices.DeleteFunc(block, func(line string) bool { return line == "" })
+			if inOurs {
+				replaces[key] = block
+			} else {
+				inserts[key] = block
+			}
+		}
+	}
+
+	// New keys go next to their neighbours in theirs, or at the end.
+	for _, line := range theirs.lines {
+		key, ok := lines.Key(line)
+		if !ok || inserts[key] == nil {
+			continue
+		}
+		result = insertNearNeighbours(result, theirs.lines, key, inserts[key])
+		delete(inserts, key)
+	}
+
+	// Conflicting lines of ours are replaced last, so that they still anchor new keys.
+	for _, key := range conflicts {
+		if block, ok := replaces[key]; ok {
+			indexes := lines.KeyIndexes(result, key)
+			last := indexes[len(indexes)-1]
+			result = slices.Replace(result, last, last+1, block...)
+		}
+	}
+	return result, conflicts, nil
+}
+
+// insertNearNeighbours inserts block after the last line of the nearest key preceding key in
+// theirsLines that is present in result, else before the nearest following one, else at the end.
+func insertNearNeighbours(result, theirsLines []string, key string, block []string) []string {
+	if lines.IsEmptyOrBlank(result) {
+		return block
+	}
+	indexes := lines.KeyIndexes(theirsLines, key)
+	for i := indexes[0] - 1; i >= 0; i-- {
+		if anchorIndexes := presentIndexes(result, theirsLines[i]); len(anchorIndexes) > 0 {
+			at := anchorIndexes[len(anchorIndexes)-1] + 1
+			if at == len(result) && lines.EndsWithoutNewline(result) {
+				result[len(result)-1] += "\n"
+			}
+			return slices.Insert(result, at, block...)
+		}
+	}
+	for i := indexes[0] + 1; i < len(theirsLines); i++ {
+		if anchorIndexes := presentIndexes(result, theirsLines[i]); len(anchorIndexes) > 0 {
+			return slices.Insert(result, anchorIndexes[0], block...)
+		}
+	}
+	if lines.EndsWithoutNewline(result) {
+		result[len(result)-1] += "\n"
+	}
+	return append(result, block...)
+}
+
+// presentIndexes returns the indexes in result of the key assigned by line, if any.
+func presentIndexes(result []string, line string) []int {
+	key, ok := lines.Key(line)
+	if !ok {
+		return nil
+	}
+	return lines.KeyIndexes(result, key)
+}
+
+// commentBlock returns the comment lines directly above the first line of key.
+func commentBlock(fileLines []string, key string) []string {
+	indexes := lines.KeyIndexes(fileLines, key)
+	start := indexes[0]
+	for start > 0 && strings.HasPrefix(strings.TrimSpace(fileLines[start-1]), "#") {
+		start--
+	}
+	block := []string{}
+	for _, line := range fileLines[start:indexes[0]] {
+		block = append(block, withNewline(line))
+	}
+	return block
+}
+
+// removeIndexes returns fileLines without the lines at indexes.
+func removeIndexes(fileLines []string, indexes []int) []string {
+	kept := []string{}
+	for i, line := range fileLines {
+		if !slices.Contains(indexes, i) {
+			kept = append(kept, line)
+		}
+	}
+	return lines.KeepTrailingNewline(fileLines, kept)
+}
+
+// withNewline returns line ending with a newline.
+func withNewline(line string) string {
+	if line == "" || strings.HasSuffix(line, "\n") {
+		return line
+	}
+	return line + "\n"
+}
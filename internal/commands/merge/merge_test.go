@@ -0,0 +1,190 @@
+package merge
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMergeOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *MergeOptions
+		wantErr bool
+	}{
+		"git merge driver arguments": {
+			opts:    []string{".merge_file_a", ".merge_file_b", ".merge_file_c"},
+			want:    &MergeOptions{BaseFile: ".merge_file_a", OursFile: ".merge_file_b", TheirsFile: ".merge_file_c"},
+			wantErr: false,
+		},
+		"print to stdout": {
+			opts:    []string{"base", "ours", "theirs", "-p"},
+			want:    &MergeOptions{BaseFile: "base", OursFile: "ours", TheirsFile: "theirs", Stdout: true},
+			wantErr: false,
+		},
+		"missing theirs": {
+			opts:    []string{"base", "ours"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseMergeOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMerge3(t *testing.T) {
+	tests := map[string]struct {
+		base          string
+		ours          string
+		theirs        string
+		want          string
+		wantConflicts []string
+	}{
+		"both sides add a key at the end": {
+			base:          "A=1\nB=2\n",
+			ours:          "A=1\nB=2\nC=3\n",
+			theirs:        "A=1\nB=2\n# the D key\nD=4\n",
+			want:          "A=1\nB=2\n# the D key\nD=4\nC=3\n",
+			wantConflicts: []string{},
+		},
+		"added key follows its neighbour": {
+			base:          "A=1\nB=2\n",
+			ours:          "# ours\nA=1\nB=2\n",
+			theirs:        "A=1\nNEW=n\nB=2\n",
+			want:          "# ours\nA=1\nNEW=n\nB=2\n",
+			wantConflicts: []string{},
+		},
+		"changes to different keys": {
+			base:          "A=1\nB=2\nC=3",
+			ours:          "A=10\nB=2\nC=3",
+			theirs:        "A=1\nB=2\nC=30",
+			want:          "A=10\nB=2\nC=30",
+			wantConflicts: []string{},
+		},
+		"deleted on their side": {
+			base:          "A=1\nB=2\n",
+			ours:          "A=1\nB=2\nC=3\n",
+			theirs:        "A=1\n",
+			want:          "A=1\nC=3\n",
+			wantConflicts: []string{},
+		},
+		"same change on both sides": {
+			base:          "A=1\n",
+			ours:          "A=2\n",
+			theirs:        "A=\"2\"\n",
+			want:          "A=2\n",
+			wantConflicts: []string{},
+		},
+		"references are not expanded": {
+			base:          "HOST=a\nURL=${HOST}/x\n",
+			ours:          "HOST=b\nURL=${HOST}/x\n",
+			theirs:        "HOST=a\nURL=${HOST}/y\n",
+			want:          "HOST=b\nURL=${HOST}/y\n",
+			wantConflicts: []string{},
+		},
+		"changed reference": {
+			base:          "URL=${A}/x\n",
+			ours:          "URL=${A}/x\n",
+			theirs:        "URL=${B}/x\n",
+			want:          "URL=${B}/x\n",
+			wantConflicts: []string{},
+		},
+		"literal dollars": {
+			base:          "A='$1'\nB=p$$x\n",
+			ours:          "A='$1'\nB=p$$x\n",
+			theirs:        "A='$2'\nB=p$$y\n",
+			want:          "A='$2'\nB=p$$y\n",
+			wantConflicts: []string{},
+		},
+		"conflicting values": {
+			base:          "A=1\nB=2\n",
+			ours:          "A=2\nB=2\n",
+			theirs:        "A=3\nB=2\n",
+			want:          "<<<<<<< ours\nA=2\n=======\nA=3\n>>>>>>> theirs\nB=2\n",
+			wantConflicts: []string{"A"},
+		},
+		"deleted on our side, changed on theirs": {
+			base:          "A=1\nB=2\n",
+			ours:          "B=2\n",
+			theirs:        "A=3\nB=2\n",
+			want:          "<<<<<<< ours\n=======\nA=3\n>>>>>>> theirs\nB=2\n",
+			wantConflicts: []string{"A"},
+		},
+		"added with different values": {
+			base:          "",
+			ours:          "A=1\n",
+			theirs:        "A=2\n",
+			want:          "<<<<<<< ours\nA=1\n=======\nA=2\n>>>>>>> theirs\n",
+			wantConflicts: []string{"A"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, conflicts, err := Merge3(splitLines(tt.base), splitLines(tt.ours), splitLines(tt.theirs))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, strings.Join(got, ""))
+			assert.Equal(t, tt.wantConflicts, conflicts)
+		})
+	}
+}
+
+func TestExec(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base")
+	ours := filepath.Join(dir, "ours")
+	theirs := filepath.Join(dir, "theirs")
+	require.NoError(t, os.WriteFile(base, []byte("A=1\n"), 0o600))
+	require.NoError(t, os.WriteFile(ours, []byte("A=1\nB=2\n"), 0o640))
+	require.NoError(t, os.WriteFile(theirs, []byte("A=1\nC=3\n"), 0o600))
+
+	// ours is a temporary file of git, which is not journaled.
+	written := []string{}
+	fs.OnWrite = func(filePath string, before, after []byte) { written = append(written, filePath) }
+	t.Cleanup(func() { fs.OnWrite = nil })
+
+	cmd, err := NewMergeCmd(&MergeOptions{BaseFile: base, OursFile: ours, TheirsFile: theirs, Stdout: true})
+	require.NoError(t, err)
+	var out bytes.Buffer
+	cmd.stdout = &out
+	require.NoError(t, cmd.Exec())
+	assert.Equal(t, "A=1\nC=3\nB=2\n", out.String())
+	got, _ := os.ReadFile(ours)
+	assert.Equal(t, "A=1\nB=2\n", string(got))
+
+	require.NoError(t, os.WriteFile(theirs, []byte("A=5\nC=3\n"), 0o600))
+	require.NoError(t, os.WriteFile(ours, []byte("A=4\nB=2\n"), 0o640))
+	cmd, err = NewMergeCmd(&MergeOptions{BaseFile: base, OursFile: ours, TheirsFile: theirs})
+	require.NoError(t, err)
+	assert.ErrorIs(t, cmd.Exec(), ErrConflict)
+	got, _ = os.ReadFile(ours)
+	assert.Equal(t, "<<<<<<< ours\nA=4\n=======\nA=5\n>>>>>>> theirs\nC=3\nB=2\n", string(got))
+	info, _ := os.Stat(ours)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+	assert.Empty(t, written)
+}
+
+// splitLines splits content into lines keeping their newlines, as fs.ReadLines does.
+func splitLines(content string) []string {
+	split := strings.SplitAfter(content, "\n")
+	if len(split) > 1 && split[len(split)-1] == "" {
+		split = split[:len(split)-1]
+	}
+	return split
+}
@@ -0,0 +1,249 @@
+package patch
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Header is the first line of every patch.
+const Header = "# envcraft patch v1"
+
+// Operations of a patch.
+const (
+	OpSet    = "set"
+	OpUnset  = "unset"
+	OpRename = "rename"
+)
+
+// Redacted is written instead of the value of a redacted set.
+const Redacted = "redacted"
+
+// AnyBase is the base of an operation on a redacted key: the key must be set, but its value
+// is not checked, as even a hash of a secret could be guessed from the patch.
+const AnyBase = "*"
+
+// ErrNotPatch is returned when the input does not start with Header.
+var ErrNotPatch = errors.New("not an envcraft patch")
+
+// Op is a key-level change. Base is the hash of the value the change expects to find, AnyBase,
+// or the hash encrypted in a patch with encrypted values; a set without Base expects the key
+// to be absent. The value of a redacted set is left out of the patch and asked for when the
+// patch is applied.
+type Op struct {
+	Name     string
+	Key      string
+	NewKey   string
+	Value    string
+	Redacted bool
+	Base     string
+}
+
+// Patch is the list of changes from one env file to another.
+type Patch struct {
+	Ops []Op
+}
+
+// Hash returns the hash of the value of key recorded as the expected base of an operation.
+func Hash(key, value string) string {
+	sum := sha256.Sum256([]byte(key + "\x00" + value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Create returns the patch from old to new, in key order. A key removed from old whose
+// value appears under exactly one added key is recorded as a rename.
+func Create(old, new map[string]string) *Patch {
+	removed := []string{}
+	added := []string{}
+	ops := []Op{}
+	for key, oldValue := range old {
+		newValue, ok := new[key]
+		switch {
+		case !ok:
+			removed = append(removed, key)
+		case newValue != oldValue:
+			ops = append(ops, Op{Name: OpSet, Key: key, Value: newValue, Base: Hash(key, oldValue)})
+		}
+	}
+	for key := range new {
+		if _, ok := old[key]; !ok {
+			added = append(added, key)
+		}
+	}
+	slices.Sort(removed)
+	slices.Sort(added)
+
+	for _, key := range removed {
+		candidates := []string{}
+		for _, addedKey := range added {
+			if new[addedKey] == old[key] {
+				candidates = append(candidates, addedKey)
+			}
+		}
+		if len(candidates) == 1 {
+			ops = append(ops, Op{Name: OpRename, Key: key, NewKey: candidates[0], Base: Hash(key, old[key])})
+			added = slices.DeleteFunc(added, func(k string) bool { return k == candidates[0] })
+			continue
+		}
+		ops = append(ops, Op{Name: OpUnset, Key: key, Base: Hash(key, old[key])})
+	}
+	for _, key := range added {
+		ops = append(ops, Op{Name: OpSet, Key: key, Value: new[key]})
+	}
+
+	slices.SortStableFunc(ops, func(a, b Op) int { return strings.Compare(a.Key, b.Key) })
+	return &Patch{Ops: ops}
+}
+
+// Write writes the patch in its text form:
+//
+//	# envcraft patch v1
+//	set API_URL "https://api.example.com" base=1f2e3d4c5b6a7988
+//	set NEW_TOKEN redacted
+//	set DB_PASSWORD redacted base=*
+//	unset LEGACY_FLAG base=...
+//	rename OLD_NAME NEW_NAME base=...
+//
+// Values are Go string literals, which Parse reads back exactly. They are quoted for the env
+// file only when applied.
+func (p *Patch) Write(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(Header + "\n")
+	for _, op := range p.Ops {
+		fields := []string{op.Name, op.Key}
+		switch op.Name {
+		case OpSet:
+			if op.Redacted {
+				fields = append(fields, Redacted)
+			} else {
+				fields = append(fields, strconv.Quote(op.Value))
+			}
+		case OpRename:
+			fields = append(fields, op.NewKey)
+		}
+		if op.Base != "" {
+			fields = append(fields, "base="+op.Base)
+		}
+		b.WriteString(strings.Join(fields, " ") + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Parse reads a patch in the form written by Write.
+func Parse(r io.Reader) (*Patch, error) {
+	p := &Patch{Ops: []Op{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	header := false
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if !header {
+			if line == "" {
+				continue
+			}
+			if line != Header {
+				return nil, ErrNotPatch
+			}
+			header = true
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		op, err := parseOp(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		p.Ops = append(p.Ops, op)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading patch: %w", err)
+	}
+	if !header {
+		return nil, ErrNotPatch
+	}
+	return p, nil
+}
+
+// parseOp parses an operation line of a patch.
+func parseOp(line string) (Op, error) {
+	fields, err := splitFields(line)
+	if err != nil {
+		return Op{}, err
+	}
+	if len(fields) < 2 {
+		return Op{}, fmt.Errorf("invalid operation %q", line)
+	}
+	op := Op{Name: fields[0].text, Key: fields[1].text}
+	rest := fields[2:]
+	if len(rest) > 0 && !rest[len(rest)-1].quoted && strings.HasPrefix(rest[len(rest)-1].text, "base=") {
+		op.Base = strings.TrimPrefix(rest[len(rest)-1].text, "base=")
+		rest = rest[:len(rest)-1]
+	}
+
+	switch op.Name {
+	case OpSet:
+		if len(rest) != 1 {
+			return Op{}, fmt.Errorf("set %s requires a value", op.Key)
+		}
+		if !rest[0].quoted && rest[0].text == Redacted {
+			op.Redacted = true
+		} else if rest[0].quoted {
+			op.Value = rest[0].text
+		} else {
+			return Op{}, fmt.Errorf("value of %s must be quoted or %s", op.Key, Redacted)
+		}
+	case OpUnset:
+		if len(rest) != 0 || op.Base == "" {
+			return Op{}, fmt.Errorf("unset %s requires only a base", op.Key)
+		}
+	case OpRename:
+		if len(rest) != 1 || op.Base == "" {
+			return Op{}, fmt.Errorf("rename %s requires a new key and a base", op.Key)
+		}
+		op.NewKey = rest[0].text
+	default:
+		return Op{}, fmt.Errorf("unknown operation %q", op.Name)
+	}
+	return op, nil
+}
+
+// field is a space-separated field of an operation line.
+type field struct {
+	text   string
+	quoted bool
+}
+
+// splitFields splits a line on spaces, reading double-quoted fields as Go string literals.
+func splitFields(line string) ([]field, error) {
+	fields := []field{}
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			return fields, nil
+		}
+		if line[0] == '"' {
+			quoted, err := strconv.QuotedPrefix(line)
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted value in %q", line)
+			}
+			value, _ := strconv.Unquote(quoted)
+			fields = append(fields, field{text: value, quoted: true})
+			line = line[len(quoted):]
+			continue
+		}
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			end = len(line)
+		}
+		fields = append(fields, field{text: line[:end]})
+		line = line[end:]
+	}
+}
@@ -0,0 +1,492 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envdiff"
+	"github.com/ba58ajbse/envcraft/internal/envedit"
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+	"github.com/ba58ajbse/envcraft/internal/sensitive"
+	"github.com/joho/godotenv"
+)
+
+// Actions of the patch command.
+const (
+	ActionCreate = "create"
+	ActionApply  = "apply"
+)
+
+// How the values of a created patch are written.
+const (
+	ValuesAuto      = "auto" // Secret values redacted, others plain.
+	ValuesPlain     = "plain"
+	ValuesRedacted  = "redacted"
+	ValuesEncrypted = "encrypted"
+)
+
+// ValueModes lists the supported --values modes.
+var ValueModes = []string{ValuesAuto, ValuesPlain, ValuesRedacted, ValuesEncrypted}
+
+// ErrConflict is returned when the target file does not match the base a patch expects.
+var ErrConflict = errors.New("patch does not apply")
+
+// PatchOptions holds the options for creating and applying env patches.
+type PatchOptions struct {
+	Action    string
+	OldFile   string
+	NewFile   string
+	PatchFile string
+	FilePath  string
+	Output    string
+	Values    string
+	Patterns  []string
+	Force     bool
+	Yes       bool
+	DryRun    bool
+	Keys      crypt.KeyOptions
+}
+
+// PatchCmd represents the command for recording the key-level changes between two env
+// files as a patch and applying such a patch to another file.
+type PatchCmd struct {
+	Options PatchOptions
+	stdout  io.Writer
+	confirm func(prompt string) (bool, error)
+	prompt  func(prompt string) (string, error)
+	keys    *crypt.Keys
+	editor  *envedit.Editor
+}
+
+func Run(args []string) error {
+	options, err := ParsePatchOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewPatchCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewPatchCmd creates a new PatchCmd instance with the specified options.
+func NewPatchCmd(options *PatchOptions) (*PatchCmd, error) {
+	switch options.Action {
+	case ActionCreate:
+		if options.OldFile == "" || options.NewFile == "" {
+			return nil, errors.New("old and new files are required")
+		}
+		if !slices.Contains(ValueModes, options.Values) {
+			return nil, fmt.Errorf("unknown values mode %q (expected one of %s)", options.Values, strings.Join(ValueModes, ", "))
+		}
+	case ActionApply:
+		if options.PatchFile == "" {
+			return nil, errors.New("patch file is required")
+		}
+		if options.FilePath == "" {
+			return nil, errors.New("file path is required")
+		}
+	default:
+		return nil, fmt.Errorf("unknown action %q (expected create or apply)", options.Action)
+	}
+
+	cmd := &PatchCmd{
+		Options: *options,
+		stdout:  os.Stdout,
+		confirm: func(prompt string) (bool, error) { return input.Confirm(prompt, false) },
+		prompt:  input.PromptHidden,
+	}
+	cmd.editor = &envedit.Editor{LoadKeys: func() (crypt.Keys, error) {
+		keys, err := cmd.loadKeys(false)
+		if err != nil {
+			return crypt.Keys{}, err
+		}
+		return *keys, nil
+	}}
+	return cmd, nil
+}
+
+// Exec executes the patch command for the chosen action.
+func (c *PatchCmd) Exec() error {
+	if c.Options.Action == ActionCreate {
+		return c.create()
+	}
+	return c.apply()
+}
+
+// create writes the patch from the old file to the new one, to stdout or the output file.
+func (c *PatchCmd) create() error {
+	oldEnv, err := envfile.Load(c.Options.OldFile, c.Options.Keys)
+	if err != nil {
+		return err
+	}
+	newEnv, err := envfile.Load(c.Options.NewFile, c.Options.Keys)
+	if err != nil {
+		return err
+	}
+
+	p := Create(oldEnv.Values, newEnv.Values)
+	secret := sensitive.Keys(oldEnv.Lines, c.Options.Patterns)
+	for key, isSecret := range sensitive.Keys(newEnv.Lines, c.Options.Patterns) {
+		secret[key] = secret[key] || isSecret
+	}
+	for i, op := range p.Ops {
+		switch {
+		case c.Options.Values == ValuesRedacted, c.Options.Values == ValuesAuto && (secret[op.Key] || secret[op.NewKey]):
+			if op.Name == OpSet {
+				p.Ops[i].Value = ""
+				p.Ops[i].Redacted = true
+			}
+			if op.Base != "" {
+				p.Ops[i].Base = AnyBase
+			}
+		case c.Options.Values == ValuesEncrypted:
+			if op.Name == OpSet {
+				encrypted, err := c.encrypt(op.Key, op.Value)
+				if err != nil {
+					return err
+				}
+				p.Ops[i].Value = encrypted
+			}
+			if op.Base != "" {
+				encrypted, err := c.encrypt(op.Key, op.Base)
+				if err != nil {
+					return err
+				}
+				p.Ops[i].Base = encrypted
+			}
+		}
+	}
+
+	if c.Options.Output == "" || c.Options.Output == "-" {
+		return p.Write(c.stdout)
+	}
+	var out bytes.Buffer
+	if err := p.Write(&out); err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.Options.Output, out.Bytes(), 0600); err != nil {
+		return fmt.Errorf("error writing to file %s: %w", c.Options.Output, err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d change(s) to %s\n", len(p.Ops), c.Options.Output)
+	return nil
+}
+
+// apply applies the patch to the target file once every operation has been checked against
+// the current values, showing the changed keys without their values first.
+func (c *PatchCmd) apply() error {
+	data, err := os.ReadFile(c.Options.PatchFile)
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", c.Options.PatchFile, err)
+	}
+	p, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.Options.PatchFile, err)
+	}
+
+	info, err := os.Stat(c.Options.FilePath)
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", c.Options.FilePath, err)
+	}
+	orgLines, err := fs.ReadLines(c.Options.FilePath)
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", c.Options.FilePath, err)
+	}
+
+	ops, err := c.check(orgLines, p.Ops)
+	if err != nil {
+		return err
+	}
+	if len(ops) < len(p.Ops) {
+		fmt.Fprintf(c.stdout, "%d change(s) already applied.\n", len(p.Ops)-len(ops))
+	}
+	if len(ops) == 0 {
+		fmt.Fprintln(c.stdout, "Nothing to apply.")
+		return nil
+	}
+
+	newLines := slices.Clone(orgLines)
+	for _, op := range ops {
+		newLines, err = c.applyOp(newLines, op)
+		if err != nil {
+			return err
+		}
+	}
+
+	old, _ := godotenv.Unmarshal(strings.Join(orgLines, ""))
+	new, _ := godotenv.Unmarshal(strings.Join(newLines, ""))
+	fmt.Fprintf(c.stdout, "Changes to %s:\n", c.Options.FilePath)
+	envdiff.PrintMasked(c.stdout, envdiff.Compare(old, new))
+
+	if c.Options.DryRun {
+		return nil
+	}
+	if !c.Options.Yes {
+		ok, err := c.confirm("Apply this patch?")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Fprintln(c.stdout, "Patch cancelled.")
+			return nil
+		}
+	}
+	return fs.WriteFileAtomic(c.Options.FilePath, []byte(strings.Join(newLines, "")), info.Mode().Perm())
+}
+
+// check compares every operation with the current value of its key and returns the ones
+// still to apply. An operation conflicts if the key does not hold the value the patch was
+// created from; with --force, differing values are overwritten anyway.
+func (c *PatchCmd) check(fileLines []string, ops []Op) ([]Op, error) {
+	pending := []Op{}
+	conflicts := []string{}
+	for _, op := range ops {
+		current, exists, err := c.currentValue(fileLines, op.Key)
+		if err != nil {
+			return nil, err
+		}
+		matchesBase := false
+		if exists {
+			if matchesBase, err = c.matchesBase(op, current); err != nil {
+				return nil, err
+			}
+		}
+
+		switch op.Name {
+		case OpSet:
+			if exists && !op.Redacted {
+				value, err := c.patchValue(op)
+				if err != nil {
+					return nil, err
+				}
+				if current == value {
+					continue
+				}
+			}
+			switch {
+			case op.Base == "" && exists:
+				conflicts = append(conflicts, fmt.Sprintf("set %s: key already exists", op.Key))
+			case op.Base != "" && !exists:
+				conflicts = append(conflicts, fmt.Sprintf("set %s: key not found", op.Key))
+			case op.Base != "" && !matchesBase:
+				conflicts = append(conflicts, fmt.Sprintf("set %s: current value differs from the patch base", op.Key))
+			default:
+				pending = append(pending, op)
+				continue
+			}
+			if c.Options.Force {
+				pending = append(pending, op)
+			}
+
+		case OpUnset:
+			switch {
+			case !exists:
+			case !matchesBase && !c.Options.Force:
+				conflicts = append(conflicts, fmt.Sprintf("unset %s: current value differs from the patch base", op.Key))
+			default:
+				pending = append(pending, op)
+			}
+
+		case OpRename:
+			newExists := len(lines.KeyIndexes(fileLines, op.NewKey)) > 0
+			switch {
+			case !exists && newExists:
+			case !exists:
+				conflicts = append(conflicts, fmt.Sprintf("rename %s: key not found", op.Key))
+			case newExists:
+				conflicts = append(conflicts, fmt.Sprintf("rename %s: key %s already exists", op.Key, op.NewKey))
+			case !matchesBase && !c.Options.Force:
+				conflicts = append(conflicts, fmt.Sprintf("rename %s: current value differs from the patch base", op.Key))
+			default:
+				pending = append(pending, op)
+			}
+		}
+	}
+	if len(conflicts) > 0 && !c.Options.Force {
+		return nil, fmt.Errorf("%w to %s (use --force to overwrite differing values):\n  %s", ErrConflict, c.Options.FilePath, strings.Join(conflicts, "\n  "))
+	}
+	if len(conflicts) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: forcing over conflicts:\n  %s\n", strings.Join(conflicts, "\n  "))
+	}
+	return pending, nil
+}
+
+// matchesBase reports whether current is the value the operation expects to find.
+func (c *PatchCmd) matchesBase(op Op, current string) (bool, error) {
+	base := op.Base
+	switch {
+	case base == AnyBase:
+		return true, nil
+	case crypt.IsEncryptedValue(base):
+		keys, err := c.loadKeys(false)
+		if err != nil {
+			return false, err
+		}
+		if base, err = crypt.NewValueCipher(*keys).Decrypt(op.Key, base); err != nil {
+			return false, err
+		}
+	}
+	return Hash(op.Key, current) == base, nil
+}
+
+// currentValue returns the decrypted value of key in the file and whether it is set.
+func (c *PatchCmd) currentValue(fileLines []string, key string) (string, bool, error) {
+	indexes, err := lines.SelectOccurrences(key, lines.KeyIndexes(fileLines, key), "")
+	if errors.Is(err, lines.ErrAmbiguousKey) {
+		return "", false, fmt.Errorf("%w (use dedupe to keep one definition)", err)
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if len(indexes) == 0 {
+		return "", false, nil
+	}
+	value, _ := lines.Value(fileLines[indexes[0]])
+	if crypt.IsEncryptedValue(value) {
+		keys, err := c.loadKeys(false)
+		if err != nil {
+			return "", false, err
+		}
+		value, err = crypt.NewValueCipher(*keys).Decrypt(key, value)
+		if err != nil {
+			return "", false, err
+		}
+	}
+	return value, true, nil
+}
+
+// patchValue returns the plaintext value of a set, decrypting it if the patch holds it encrypted.
+func (c *PatchCmd) patchValue(op Op) (string, error) {
+	if !crypt.IsEncryptedValue(op.Value) {
+		return op.Value, nil
+	}
+	keys, err := c.loadKeys(false)
+	if err != nil {
+		return "", err
+	}
+	return crypt.NewValueCipher(*keys).Decrypt(op.Key, op.Value)
+}
+
+// applyOp returns the lines of the file after the operation. Values replacing an encrypted
+// value are encrypted; the values of redacted sets are asked for, except for a dry run.
+func (c *PatchCmd) applyOp(fileLines []string, op Op) ([]string, error) {
+	switch op.Name {
+	case OpSet:
+		value := op.Value
+		var err error
+		switch {
+		case op.Redacted && c.Options.DryRun:
+			value = "<" + Redacted + ">"
+		case op.Redacted:
+			value, err = c.prompt(fmt.Sprintf("Value for %s: ", op.Key))
+		default:
+			value, err = c.patchValue(op)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return c.editor.Set(fileLines, op.Key, value)
+	case OpUnset:
+		return envedit.Unset(fileLines, op.Key), nil
+	case OpRename:
+		return c.editor.Rename(fileLines, op.Key, op.NewKey)
+	}
+	return nil, fmt.Errorf("unknown operation %q", op.Name)
+}
+
+// encrypt returns the value encrypted for key.
+func (c *PatchCmd) encrypt(key, value string) (string, error) {
+	keys, err := c.loadKeys(true)
+	if err != nil {
+		return "", err
+	}
+	return crypt.NewValueCipher(*keys).Encrypt(key, value)
+}
+
+// loadKeys loads the keys once, the first time an operation needs them.
+func (c *PatchCmd) loadKeys(confirm bool) (*crypt.Keys, error) {
+	if c.keys == nil {
+		keys, err := c.Options.Keys.Load(confirm)
+		if err != nil {
+			return nil, err
+		}
+		c.keys = &keys
+	}
+	return c.keys, nil
+}
+
+// ParsePatchOptions parses command-line arguments and returns a PatchOptions struct.
+func ParsePatchOptions(opts []string) (*PatchOptions, error) {
+	if len(opts) == 0 || strings.HasPrefix(opts[0], "-") {
+		return nil, errors.New("action is required (create or apply)")
+	}
+	action := opts[0]
+	opts = opts[1:]
+
+	flagSet := flag.NewFlagSet("patch "+action, flag.ContinueOnError)
+	file := flagSet.String("f", "", "Path to the .env file to apply the patch to")
+	output := flagSet.String("o", "", "Write the patch to this file instead of stdout")
+	values := flagSet.String("values", ValuesAuto, "How values are written: auto (secrets redacted), plain, redacted or encrypted; the current values of redacted keys are not checked when applying")
+	patterns := flagSet.String("secret-pattern", strings.Join(sensitive.DefaultPatterns, ","), "Comma separated glob patterns of secret keys redacted with --values auto, unless annotated with "+sensitive.SecretAnnotation+" or "+sensitive.PublicAnnotation)
+	force := flagSet.Bool("force", false, "Apply even where the current value differs from the patch base")
+	yes := flagSet.Bool("yes", false, "Apply without asking for confirmation")
+	dryRun := flagSet.Bool("dry-run", false, "Show the changes without applying them")
+
+	var keys crypt.KeyOptions
+	keys.AddFlags(flagSet)
+
+	args := []string{}
+	for len(opts) > 0 && !strings.HasPrefix(opts[0], "-") {
+		args = append(args, opts[0])
+		opts = opts[1:]
+	}
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	args = append(args, flagSet.Args()...)
+
+	options := &PatchOptions{
+		Action:   action,
+		FilePath: *file,
+		Output:   *output,
+		Values:   *values,
+		Patterns: input.SplitList(*patterns),
+		Force:    *force,
+		Yes:      *yes,
+		DryRun:   *dryRun,
+		Keys:     keys,
+	}
+	switch action {
+	case ActionCreate:
+		if len(args) != 2 {
+			return nil, errors.New("old and new files are required")
+		}
+		options.OldFile, options.NewFile = args[0], args[1]
+	case ActionApply:
+		if len(args) != 1 {
+			return nil, errors.New("patch file is required")
+		}
+		options.PatchFile = args[0]
+		if options.FilePath == "" {
+			return nil, errors.New("file path is required")
+		}
+	default:
+		return nil, fmt.Errorf("unknown action %q (expected create or apply)", action)
+	}
+	return options, nil
+}
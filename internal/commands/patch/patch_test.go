@@ -0,0 +1,344 @@
+package patch
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/sensitive"
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePatchOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *PatchOptions
+		wantErr bool
+	}{
+		"create": {
+			opts: []string{"create", ".env.old", ".env.new", "--values", "redacted"},
+			want: &PatchOptions{
+				Action:   ActionCreate,
+				OldFile:  ".env.old",
+				NewFile:  ".env.new",
+				Values:   ValuesRedacted,
+				Patterns: sensitive.DefaultPatterns,
+			},
+			wantErr: false,
+		},
+		"apply": {
+			opts: []string{"apply", "change.envpatch", "-f", ".env", "--force", "--yes", "--key-file", "team.key"},
+			want: &PatchOptions{
+				Action:    ActionApply,
+				PatchFile: "change.envpatch",
+				FilePath:  ".env",
+				Values:    ValuesAuto,
+				Patterns:  sensitive.DefaultPatterns,
+				Force:     true,
+				Yes:       true,
+				Keys:      crypt.KeyOptions{KeyFile: "team.key"},
+			},
+			wantErr: false,
+		},
+		"missing action": {
+			opts:    []string{"-f", ".env"},
+			want:    nil,
+			wantErr: true,
+		},
+		"create with one file": {
+			opts:    []string{"create", ".env.old"},
+			want:    nil,
+			wantErr: true,
+		},
+		"apply without target": {
+			opts:    []string{"apply", "change.envpatch"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParsePatchOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	old := map[string]string{"A": "1", "OLD": "x", "GONE": "1", "SAME": "s"}
+	new := map[string]string{"A": "2", "NEW": "x", "ADDED": "a b", "SAME": "s"}
+
+	assert.Equal(t, []Op{
+		{Name: OpSet, Key: "A", Value: "2", Base: Hash("A", "1")},
+		{Name: OpSet, Key: "ADDED", Value: "a b"},
+		{Name: OpUnset, Key: "GONE", Base: Hash("GONE", "1")},
+		{Name: OpRename, Key: "OLD", NewKey: "NEW", Base: Hash("OLD", "x")},
+	}, Create(old, new).Ops)
+}
+
+func TestWriteParseRoundTrip(t *testing.T) {
+	p := &Patch{Ops: []Op{
+		{Name: OpSet, Key: "A", Value: "line\n\"quoted\" redacted", Base: "0011223344556677"},
+		{Name: OpSet, Key: "TOKEN", Redacted: true},
+		{Name: OpSet, Key: "WORD", Value: Redacted},
+		{Name: OpUnset, Key: "GONE", Base: "8899aabbccddeeff"},
+		{Name: OpRename, Key: "OLD", NewKey: "NEW", Base: "0123456789abcdef"},
+	}}
+	var out bytes.Buffer
+	require.NoError(t, p.Write(&out))
+	assert.True(t, strings.HasPrefix(out.String(), Header+"\n"))
+
+	got, err := Parse(&out)
+	require.NoError(t, err)
+	assert.Equal(t, p, got)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]struct {
+		patch   string
+		wantErr error
+	}{
+		"missing header":      {patch: "set A \"1\"\n", wantErr: ErrNotPatch},
+		"unquoted value":      {patch: Header + "\nset A 1\n"},
+		"unset without base":  {patch: Header + "\nunset A\n"},
+		"rename without base": {patch: Header + "\nrename A B\n"},
+		"unknown operation":   {patch: Header + "\ndelete A base=00\n"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.patch))
+			assert.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecCreateRedactsSecrets(t *testing.T) {
+	dir := t.TempDir()
+	oldFile := filepath.Join(dir, ".env.old")
+	newFile := filepath.Join(dir, ".env.new")
+	require.NoError(t, os.WriteFile(oldFile, []byte("HOST=a\nDB_PASSWORD=old\n"), 0o600))
+	require.NoError(t, os.WriteFile(newFile, []byte("HOST=b\nDB_PASSWORD=new\n"), 0o600))
+
+	cmd, err := NewPatchCmd(&PatchOptions{Action: ActionCreate, OldFile: oldFile, NewFile: newFile, Values: ValuesAuto, Patterns: sensitive.DefaultPatterns})
+	require.NoError(t, err)
+	var out bytes.Buffer
+	cmd.stdout = &out
+	require.NoError(t, cmd.Exec())
+
+	assert.Equal(t, Header+"\n"+
+		"set DB_PASSWORD redacted base=*\n"+
+		"set HOST \"b\" base="+Hash("HOST", "a")+"\n", out.String())
+}
+
+func TestExecCreateRedactedHoldsNothingOfTheValues(t *testing.T) {
+	create := func(oldPassword, newPassword string) string {
+		dir := t.TempDir()
+		oldFile := filepath.Join(dir, ".env.old")
+		newFile := filepath.Join(dir, ".env.new")
+		require.NoError(t, os.WriteFile(oldFile, []byte("DB_PASSWORD="+oldPassword+"\nAPI_TOKEN=t\nOLD_SECRET=s\n"), 0o600))
+		require.NoError(t, os.WriteFile(newFile, []byte("DB_PASSWORD="+newPassword+"\nNEW_SECRET=s\n"), 0o600))
+
+		cmd, err := NewPatchCmd(&PatchOptions{Action: ActionCreate, OldFile: oldFile, NewFile: newFile, Values: ValuesRedacted, Patterns: sensitive.DefaultPatterns})
+		require.NoError(t, err)
+		var out bytes.Buffer
+		cmd.stdout = &out
+		require.NoError(t, cmd.Exec())
+		return out.String()
+	}
+
+	got := create("hunter2", "letmein")
+	assert.Equal(t, Header+"\n"+
+		"unset API_TOKEN base=*\n"+
+		"set DB_PASSWORD redacted base=*\n"+
+		"rename OLD_SECRET NEW_SECRET base=*\n", got)
+	assert.Equal(t, got, create("correct horse", "battery staple"))
+}
+
+func TestExecCreateApplyRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	oldFile := filepath.Join(dir, ".env.old")
+	newFile := filepath.Join(dir, ".env.new")
+	patchFile := filepath.Join(dir, "change.envpatch")
+	require.NoError(t, os.WriteFile(oldFile, []byte("HOST=a\nURL=u\n"), 0o600))
+	require.NoError(t, os.WriteFile(newFile, []byte(`HOST=b
+URL="http://\$HOST/x"
+TABBED="a	b"
+PATH_WIN=C:\dir\
+QUOTED='say "hi"'
+LINES="one\ntwo"
+`), 0o600))
+
+	create, err := NewPatchCmd(&PatchOptions{Action: ActionCreate, OldFile: oldFile, NewFile: newFile, Values: ValuesPlain})
+	require.NoError(t, err)
+	var out bytes.Buffer
+	create.stdout = &out
+	require.NoError(t, create.Exec())
+	require.NoError(t, os.WriteFile(patchFile, out.Bytes(), 0o600))
+
+	apply, err := NewPatchCmd(&PatchOptions{Action: ActionApply, PatchFile: patchFile, FilePath: oldFile, Yes: true})
+	require.NoError(t, err)
+	apply.stdout = &bytes.Buffer{}
+	require.NoError(t, apply.Exec())
+
+	want, err := godotenv.Read(newFile)
+	require.NoError(t, err)
+	got, err := godotenv.Read(oldFile)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+	assert.Equal(t, "http://$HOST/x", got["URL"])
+}
+
+func TestExecApply(t *testing.T) {
+	patch := Header + "\n" +
+		"set A \"2\" base=" + Hash("A", "1") + "\n" +
+		"set ADDED \"a b\"\n" +
+		"set TOKEN redacted base=" + Hash("TOKEN", "t1") + "\n" +
+		"unset GONE base=" + Hash("GONE", "g") + "\n" +
+		"rename OLD NEW base=" + Hash("OLD", "x") + "\n"
+
+	tests := map[string]struct {
+		env     string
+		force   bool
+		want    string
+		wantErr error
+	}{
+		"applies to the base": {
+			env:  "A=1\nTOKEN=t1\nGONE=g\nOLD=x\n",
+			want: "A=\"2\"\nTOKEN=\"prompted\"\nNEW=x\nADDED=\"a b\"",
+		},
+		"already applied changes are skipped": {
+			env:  "A=\"2\"\nADDED=\"a b\"\nTOKEN=t1\nNEW=x\n",
+			want: "A=\"2\"\nADDED=\"a b\"\nTOKEN=\"prompted\"\nNEW=x\n",
+		},
+		"conflicting value": {
+			env:     "A=5\nTOKEN=t1\nGONE=g\nOLD=x\n",
+			want:    "A=5\nTOKEN=t1\nGONE=g\nOLD=x\n",
+			wantErr: ErrConflict,
+		},
+		"conflicting value forced": {
+			env:   "A=5\nTOKEN=t1\nGONE=changed\nOLD=x\n",
+			force: true,
+			want:  "A=\"2\"\nTOKEN=\"prompted\"\nNEW=x\nADDED=\"a b\"",
+		},
+		"existing added key": {
+			env:     "A=1\nADDED=other\nTOKEN=t1\nGONE=g\nOLD=x\n",
+			want:    "A=1\nADDED=other\nTOKEN=t1\nGONE=g\nOLD=x\n",
+			wantErr: ErrConflict,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			envFile := filepath.Join(dir, ".env")
+			patchFile := filepath.Join(dir, "change.envpatch")
+			require.NoError(t, os.WriteFile(envFile, []byte(tt.env), 0o640))
+			require.NoError(t, os.WriteFile(patchFile, []byte(patch), 0o600))
+
+			cmd, err := NewPatchCmd(&PatchOptions{Action: ActionApply, PatchFile: patchFile, FilePath: envFile, Force: tt.force, Yes: true})
+			require.NoError(t, err)
+			cmd.stdout = &bytes.Buffer{}
+			cmd.prompt = func(string) (string, error) { return "prompted", nil }
+
+			err = cmd.Exec()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			got, _ := os.ReadFile(envFile)
+			assert.Equal(t, tt.want, string(got))
+			info, _ := os.Stat(envFile)
+			assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+		})
+	}
+}
+
+func TestExecApplyAnyBase(t *testing.T) {
+	patch := Header + "\nset DB_PASSWORD redacted base=*\nunset API_TOKEN base=*\n"
+
+	tests := map[string]struct {
+		env     string
+		want    string
+		wantErr error
+	}{
+		"any current value": {
+			env:  "DB_PASSWORD=whatever\nAPI_TOKEN=t\n",
+			want: "DB_PASSWORD=\"prompted\"\n",
+		},
+		"key not found": {
+			env:     "API_TOKEN=t\n",
+			want:    "API_TOKEN=t\n",
+			wantErr: ErrConflict,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			envFile := filepath.Join(dir, ".env")
+			patchFile := filepath.Join(dir, "change.envpatch")
+			require.NoError(t, os.WriteFile(envFile, []byte(tt.env), 0o600))
+			require.NoError(t, os.WriteFile(patchFile, []byte(patch), 0o600))
+
+			cmd, err := NewPatchCmd(&PatchOptions{Action: ActionApply, PatchFile: patchFile, FilePath: envFile, Yes: true})
+			require.NoError(t, err)
+			cmd.stdout = &bytes.Buffer{}
+			cmd.prompt = func(string) (string, error) { return "prompted", nil }
+
+			err = cmd.Exec()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			got, _ := os.ReadFile(envFile)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestExecEncryptedValues(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "team.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("0123456789abcdef"), 0o600))
+	keys := crypt.KeyOptions{KeyFile: keyFile}
+	oldFile := filepath.Join(dir, ".env.old")
+	newFile := filepath.Join(dir, ".env.new")
+	envFile := filepath.Join(dir, ".env")
+	patchFile := filepath.Join(dir, "change.envpatch")
+	require.NoError(t, os.WriteFile(oldFile, []byte("HOST=a\n"), 0o600))
+	require.NoError(t, os.WriteFile(newFile, []byte("HOST=b\n"), 0o600))
+	require.NoError(t, os.WriteFile(envFile, []byte("HOST=a\n"), 0o600))
+
+	cmd, err := NewPatchCmd(&PatchOptions{Action: ActionCreate, OldFile: oldFile, NewFile: newFile, Output: patchFile, Values: ValuesEncrypted, Keys: keys})
+	require.NoError(t, err)
+	require.NoError(t, cmd.Exec())
+	data, err := os.ReadFile(patchFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "set HOST \"enc:v1:")
+	assert.Contains(t, string(data), " base=enc:v1:")
+	assert.NotContains(t, string(data), Hash("HOST", "a"))
+
+	cmd, err = NewPatchCmd(&PatchOptions{Action: ActionApply, PatchFile: patchFile, FilePath: envFile, Yes: true, Keys: keys})
+	require.NoError(t, err)
+	cmd.stdout = &bytes.Buffer{}
+	require.NoError(t, cmd.Exec())
+	got, _ := os.ReadFile(envFile)
+	assert.Equal(t, "HOST=\"b\"\n", string(got))
+}
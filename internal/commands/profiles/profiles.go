@@ -0,0 +1,101 @@
+package profiles
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+)
+
+// ProfilesOptions holds the options for listing the profiles of an env file.
+type ProfilesOptions struct {
+	FilePath string
+	Profile  envfile.ProfileOptions
+}
+
+// ProfilesCmd represents the command for listing the profiles next to an env file and the files each loads.
+type ProfilesCmd struct {
+	Options ProfilesOptions
+}
+
+func Run(args []string) error {
+	options, err := ParseProfilesOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewProfilesCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewProfilesCmd creates a new ProfilesCmd instance with the specified options.
+func NewProfilesCmd(options *ProfilesOptions) (*ProfilesCmd, error) {
+	if options.FilePath == "" {
+		return nil, errors.New("file path is required")
+	}
+
+	return &ProfilesCmd{
+		Options: *options,
+	}, nil
+}
+
+// Exec executes the profiles command: prints every detected profile, marking the active one,
+// with the files it loads in order of precedence, lowest first.
+func (c *ProfilesCmd) Exec() error {
+	profiles, err := envfile.Profiles(c.Options.FilePath)
+	if err != nil {
+		return err
+	}
+
+	active := c.Options.Profile.Name()
+	if active != "" && !slices.Contains(profiles, active) {
+		fmt.Fprintf(os.Stderr, "Warning: active profile %s has no files next to %s\n", active, c.Options.FilePath)
+	}
+	if len(profiles) == 0 {
+		fmt.Printf("No profiles found next to %s (expected files such as %s.production)\n", c.Options.FilePath, c.Options.FilePath)
+		return nil
+	}
+
+	for _, profile := range profiles {
+		marker := ""
+		if profile == active {
+			marker = " (active)"
+		}
+		fmt.Printf("%s%s\n", profile, marker)
+		for _, file := range envfile.ProfileFiles(c.Options.FilePath, profile) {
+			fmt.Printf("  %s\n", file)
+		}
+	}
+	return nil
+}
+
+// ParseProfilesOptions parses command-line arguments and returns a ProfilesOptions struct.
+func ParseProfilesOptions(opts []string) (*ProfilesOptions, error) {
+	flagSet := flag.NewFlagSet("profiles", flag.ContinueOnError)
+	file := flagSet.String("f", ".env", "Path to the base .env file")
+
+	var profile envfile.ProfileOptions
+	profile.AddFlags(flagSet)
+
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	if len(flagSet.Args()) > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flagSet.Args(), " "))
+	}
+
+	return &ProfilesOptions{
+		FilePath: *file,
+		Profile:  profile,
+	}, nil
+}
@@ -0,0 +1,44 @@
+package profiles
+
+import (
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProfilesOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *ProfilesOptions
+		wantErr bool
+	}{
+		"defaults": {
+			opts:    []string{},
+			want:    &ProfilesOptions{FilePath: ".env"},
+			wantErr: false,
+		},
+		"file and active profile": {
+			opts:    []string{"-f", "config/.env", "--env", "production"},
+			want:    &ProfilesOptions{FilePath: "config/.env", Profile: envfile.ProfileOptions{Env: "production"}},
+			wantErr: false,
+		},
+		"unexpected argument": {
+			opts:    []string{"production"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseProfilesOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
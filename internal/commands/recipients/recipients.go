@@ -0,0 +1,427 @@
+package recipients
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/ba58ajbse/envcraft/internal/input"
+)
+
+// Actions of the recipients command.
+const (
+	ActionAdd    = "add"
+	ActionRemove = "remove"
+	ActionList   = "list"
+)
+
+// ErrNoFile is returned when removing a recipient without an encrypted file to re-encrypt.
+var ErrNoFile = errors.New("removing a recipient requires -f FILE: access is only revoked by re-encrypting the file with a new data key")
+
+// ErrUnlistedFiles is returned when removing a recipient would leave other files encrypted for
+// the recipients readable by the removed recipient.
+var ErrUnlistedFiles = errors.New("other files are encrypted for the recipients and would stay readable by the removed recipient")
+
+// ErrDroppedAccess is returned when removing a recipient would drop the passphrase or key file
+// access to a file without the passphrase or key file to keep it.
+var ErrDroppedAccess = errors.New("re-encrypting would drop access")
+
+// RecipientsOptions holds the options for managing the recipients of encrypted files.
+type RecipientsOptions struct {
+	Action         string
+	Recipient      string
+	Name           string
+	FilePaths      []string
+	DropPassphrase bool
+	DropKeyFile    bool
+	Keys           crypt.KeyOptions
+}
+
+// RecipientsCmd represents the command for adding and removing recipients of encrypted files.
+type RecipientsCmd struct {
+	Options  RecipientsOptions
+	OrgLines []string
+}
+
+func Run(args []string) error {
+	options, err := ParseRecipientsOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewRecipientsCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewRecipientsCmd creates a new RecipientsCmd instance with the specified options.
+func NewRecipientsCmd(options *RecipientsOptions) (*RecipientsCmd, error) {
+	if options.Action != ActionList {
+		if _, err := crypt.ParseRecipient(options.Recipient); err != nil {
+			return nil, err
+		}
+	}
+	if options.Action == ActionRemove && len(options.FilePaths) == 0 {
+		return nil, ErrNoFile
+	}
+
+	return &RecipientsCmd{
+		Options:  *options,
+		OrgLines: []string{},
+	}, nil
+}
+
+// Exec executes the recipients command: updates the recipients file and re-locks the data key
+// of the encrypted files, if any are given, for the new list of recipients.
+func (c *RecipientsCmd) Exec() error {
+	err := c.readLines()
+	if err != nil {
+		if c.Options.Action != ActionAdd || !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	var newLines []string
+	switch c.Options.Action {
+	case ActionList:
+		for _, line := range c.OrgLines {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				fmt.Println(line)
+			}
+		}
+		return nil
+	case ActionAdd:
+		newLines, err = c.addLines()
+	case ActionRemove:
+		newLines, err = c.removeLines()
+	default:
+		return fmt.Errorf("unknown action %q", c.Options.Action)
+	}
+	if err != nil {
+		return err
+	}
+
+	recipientsData := []byte(strings.Join(newLines, ""))
+	if len(c.Options.FilePaths) == 0 {
+		return fs.WriteFileAtomic(c.recipientsPath(), recipientsData, fileMode(c.recipientsPath(), 0644))
+	}
+	if c.Options.Action == ActionRemove {
+		unlisted, err := c.unlistedFiles()
+		if err != nil {
+			return err
+		}
+		if len(unlisted) > 0 {
+			return fmt.Errorf("%w: %s (re-encrypt them as well with -f %s)", ErrUnlistedFiles, strings.Join(unlisted, ", "), strings.Join(slices.Concat(c.Options.FilePaths, unlisted), ","))
+		}
+	}
+	return c.rewrap(recipientsData)
+}
+
+// readLines reads all lines of the recipients file and stores them in OrgLines.
+func (c *RecipientsCmd) readLines() error {
+	lines, err := fs.ReadLines(c.recipientsPath())
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", c.recipientsPath(), err)
+	}
+	c.OrgLines = lines
+
+	return nil
+}
+
+// addLines returns the lines of the recipients file with the recipient appended.
+func (c *RecipientsCmd) addLines() ([]string, error) {
+	if slices.ContainsFunc(c.OrgLines, c.isRecipientLine) {
+		return nil, fmt.Errorf("recipient %s is already listed", c.Options.Recipient)
+	}
+
+	newLines := slices.DeleteFunc(slices.Clone(c.OrgLines), func(line string) bool { return line == "" })
+	if len(newLines) > 0 && !strings.HasSuffix(newLines[len(newLines)-1], "\n") {
+		newLines[len(newLines)-1] += "\n"
+	}
+	line := c.Options.Recipient
+	if c.Options.Name != "" {
+		line += " # " + c.Options.Name
+	}
+	return append(newLines, line+"\n"), nil
+}
+
+// removeLines returns the lines of the recipients file without the recipient.
+func (c *RecipientsCmd) removeLines() ([]string, error) {
+	newLines := slices.DeleteFunc(slices.Clone(c.OrgLines), c.isRecipientLine)
+	if len(newLines) == len(c.OrgLines) {
+		return nil, fmt.Errorf("recipient %s is not listed", c.Options.Recipient)
+	}
+	return newLines, nil
+}
+
+// isRecipientLine reports whether line lists the recipient of the options.
+func (c *RecipientsCmd) isRecipientLine(line string) bool {
+	line, _, _ = strings.Cut(line, "#")
+	return strings.TrimSpace(line) == c.Options.Recipient
+}
+
+// rewrap re-locks the encrypted files for the new list of recipients and writes them together
+// with the recipients file, so that the recipients file never lists other recipients than the
+// files are encrypted for. Removing a recipient replaces the data keys, so the removed recipient
+// cannot decrypt later versions of the files.
+func (c *RecipientsCmd) rewrap(recipientsData []byte) error {
+	keys, err := c.Options.Keys.LoadFile(false)
+	if err != nil {
+		return err
+	}
+	keys.Recipients, err = crypt.ParseRecipients(recipientsData)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.recipientsPath(), err)
+	}
+
+	rotate := c.Options.Action == ActionRemove
+	orgData := make([][]byte, len(c.Options.FilePaths))
+	perms := make([]os.FileMode, len(c.Options.FilePaths))
+	staged := []*fs.StagedFile{}
+	discard := func() {
+		for _, s := range staged {
+			s.Discard()
+		}
+	}
+	for i, filePath := range c.Options.FilePaths {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			discard()
+			return fmt.Errorf("error reading file %s: %w", filePath, err)
+		}
+		if rotate {
+			if err := c.checkDroppedAccess(filePath, data, keys); err != nil {
+				discard()
+				return err
+			}
+		}
+		rewrapped, err := crypt.Rewrap(data, keys, rotate)
+		if err != nil {
+			discard()
+			return fmt.Errorf("%s: %w", filePath, err)
+		}
+		orgData[i], perms[i] = data, fileMode(filePath, 0600)
+		s, err := fs.StageFile(filePath, rewrapped, perms[i])
+		if err != nil {
+			discard()
+			return err
+		}
+		staged = append(staged, s)
+	}
+	s, err := fs.StageFile(c.recipientsPath(), recipientsData, fileMode(c.recipientsPath(), 0644))
+	if err != nil {
+		discard()
+		return err
+	}
+	staged = append(staged, s)
+
+	// The recipients file is committed last, so only encrypted files need restoring.
+	for i, s := range staged {
+		if err := s.Commit(); err != nil {
+			for _, s := range staged[i+1:] {
+				s.Discard()
+			}
+			restoreErrs := []error{err}
+			for j, filePath := range c.Options.FilePaths[:i] {
+				if err := fs.WriteFileAtomic(filePath, orgData[j], perms[j]); err != nil {
+					restoreErrs = append(restoreErrs, fmt.Errorf("error restoring %s: %w", filePath, err))
+				}
+			}
+			return errors.Join(restoreErrs...)
+		}
+	}
+	for _, filePath := range c.Options.FilePaths {
+		fmt.Printf("Re-encrypted %s for %d recipient(s)\n", filePath, len(keys.Recipients))
+	}
+
+	return nil
+}
+
+// checkDroppedAccess returns ErrDroppedAccess if the file can be decrypted with a passphrase or
+// key file that keys does not hold, as a new data key cannot be locked for it. The access is
+// dropped, with a note, only if the options allow it.
+func (c *RecipientsCmd) checkDroppedAccess(filePath string, data []byte, keys crypt.Keys) error {
+	envelope, err := crypt.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filePath, err)
+	}
+	for _, stanza := range envelope.Stanzas {
+		switch {
+		case stanza.Type == "scrypt" && len(keys.Passphrase) == 0:
+			if !c.Options.DropPassphrase {
+				return fmt.Errorf("%w: %s can be decrypted with a passphrase; pass it with --passphrase-file to keep that access, or --drop-passphrase to remove it", ErrDroppedAccess, filePath)
+			}
+			fmt.Printf("Note: passphrase access to %s is dropped.\n", filePath)
+		case stanza.Type == "keyfile" && len(keys.KeyFile) == 0:
+			if !c.Options.DropKeyFile {
+				return fmt.Errorf("%w: %s can be decrypted with a key file; pass it with --key-file to keep that access, or --drop-keyfile to remove it", ErrDroppedAccess, filePath)
+			}
+			fmt.Printf("Note: key file access to %s is dropped.\n", filePath)
+		}
+	}
+	return nil
+}
+
+// unlistedFiles returns the files encrypted for recipients next to or below the recipients file
+// that are not re-encrypted. Directories with a recipients file of their own, and hidden
+// directories such as .git, are not searched.
+func (c *RecipientsCmd) unlistedFiles() ([]string, error) {
+	listed := map[string]bool{}
+	for _, filePath := range c.Options.FilePaths {
+		listed[absPath(filePath)] = true
+	}
+
+	root := filepath.Dir(c.recipientsPath())
+	unlisted := []string{}
+	err := filepath.WalkDir(root, func(filePath string, entry iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if filePath == root {
+				return nil
+			}
+			if strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(filePath, crypt.DefaultRecipientsFile)); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || listed[absPath(filePath)] {
+			return nil
+		}
+		encrypted, err := encryptedForRecipients(filePath)
+		if err != nil {
+			return err
+		}
+		if encrypted {
+			unlisted = append(unlisted, filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error searching %s for encrypted files: %w", root, err)
+	}
+	return unlisted, nil
+}
+
+// encryptedForRecipients reports whether filePath is an encrypted file with recipients.
+// Only the header is read from other files.
+func encryptedForRecipients(filePath string) (bool, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	head := make([]byte, 64)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	if !crypt.IsEncrypted(head[:n]) {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return false, err
+	}
+	envelope, err := crypt.Parse(data)
+	if err != nil {
+		return false, nil
+	}
+	return slices.ContainsFunc(envelope.Stanzas, func(s crypt.Stanza) bool { return s.Type == "x25519" }), nil
+}
+
+// absPath returns filePath made absolute, or cleaned if that fails.
+func absPath(filePath string) string {
+	if abs, err := filepath.Abs(filePath); err == nil {
+		return abs
+	}
+	return filepath.Clean(filePath)
+}
+
+// fileMode returns the permissions of filePath, or perm if it does not exist yet.
+func fileMode(filePath string, perm os.FileMode) os.FileMode {
+	if info, err := os.Stat(filePath); err == nil {
+		return info.Mode().Perm()
+	}
+	return perm
+}
+
+// recipientsPath returns the recipients file to update.
+func (c *RecipientsCmd) recipientsPath() string {
+	if path := c.Options.Keys.RecipientsPath(); path != "" {
+		return path
+	}
+	return crypt.DefaultRecipientsFile
+}
+
+// ParseRecipientsOptions parses command-line arguments and returns a RecipientsOptions struct.
+func ParseRecipientsOptions(opts []string) (*RecipientsOptions, error) {
+	flagSet := flag.NewFlagSet("recipients", flag.ContinueOnError)
+	files := flagSet.String("f", "", "Comma separated paths of encrypted files to re-encrypt for the new recipients (required for remove)")
+	name := flagSet.String("name", "", "Name of the recipient, written as a comment (add only)")
+	dropPassphrase := flagSet.Bool("drop-passphrase", false, "Remove the passphrase access to the files when no passphrase is given (remove only)")
+	dropKeyFile := flagSet.Bool("drop-keyfile", false, "Remove the key file access to the files when no key file is given (remove only)")
+
+	var keys crypt.KeyOptions
+	keys.AddFlags(flagSet)
+	keys.AddRecipientFlags(flagSet)
+
+	if len(opts) == 0 || strings.HasPrefix(opts[0], "-") {
+		return nil, errors.New("usage: envcraft recipients [add|remove|list] [RECIPIENT] [flags]")
+	}
+	action := opts[0]
+	if !slices.Contains([]string{ActionAdd, ActionRemove, ActionList}, action) {
+		return nil, fmt.Errorf("unknown action %q (expected add, remove or list)", action)
+	}
+	opts = opts[1:]
+
+	args := []string{}
+	if len(opts) > 0 && !strings.HasPrefix(opts[0], "-") {
+		args = append(args, opts[0])
+		opts = opts[1:]
+	}
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	args = append(args, flagSet.Args()...)
+
+	var recipient string
+	if action == ActionList {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+		}
+	} else {
+		if len(args) != 1 {
+			return nil, errors.New("recipient is required")
+		}
+		recipient = args[0]
+	}
+
+	return &RecipientsOptions{
+		Action:         action,
+		Recipient:      recipient,
+		Name:           *name,
+		FilePaths:      input.SplitList(*files),
+		DropPassphrase: *dropPassphrase,
+		DropKeyFile:    *dropKeyFile,
+		Keys:           keys,
+	}, nil
+}
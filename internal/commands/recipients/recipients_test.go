@@ -0,0 +1,247 @@
+package recipients
+
+import (
+	"crypto/ecdh"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec_AddAndRemove(t *testing.T) {
+	tmpDir := t.TempDir()
+	recipientsFile := filepath.Join(tmpDir, "recipients")
+	envFile := filepath.Join(tmpDir, ".env.enc")
+
+	alice, _ := crypt.GenerateIdentity()
+	bob, _ := crypt.GenerateIdentity()
+	aliceFile := filepath.Join(tmpDir, "alice")
+	assert.NoError(t, os.WriteFile(aliceFile, []byte(crypt.FormatIdentity(alice)+"\n"), 0600))
+	assert.NoError(t, os.WriteFile(recipientsFile, []byte(crypt.FormatRecipient(alice.PublicKey())+" # alice\n"), 0644))
+
+	data, err := crypt.Encrypt([]byte("A=1\n"), crypt.Keys{Recipients: []*ecdh.PublicKey{alice.PublicKey()}})
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(envFile, data, 0600))
+
+	keys := crypt.KeyOptions{Identity: aliceFile, Recipients: recipientsFile}
+	bobRecipient := crypt.FormatRecipient(bob.PublicKey())
+
+	cmd, err := NewRecipientsCmd(&RecipientsOptions{Action: ActionAdd, Recipient: bobRecipient, Name: "bob", FilePaths: []string{envFile}, Keys: keys})
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+
+	content, _ := os.ReadFile(recipientsFile)
+	assert.Equal(t, crypt.FormatRecipient(alice.PublicKey())+" # alice\n"+bobRecipient+" # bob\n", string(content))
+	data, _ = os.ReadFile(envFile)
+	_, err = crypt.Decrypt(data, crypt.Keys{Identities: []*ecdh.PrivateKey{bob}})
+	assert.NoError(t, err)
+	info, err := os.Stat(envFile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Adding twice is refused.
+	cmd, _ = NewRecipientsCmd(&RecipientsOptions{Action: ActionAdd, Recipient: bobRecipient, Keys: keys})
+	assert.Error(t, cmd.Exec())
+
+	cmd, err = NewRecipientsCmd(&RecipientsOptions{Action: ActionRemove, Recipient: bobRecipient, FilePaths: []string{envFile}, Keys: keys})
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+
+	content, _ = os.ReadFile(recipientsFile)
+	assert.Equal(t, crypt.FormatRecipient(alice.PublicKey())+" # alice\n", string(content))
+	data, _ = os.ReadFile(envFile)
+	_, err = crypt.Decrypt(data, crypt.Keys{Identities: []*ecdh.PrivateKey{bob}})
+	assert.ErrorIs(t, err, crypt.ErrDecrypt)
+	plaintext, err := crypt.Decrypt(data, crypt.Keys{Identities: []*ecdh.PrivateKey{alice}})
+	assert.NoError(t, err)
+	assert.Equal(t, "A=1\n", string(plaintext))
+}
+
+func TestExec_RemoveRequiresFile(t *testing.T) {
+	alice, _ := crypt.GenerateIdentity()
+	_, err := NewRecipientsCmd(&RecipientsOptions{Action: ActionRemove, Recipient: crypt.FormatRecipient(alice.PublicKey())})
+	assert.ErrorIs(t, err, ErrNoFile)
+}
+
+func TestExec_FailedRewrapKeepsFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	recipientsFile := filepath.Join(tmpDir, "recipients")
+	envFile := filepath.Join(tmpDir, ".env.enc")
+
+	alice, _ := crypt.GenerateIdentity()
+	bob, _ := crypt.GenerateIdentity()
+	bobFile := filepath.Join(tmpDir, "bob")
+	assert.NoError(t, os.WriteFile(bobFile, []byte(crypt.FormatIdentity(bob)+"\n"), 0600))
+	recipients := crypt.FormatRecipient(alice.PublicKey()) + " # alice\n"
+	assert.NoError(t, os.WriteFile(recipientsFile, []byte(recipients), 0644))
+	data, err := crypt.Encrypt([]byte("A=1\n"), crypt.Keys{Recipients: []*ecdh.PublicKey{alice.PublicKey()}})
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(envFile, data, 0600))
+
+	// Bob is not a recipient yet, so his identity cannot re-encrypt the file.
+	keys := crypt.KeyOptions{Identity: bobFile, Recipients: recipientsFile}
+	cmd, err := NewRecipientsCmd(&RecipientsOptions{Action: ActionAdd, Recipient: crypt.FormatRecipient(bob.PublicKey()), FilePaths: []string{envFile}, Keys: keys})
+	assert.NoError(t, err)
+	assert.Error(t, cmd.Exec())
+
+	content, _ := os.ReadFile(recipientsFile)
+	assert.Equal(t, recipients, string(content))
+	content, _ = os.ReadFile(envFile)
+	assert.Equal(t, data, content)
+}
+
+// teamFiles writes a recipients file listing alice and bob and an identity file for alice in dir.
+func teamFiles(t *testing.T, dir string) (alice, bob *ecdh.PrivateKey, keys crypt.KeyOptions) {
+	alice, _ = crypt.GenerateIdentity()
+	bob, _ = crypt.GenerateIdentity()
+	aliceFile := filepath.Join(dir, "alice")
+	recipientsFile := filepath.Join(dir, crypt.DefaultRecipientsFile)
+	assert.NoError(t, os.WriteFile(aliceFile, []byte(crypt.FormatIdentity(alice)+"\n"), 0600))
+	assert.NoError(t, os.WriteFile(recipientsFile, []byte(crypt.FormatRecipient(alice.PublicKey())+"\n"+crypt.FormatRecipient(bob.PublicKey())+"\n"), 0644))
+	return alice, bob, crypt.KeyOptions{Identity: aliceFile, Recipients: recipientsFile}
+}
+
+// encryptFile writes A=1 encrypted with keys to filePath.
+func encryptFile(t *testing.T, filePath string, keys crypt.Keys) []byte {
+	data, err := crypt.Encrypt([]byte("A=1\n"), keys)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filePath, data, 0600))
+	return data
+}
+
+func TestExec_RemoveDropsPassphraseOnlyWhenAllowed(t *testing.T) {
+	tmpDir := t.TempDir()
+	alice, bob, keys := teamFiles(t, tmpDir)
+	envFile := filepath.Join(tmpDir, ".env.enc")
+	passphraseFile := filepath.Join(tmpDir, "passphrase")
+	assert.NoError(t, os.WriteFile(passphraseFile, []byte("correct horse\n"), 0600))
+	recipients, _ := os.ReadFile(keys.Recipients)
+	data := encryptFile(t, envFile, crypt.Keys{Recipients: []*ecdh.PublicKey{alice.PublicKey(), bob.PublicKey()}, Passphrase: []byte("correct horse")})
+	remove := &RecipientsOptions{Action: ActionRemove, Recipient: crypt.FormatRecipient(bob.PublicKey()), FilePaths: []string{envFile}, Keys: keys}
+
+	cmd, err := NewRecipientsCmd(remove)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, cmd.Exec(), ErrDroppedAccess)
+	content, _ := os.ReadFile(envFile)
+	assert.Equal(t, data, content)
+	content, _ = os.ReadFile(keys.Recipients)
+	assert.Equal(t, recipients, content)
+
+	withPassphrase := *remove
+	withPassphrase.Keys.PassphraseFile = passphraseFile
+	cmd, err = NewRecipientsCmd(&withPassphrase)
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+	content, _ = os.ReadFile(envFile)
+	_, err = crypt.Decrypt(content, crypt.Keys{Passphrase: []byte("correct horse")})
+	assert.NoError(t, err)
+	_, err = crypt.Decrypt(content, crypt.Keys{Identities: []*ecdh.PrivateKey{bob}})
+	assert.ErrorIs(t, err, crypt.ErrDecrypt)
+
+	// Without the passphrase, its access is dropped only when asked to.
+	assert.NoError(t, os.WriteFile(keys.Recipients, []byte(crypt.FormatRecipient(alice.PublicKey())+"\n"+crypt.FormatRecipient(bob.PublicKey())+"\n"), 0644))
+	dropping := *remove
+	dropping.DropPassphrase = true
+	cmd, err = NewRecipientsCmd(&dropping)
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+	content, _ = os.ReadFile(envFile)
+	_, err = crypt.Decrypt(content, crypt.Keys{Passphrase: []byte("correct horse")})
+	assert.ErrorIs(t, err, crypt.ErrDecrypt)
+	_, err = crypt.Decrypt(content, crypt.Keys{Identities: []*ecdh.PrivateKey{alice}})
+	assert.NoError(t, err)
+}
+
+func TestExec_RemoveReencryptsEveryFileOfTheRecipients(t *testing.T) {
+	tmpDir := t.TempDir()
+	alice, bob, keys := teamFiles(t, tmpDir)
+	team := crypt.Keys{Recipients: []*ecdh.PublicKey{alice.PublicKey(), bob.PublicKey()}}
+	envFile := filepath.Join(tmpDir, ".env.enc")
+	prodFile := filepath.Join(tmpDir, "deploy", ".env.prod.enc")
+	otherTeamFile := filepath.Join(tmpDir, "other", ".env.enc")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(prodFile), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(otherTeamFile), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "other", crypt.DefaultRecipientsFile), []byte(crypt.FormatRecipient(bob.PublicKey())+"\n"), 0644))
+	encryptFile(t, envFile, team)
+	prodData := encryptFile(t, prodFile, team)
+	otherTeamData := encryptFile(t, otherTeamFile, crypt.Keys{Recipients: []*ecdh.PublicKey{bob.PublicKey()}})
+	// A file encrypted with a passphrase only has no recipients to remove.
+	encryptFile(t, filepath.Join(tmpDir, ".env.local.enc"), crypt.Keys{Passphrase: []byte("p")})
+
+	remove := &RecipientsOptions{Action: ActionRemove, Recipient: crypt.FormatRecipient(bob.PublicKey()), FilePaths: []string{envFile}, Keys: keys}
+	cmd, err := NewRecipientsCmd(remove)
+	assert.NoError(t, err)
+	err = cmd.Exec()
+	assert.ErrorIs(t, err, ErrUnlistedFiles)
+	assert.ErrorContains(t, err, prodFile)
+	assert.NotContains(t, err.Error(), otherTeamFile)
+	content, _ := os.ReadFile(prodFile)
+	assert.Equal(t, prodData, content)
+
+	remove.FilePaths = []string{envFile, prodFile}
+	cmd, err = NewRecipientsCmd(remove)
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+	for _, filePath := range remove.FilePaths {
+		content, _ := os.ReadFile(filePath)
+		_, err = crypt.Decrypt(content, crypt.Keys{Identities: []*ecdh.PrivateKey{bob}})
+		assert.ErrorIs(t, err, crypt.ErrDecrypt)
+		_, err = crypt.Decrypt(content, crypt.Keys{Identities: []*ecdh.PrivateKey{alice}})
+		assert.NoError(t, err)
+	}
+	content, _ = os.ReadFile(otherTeamFile)
+	assert.Equal(t, otherTeamData, content)
+}
+
+func TestParseRecipientsOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *RecipientsOptions
+		wantErr bool
+	}{
+		"add with name": {
+			opts:    []string{"add", "x25519:abc", "--name", "bob"},
+			want:    &RecipientsOptions{Action: ActionAdd, Recipient: "x25519:abc", Name: "bob", FilePaths: []string{}},
+			wantErr: false,
+		},
+		"remove and re-encrypt": {
+			opts:    []string{"remove", "x25519:abc", "-f", ".env.enc", "--recipients", "team"},
+			want:    &RecipientsOptions{Action: ActionRemove, Recipient: "x25519:abc", FilePaths: []string{".env.enc"}, Keys: crypt.KeyOptions{Recipients: "team"}},
+			wantErr: false,
+		},
+		"remove from several files dropping the passphrase": {
+			opts:    []string{"remove", "x25519:abc", "-f", ".env.enc, .env.prod.enc", "--drop-passphrase"},
+			want:    &RecipientsOptions{Action: ActionRemove, Recipient: "x25519:abc", FilePaths: []string{".env.enc", ".env.prod.enc"}, DropPassphrase: true},
+			wantErr: false,
+		},
+		"list": {
+			opts:    []string{"list"},
+			want:    &RecipientsOptions{Action: ActionList, FilePaths: []string{}},
+			wantErr: false,
+		},
+		"missing recipient": {
+			opts:    []string{"add"},
+			want:    nil,
+			wantErr: true,
+		},
+		"unknown action": {
+			opts:    []string{"show"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseRecipientsOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
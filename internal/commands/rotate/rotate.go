@@ -0,0 +1,358 @@
+package rotate
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+)
+
+// Ways of keeping the previous value of a rotated key.
+const (
+	KeepComment  = "comment"  // # KEY="old"  (rotated 2026-10-17)
+	KeepPrevious = "previous" // KEY_PREVIOUS="old"
+	KeepNone     = "none"
+)
+
+const (
+	dateLayout   = "2006-01-02"
+	markerPrefix = "# envcraft:rotated="
+)
+
+// RotateOptions holds the options for rotating a secret.
+type RotateOptions struct {
+	Key      string
+	FilePath string
+	Keep     string
+	Source   input.ValueSource
+	Report   bool
+	MaxAge   int
+	Keys     crypt.KeyOptions
+}
+
+// RotateCmd represents the command for replacing a secret with a new value while keeping the previous one.
+type RotateCmd struct {
+	Options  RotateOptions
+	OrgLines []string
+	cipher   *crypt.ValueCipher
+	now      func() time.Time
+}
+
+// ErrNoUpdated is returned when no matching key is found to rotate.
+var ErrNoUpdated = errors.New("no lines updated")
+
+// ErrStale is returned by the report when a secret was rotated longer ago than the maximum age.
+var ErrStale = errors.New("secrets older than the maximum age")
+
+func Run(args []string) error {
+	options, err := ParseRotateOptions(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := NewRotateCmd(options)
+	if err != nil {
+		return err
+	}
+	err = cmd.Exec()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewRotateCmd creates a new RotateCmd instance with the specified options.
+func NewRotateCmd(options *RotateOptions) (*RotateCmd, error) {
+	if options.FilePath == "" {
+		return nil, errors.New("file path is required")
+	}
+
+	return &RotateCmd{
+		Options:  *options,
+		OrgLines: []string{},
+		now:      time.Now,
+	}, nil
+}
+
+// Exec executes the rotate command: reads lines, replaces the value, and writes back.
+// In report mode it lists the rotation date of every secret instead.
+func (c *RotateCmd) Exec() error {
+	err := c.readLines()
+	if err != nil {
+		return err
+	}
+
+	if c.Options.Report {
+		return c.report()
+	}
+
+	value, err := c.Options.Source.Read(c.Options.Key)
+	if err != nil {
+		return err
+	}
+	if c.isEncrypted() {
+		keys, err := envfile.LoadValueKeys(c.OrgLines, c.Options.Keys, true)
+		if err != nil {
+			return err
+		}
+		c.cipher = crypt.NewValueCipher(keys)
+		if value, err = c.cipher.Encrypt(c.Options.Key, value); err != nil {
+			return err
+		}
+	}
+
+	newLines, err := c.makeNewLines(value)
+	if err != nil {
+		return err
+	}
+
+	err = c.apply(newLines)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// readLines reads all lines from the file specified in RotateCmd and stores them in OrgLines.
+func (c *RotateCmd) readLines() error {
+	lines, err := fs.ReadLines(c.filePath())
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", c.filePath(), err)
+	}
+	c.OrgLines = lines
+
+	return nil
+}
+
+// isEncrypted reports whether any line of the key holds an encrypted value,
+// in which case the new value is encrypted as well.
+func (c *RotateCmd) isEncrypted() bool {
+	for _, i := range lines.KeyIndexes(c.OrgLines, c.Options.Key) {
+		if value, _ := lines.Value(c.OrgLines[i]); crypt.IsEncryptedValue(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// makeNewLines returns a new slice of lines with the key set to value, the previous value kept
+// according to the Keep option and a rotation marker recording today's date above the key.
+func (c *RotateCmd) makeNewLines(value string) ([]string, error) {
+	key := c.Options.Key
+	indexes := lines.KeyIndexes(c.OrgLines, key)
+	if len(indexes) == 0 {
+		return slices.Clone(c.OrgLines), fmt.Errorf("%w: key not found: %s", ErrNoUpdated, key)
+	}
+	indexes, err := lines.SelectOccurrences(key, indexes, "")
+	if errors.Is(err, lines.ErrAmbiguousKey) {
+		return slices.Clone(c.OrgLines), fmt.Errorf("%w (use dedupe to keep one definition)", err)
+	}
+	if err != nil {
+		return slices.Clone(c.OrgLines), err
+	}
+	i := indexes[0]
+	oldValue, _ := lines.Value(c.OrgLines[i])
+	date := c.now().Format(dateLayout)
+
+	// Replace the annotations left by an earlier rotation.
+	start := i
+	for start > 0 && c.isRotationComment(c.OrgLines[start-1]) {
+		start--
+	}
+
+	block := []string{}
+	if c.Options.Keep == KeepComment {
+		oldLine, err := keyAndValue(key, oldValue)
+		if err != nil {
+			return slices.Clone(c.OrgLines), err
+		}
+		block = append(block, fmt.Sprintf("# %s  (rotated %s)\n", oldLine, date))
+	}
+	line, err := keyAndValue(key, value)
+	if err != nil {
+		return slices.Clone(c.OrgLines), err
+	}
+	block = append(block, markerPrefix+date+"\n", line+"\n")
+
+	newLines := slices.Concat(c.OrgLines[:start], block, c.OrgLines[i+1:])
+	if c.Options.Keep == KeepPrevious {
+		previousKey := key + "_PREVIOUS"
+		previousValue, err := c.reencrypt(oldValue, key, previousKey)
+		if err != nil {
+			return slices.Clone(c.OrgLines), err
+		}
+		previousLine, err := keyAndValue(previousKey, previousValue)
+		if err != nil {
+			return slices.Clone(c.OrgLines), err
+		}
+		previousLine += "\n"
+		if previous := lines.KeyIndexes(newLines, previousKey); len(previous) > 0 {
+			newLines[previous[0]] = previousLine
+		} else {
+			newLines = slices.Insert(newLines, start+len(block), previousLine)
+		}
+	}
+
+	return lines.KeepTrailingNewline(c.OrgLines, newLines), nil
+}
+
+// reencrypt returns an encrypted value of oldKey encrypted again for newKey, as encrypted values
+// are bound to their key. Other values are returned unchanged.
+func (c *RotateCmd) reencrypt(value, oldKey, newKey string) (string, error) {
+	if !crypt.IsEncryptedValue(value) {
+		return value, nil
+	}
+	plaintext, err := c.cipher.Decrypt(oldKey, value)
+	if err != nil {
+		return "", err
+	}
+	return c.cipher.Encrypt(newKey, plaintext)
+}
+
+// isRotationComment reports whether line is a rotation marker or a commented previous value of the key.
+func (c *RotateCmd) isRotationComment(line string) bool {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, markerPrefix) {
+		return true
+	}
+	return strings.HasPrefix(line, "# "+c.Options.Key+"=") && strings.Contains(line, "(rotated ")
+}
+
+// report prints the rotation date of every key carrying a rotation marker.
+// Returns ErrStale if any of them is older than MaxAge days.
+func (c *RotateCmd) report() error {
+	stale := []string{}
+	rotated := RotatedAt(c.OrgLines)
+	for _, key := range slices.Sorted(maps.Keys(rotated)) {
+		days := int(c.now().Sub(rotated[key]).Hours() / 24)
+		status := "ok"
+		if days > c.Options.MaxAge {
+			status = "STALE"
+			stale = append(stale, key)
+		}
+		fmt.Printf("%s\trotated %s (%d days ago)\t%s\n", key, rotated[key].Format(dateLayout), days, status)
+	}
+
+	if len(stale) > 0 {
+		return fmt.Errorf("%w (%d days): %s", ErrStale, c.Options.MaxAge, strings.Join(stale, ", "))
+	}
+	return nil
+}
+
+// RotatedAt returns the last rotation date of every key that carries a rotation marker.
+func RotatedAt(envLines []string) map[string]time.Time {
+	rotated := map[string]time.Time{}
+	for i, line := range envLines {
+		date, found := strings.CutPrefix(strings.TrimSpace(line), markerPrefix)
+		if !found || i+1 >= len(envLines) {
+			continue
+		}
+		key, ok := lines.Key(envLines[i+1])
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(dateLayout, date)
+		if err != nil {
+			continue
+		}
+		rotated[key] = t
+	}
+	return rotated
+}
+
+// apply writes the new lines to the file, overwriting the original content.
+func (c *RotateCmd) apply(newLines []string) error {
+	if err := fs.WriteLines(c.filePath(), newLines); err != nil {
+		return fmt.Errorf("error writing to file %s: %w", c.filePath(), err)
+	}
+
+	return nil
+}
+
+// filePath returns the file path from the options.
+func (c *RotateCmd) filePath() string {
+	return c.Options.FilePath
+}
+
+// keyAndValue returns the key and quoted value in the format KEY="value".
+// The value is quoted so that dotenv loaders read it back unchanged.
+func keyAndValue(key, value string) (string, error) {
+	quoted, err := lines.Quote(value)
+	if err != nil {
+		return "", err
+	}
+	return key + "=" + quoted, nil
+}
+
+// ParseRotateOptions parses command-line arguments and returns a RotateOptions struct.
+// Without another value source the new value is generated.
+func ParseRotateOptions(opts []string) (*RotateOptions, error) {
+	flagSet := flag.NewFlagSet("rotate", flag.ContinueOnError)
+	file := flagSet.String("f", "", "Path to .env file")
+	keep := flagSet.String("keep", KeepComment, "How to keep the previous value: comment, previous (KEY_PREVIOUS) or none")
+	report := flagSet.Bool("report", false, "List when each secret was last rotated")
+	maxAge := flagSet.Int("max-age", 90, "Maximum age in days before a secret is reported as stale")
+
+	var source input.ValueSource
+	source.AddFlags(flagSet)
+	var keys crypt.KeyOptions
+	keys.AddFlags(flagSet)
+
+	args := []string{}
+	if len(opts) > 0 && !strings.HasPrefix(opts[0], "-") {
+		args = append(args, opts[0])
+		opts = opts[1:]
+	}
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	args = append(args, flagSet.Args()...)
+
+	var key string
+	if !*report {
+		if len(args) != 1 {
+			return nil, errors.New("key is required")
+		}
+		key = args[0]
+	}
+
+	if *file == "" {
+		fmt.Println("Error: -f flag is required")
+		flagSet.Usage()
+		return nil, errors.New("file path is required")
+	}
+
+	if !slices.Contains([]string{KeepComment, KeepPrevious, KeepNone}, *keep) {
+		return nil, fmt.Errorf("--keep must be comment, previous or none, got %q", *keep)
+	}
+
+	if *maxAge < 0 {
+		return nil, errors.New("max age must be a non-negative integer")
+	}
+
+	if !source.IsSet() {
+		source.Generate = true
+	}
+	if err := source.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &RotateOptions{
+		Key:      key,
+		FilePath: *file,
+		Keep:     *keep,
+		Source:   source,
+		Report:   *report,
+		MaxAge:   *maxAge,
+		Keys:     keys,
+	}, nil
+}
@@ -0,0 +1,255 @@
+package rotate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envfile"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+	"github.com/stretchr/testify/assert"
+)
+
+func fixedNow() time.Time {
+	return time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+}
+
+func Test_makeNewLines(t *testing.T) {
+	tests := map[string]struct {
+		orgLines []string
+		key      string
+		keep     string
+		value    string
+		want     []string
+		wantErr  bool
+	}{
+		"keep previous value as comment": {
+			orgLines: []string{"FOO=\"bar\"\n", "API_KEY=\"old\"\n", "BAR=\"baz\""},
+			key:      "API_KEY",
+			keep:     KeepComment,
+			value:    "new",
+			want: []string{
+				"FOO=\"bar\"\n",
+				"# API_KEY=\"old\"  (rotated 2026-10-17)\n",
+				"# envcraft:rotated=2026-10-17\n",
+				"API_KEY=\"new\"\n",
+				"BAR=\"baz\"",
+			},
+		},
+		"replace annotations of earlier rotation": {
+			orgLines: []string{
+				"# API_KEY=\"older\"  (rotated 2026-01-01)\n",
+				"# envcraft:rotated=2026-01-01\n",
+				"API_KEY=\"old\"",
+			},
+			key:   "API_KEY",
+			keep:  KeepComment,
+			value: "new",
+			want: []string{
+				"# API_KEY=\"old\"  (rotated 2026-10-17)\n",
+				"# envcraft:rotated=2026-10-17\n",
+				"API_KEY=\"new\"",
+			},
+		},
+		"keep previous value in KEY_PREVIOUS": {
+			orgLines: []string{"API_KEY=\"old\"\n", "BAR=\"baz\"\n"},
+			key:      "API_KEY",
+			keep:     KeepPrevious,
+			value:    "new",
+			want: []string{
+				"# envcraft:rotated=2026-10-17\n",
+				"API_KEY=\"new\"\n",
+				"API_KEY_PREVIOUS=\"old\"\n",
+				"BAR=\"baz\"\n",
+			},
+		},
+		"update existing KEY_PREVIOUS": {
+			orgLines: []string{"API_KEY=\"old\"\n", "API_KEY_PREVIOUS=\"older\""},
+			key:      "API_KEY",
+			keep:     KeepPrevious,
+			value:    "new",
+			want: []string{
+				"# envcraft:rotated=2026-10-17\n",
+				"API_KEY=\"new\"\n",
+				"API_KEY_PREVIOUS=\"old\"",
+			},
+		},
+		"values read back unchanged": {
+			orgLines: []string{"HOST=db\n", "API_KEY=\"o\\$HOST\\\\x\"\n"},
+			key:      "API_KEY",
+			keep:     KeepPrevious,
+			value:    "n$HOST\t\"x\"\n",
+			want: []string{
+				"HOST=db\n",
+				"# envcraft:rotated=2026-10-17\n",
+				"API_KEY=\"n\\$HOST\t\\\"x\\\"\\n\"\n",
+				"API_KEY_PREVIOUS=\"o\\$HOST\\\\x\"\n",
+			},
+		},
+		"missing key": {
+			orgLines: []string{"FOO=\"bar\""},
+			key:      "API_KEY",
+			keep:     KeepNone,
+			value:    "new",
+			wantErr:  true,
+		},
+		"duplicate key": {
+			orgLines: []string{"API_KEY=\"a\"\n", "API_KEY=\"b\""},
+			key:      "API_KEY",
+			keep:     KeepNone,
+			value:    "new",
+			wantErr:  true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmd := &RotateCmd{
+				Options:  RotateOptions{Key: tt.key, Keep: tt.keep},
+				OrgLines: tt.orgLines,
+				now:      fixedNow,
+			}
+			got, err := cmd.makeNewLines(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_makeNewLines_DuplicateKeyHint(t *testing.T) {
+	cmd := &RotateCmd{
+		Options:  RotateOptions{Key: "API_KEY", Keep: KeepNone},
+		OrgLines: []string{"API_KEY=\"a\"\n", "API_KEY=\"b\""},
+		now:      fixedNow,
+	}
+	_, err := cmd.makeNewLines("new")
+	assert.ErrorIs(t, err, lines.ErrAmbiguousKey)
+	assert.EqualError(t, err, "key defined more than once: API_KEY on lines 1, 2 (use dedupe to keep one definition)")
+}
+
+func TestExec_KeepPreviousEncrypted(t *testing.T) {
+	tmpDir := t.TempDir()
+	keyFile := filepath.Join(tmpDir, "team.key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("0123456789abcdef"), 0600))
+	keys := crypt.Keys{KeyFile: []byte("0123456789abcdef")}
+
+	encrypted, err := crypt.NewValueCipher(keys).Encrypt("API_KEY", "old")
+	assert.NoError(t, err)
+	envFile := filepath.Join(tmpDir, ".env")
+	assert.NoError(t, os.WriteFile(envFile, []byte("API_KEY=\""+encrypted+"\"\n"), 0644))
+
+	cmd, err := NewRotateCmd(&RotateOptions{
+		Key:      "API_KEY",
+		FilePath: envFile,
+		Keep:     KeepPrevious,
+		Source:   input.ValueSource{Generate: true},
+		Keys:     crypt.KeyOptions{KeyFile: keyFile},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+
+	env, err := envfile.Load(envFile, crypt.KeyOptions{KeyFile: keyFile})
+	assert.NoError(t, err)
+	assert.Equal(t, "old", env.Values["API_KEY_PREVIOUS"])
+	assert.NotEqual(t, "old", env.Values["API_KEY"])
+	assert.NotEmpty(t, env.Values["API_KEY"])
+}
+
+func TestExec_RejectsOtherKey(t *testing.T) {
+	tmpDir := t.TempDir()
+	keyFile := filepath.Join(tmpDir, "other.key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("fedcba9876543210"), 0600))
+
+	encrypted, err := crypt.NewValueCipher(crypt.Keys{KeyFile: []byte("0123456789abcdef")}).Encrypt("API_KEY", "old")
+	assert.NoError(t, err)
+	envFile := filepath.Join(tmpDir, ".env")
+	content := "API_KEY=\"" + encrypted + "\"\n"
+	assert.NoError(t, os.WriteFile(envFile, []byte(content), 0644))
+
+	cmd, err := NewRotateCmd(&RotateOptions{
+		Key:      "API_KEY",
+		FilePath: envFile,
+		Keep:     KeepComment,
+		Source:   input.ValueSource{Generate: true},
+		Keys:     crypt.KeyOptions{KeyFile: keyFile},
+	})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, cmd.Exec(), crypt.ErrKeyMismatch)
+
+	data, err := os.ReadFile(envFile)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(data))
+}
+
+func Test_report(t *testing.T) {
+	cmd := &RotateCmd{
+		Options: RotateOptions{Report: true, MaxAge: 90},
+		OrgLines: []string{
+			"# envcraft:rotated=2026-10-01\n",
+			"FRESH=\"a\"\n",
+			"# envcraft:rotated=2026-01-01\n",
+			"OLD=\"b\"\n",
+		},
+		now: fixedNow,
+	}
+	err := cmd.report()
+	assert.ErrorIs(t, err, ErrStale)
+	assert.ErrorContains(t, err, "OLD")
+	assert.NotContains(t, err.Error(), "FRESH")
+}
+
+func TestParseRotateOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts    []string
+		want    *RotateOptions
+		wantErr bool
+	}{
+		"generate by default": {
+			opts:    []string{"KEY", "-f", "test.env"},
+			want:    &RotateOptions{Key: "KEY", FilePath: "test.env", Keep: KeepComment, Source: input.ValueSource{Generate: true}, MaxAge: 90},
+			wantErr: false,
+		},
+		"value from stdin, keep previous": {
+			opts:    []string{"KEY", "--stdin", "--keep", "previous", "-f", "test.env"},
+			want:    &RotateOptions{Key: "KEY", FilePath: "test.env", Keep: KeepPrevious, Source: input.ValueSource{Stdin: true}, MaxAge: 90},
+			wantErr: false,
+		},
+		"report": {
+			opts:    []string{"--report", "--max-age", "30", "-f", "test.env"},
+			want:    &RotateOptions{FilePath: "test.env", Keep: KeepComment, Source: input.ValueSource{Generate: true}, Report: true, MaxAge: 30},
+			wantErr: false,
+		},
+		"invalid keep": {
+			opts:    []string{"KEY", "--keep", "all", "-f", "test.env"},
+			want:    nil,
+			wantErr: true,
+		},
+		"missing key": {
+			opts:    []string{"-f", "test.env"},
+			want:    nil,
+			wantErr: true,
+		},
+		"missing file": {
+			opts:    []string{"KEY"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseRotateOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
@@ -6,14 +6,21 @@ import (
 	"os"
 	"os/exec"
 
-	"github.com/joho/godotenv"
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envfile"
 )
 
 // Run parses flags, loads environment variables from a file, and executes the specified command with those variables set.
+// Encrypted files are decrypted in memory; the plaintext is never written to disk.
 func Run(args []string) error {
 	// 1) Define flags
 	fs := flag.NewFlagSet("run", flag.ContinueOnError)
 	envFile := fs.String("f", ".env", "path to .env file")
+	var profile envfile.ProfileOptions
+	profile.AddFlags(fs)
+	var keyOptions crypt.KeyOptions
+	keyOptions.AddFlags(fs)
+	keyOptions.AddRecipientFlags(fs)
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
@@ -21,13 +28,13 @@ func Run(args []string) error {
 	// 2) The rest of args after flags is the command to exec
 	cmdArgs := fs.Args()
 	if len(cmdArgs) == 0 {
-		return fmt.Errorf("usage: envcraft run [-f .env] -- <your-command>")
+		return fmt.Errorf("usage: envcraft run [-f .env] [--env profile] -- <your-command>")
 	}
 	cmdName := cmdArgs[0]
 	cmdParams := cmdArgs[1:]
 
 	// 3) Load env
-	err := godotenv.Load(*envFile)
+	err := load(*envFile, profile, keyOptions)
 	if err != nil {
 		return err
 	}
@@ -39,3 +46,21 @@ func Run(args []string) error {
 	cmd.Stdin = os.Stdin
 	return cmd.Run()
 }
+
+// load sets the variables of envFile, or of the files of the selected profile, that are not
+// already set, like godotenv.Load, decrypting the files or their encrypted values first.
+func load(envFile string, profile envfile.ProfileOptions, keyOptions crypt.KeyOptions) error {
+	env, err := profile.Load(envFile, keyOptions)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range env.Keys {
+		if _, ok := os.LookupEnv(key); !ok {
+			if err := os.Setenv(key, env.Values[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -1,8 +1,11 @@
 package run
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/ba58ajbse/envcraft/internal/crypt"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -35,3 +38,37 @@ func TestRun(t *testing.T) {
 		})
 	}
 }
+
+func TestRun_EncryptedFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	encFile := filepath.Join(tmpDir, ".env.production.enc")
+	data, err := crypt.Encrypt([]byte("ENVCRAFT_RUN_TEST=decrypted\n"), crypt.Keys{Passphrase: []byte("correct horse")})
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(encFile, data, 0644))
+
+	t.Setenv(crypt.EnvPassphrase, "correct horse")
+	err = Run([]string{"-f", encFile, "--", "sh", "-c", `test "$ENVCRAFT_RUN_TEST" = decrypted`})
+	assert.NoError(t, err)
+
+	entries, err := os.ReadDir(tmpDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "no plaintext file may be written")
+
+	t.Setenv(crypt.EnvPassphrase, "wrong")
+	err = Run([]string{"-f", encFile, "--", "true"})
+	assert.ErrorIs(t, err, crypt.ErrDecrypt)
+}
+
+func TestRun_EncryptedValues(t *testing.T) {
+	tmpDir := t.TempDir()
+	keyFile := filepath.Join(tmpDir, "team.key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("0123456789abcdef"), 0600))
+
+	encrypted, err := crypt.NewValueCipher(crypt.Keys{KeyFile: []byte("0123456789abcdef")}).Encrypt("ENVCRAFT_RUN_SECRET", "s3cr3t")
+	assert.NoError(t, err)
+	envFile := filepath.Join(tmpDir, ".env")
+	assert.NoError(t, os.WriteFile(envFile, []byte("ENVCRAFT_RUN_SECRET=\""+encrypted+"\"\n"), 0644))
+
+	err = Run([]string{"-f", envFile, "--key-file", keyFile, "--", "sh", "-c", `test "$ENVCRAFT_RUN_SECRET" = s3cr3t`})
+	assert.NoError(t, err)
+}
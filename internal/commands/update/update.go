@@ -5,17 +5,25 @@ import (
 	"flag"
 	"fmt"
 	"slices"
-	"strconv"
 	"strings"
 
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/envfile"
 	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/ba58ajbse/envcraft/internal/lines"
 )
 
 // UpdateOptions holds the options for updating an environment variable.
 type UpdateOptions struct {
-	Key      string
-	Value    string
-	FilePath string
+	Key        string
+	Value      string
+	FilePath   string
+	Occurrence string
+	Source     input.ValueSource
+	IfMissing  bool
+	Secret     bool
+	Keys       crypt.KeyOptions
 }
 
 // UpdateCmd represents the command for updating an environment variable in a file.
@@ -62,6 +70,21 @@ func (c *UpdateCmd) Exec() error {
 		return err
 	}
 
+	if c.Options.IfMissing && c.hasValue() {
+		fmt.Printf("%s is already set, skipped.\n", c.Options.Key)
+		return nil
+	}
+
+	if err := c.readValue(); err != nil {
+		return err
+	}
+
+	if c.Options.Secret || c.isEncrypted() {
+		if err := c.encryptValue(); err != nil {
+			return err
+		}
+	}
+
 	newLines, err := c.makeNewLines()
 	if err != nil {
 		return err
@@ -75,6 +98,56 @@ func (c *UpdateCmd) Exec() error {
 	return nil
 }
 
+// readValue reads the value from the chosen source when it was not given on the command line.
+func (c *UpdateCmd) readValue() error {
+	if !c.Options.Source.IsSet() {
+		return nil
+	}
+	value, err := c.Options.Source.Read(c.Options.Key)
+	if err != nil {
+		return err
+	}
+	c.Options.Value = value
+
+	return nil
+}
+
+// hasValue reports whether the effective (last) line of the key has a non-empty value.
+func (c *UpdateCmd) hasValue() bool {
+	indexes := lines.KeyIndexes(c.OrgLines, c.Options.Key)
+	if len(indexes) == 0 {
+		return false
+	}
+	value, _ := lines.Value(c.OrgLines[indexes[len(indexes)-1]])
+	return value != ""
+}
+
+// isEncrypted reports whether any line of the key holds an encrypted value,
+// in which case the new value is encrypted as well.
+func (c *UpdateCmd) isEncrypted() bool {
+	for _, i := range lines.KeyIndexes(c.OrgLines, c.Options.Key) {
+		if value, _ := lines.Value(c.OrgLines[i]); crypt.IsEncryptedValue(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// encryptValue replaces the value with its encrypted form, keeping the key readable.
+func (c *UpdateCmd) encryptValue() error {
+	keys, err := envfile.LoadValueKeys(c.OrgLines, c.Options.Keys, true)
+	if err != nil {
+		return err
+	}
+	value, err := crypt.NewValueCipher(keys).Encrypt(c.Options.Key, c.Options.Value)
+	if err != nil {
+		return err
+	}
+	c.Options.Value = value
+
+	return nil
+}
+
 // readLines reads all lines from the file specified in UpdateCmd and stores them in OrgLines.
 func (c *UpdateCmd) readLines() error {
 	lines, err := fs.ReadLines(c.filePath())
@@ -87,6 +160,7 @@ func (c *UpdateCmd) readLines() error {
 }
 
 // makeNewLines returns a new slice of lines with the updated value if the key matches.
+// If the key is defined more than once, only the lines of the chosen occurrence are updated.
 // If no matching key is found, returns ErrNoUpdated.
 func (c *UpdateCmd) makeNewLines() ([]string, error) {
 	if len(c.OrgLines) == 0 {
@@ -95,26 +169,28 @@ func (c *UpdateCmd) makeNewLines() ([]string, error) {
 
 	newLines := slices.Clone(c.OrgLines)
 
-	updatedFlag := false
-	for i, line := range c.OrgLines {
-		parts := strings.Split(line, "=")
-		if len(parts) < 2 {
-			continue
-		}
-		key := strings.TrimSpace(parts[0])
-		if c.keyEqual(key) {
-			if strings.HasSuffix(line, "\n") {
-				newLines[i] = c.keyAndValue() + "\n"
-			} else {
-				newLines[i] = c.keyAndValue()
-			}
-			updatedFlag = true
-			break
-		}
+	indexes := lines.KeyIndexes(c.OrgLines, c.Options.Key)
+	if len(indexes) == 0 {
+		return newLines, ErrNoUpdated
+	}
+	indexes, err := lines.SelectOccurrences(c.Options.Key, indexes, c.Options.Occurrence)
+	if errors.Is(err, lines.ErrAmbiguousKey) {
+		return newLines, fmt.Errorf("%w (use --all, --first or --last)", err)
+	}
+	if err != nil {
+		return newLines, err
 	}
 
-	if !updatedFlag {
-		return newLines, ErrNoUpdated
+	line, err := c.keyAndValue()
+	if err != nil {
+		return newLines, err
+	}
+	for _, i := range indexes {
+		if strings.HasSuffix(c.OrgLines[i], "\n") {
+			newLines[i] = line + "\n"
+		} else {
+			newLines[i] = line
+		}
 	}
 
 	return newLines, nil
@@ -134,42 +210,61 @@ func (s *UpdateCmd) filePath() string {
 	return s.Options.FilePath
 }
 
-// keyEqual checks if the given key matches the update target key.
-func (s *UpdateCmd) keyEqual(key string) bool {
-	return s.Options.Key == key
-}
-
 // keyAndValue returns the key and quoted value in the format KEY="value".
-func (s *UpdateCmd) keyAndValue() string {
-	return s.Options.Key + "=" + strconv.Quote(s.Options.Value)
+// The value is quoted so that dotenv loaders read it back unchanged.
+func (s *UpdateCmd) keyAndValue() (string, error) {
+	value, err := lines.Quote(s.Options.Value)
+	if err != nil {
+		return "", err
+	}
+	return s.Options.Key + "=" + value, nil
 }
 
 // ParseUpdateOptions parses command-line arguments and returns an UpdateOptions struct.
 func ParseUpdateOptions(opts []string) (*UpdateOptions, error) {
 	flagSet := flag.NewFlagSet("update", flag.ContinueOnError)
 	file := flagSet.String("f", "", "Path to .env file")
+	all := flagSet.Bool("all", false, "Update every line of a key defined more than once")
+	first := flagSet.Bool("first", false, "Update only the first line of a key defined more than once")
+	last := flagSet.Bool("last", false, "Update only the last (effective) line of a key defined more than once")
+	flagSet.BoolVar(last, "effective", false, "Alias for --last")
+	ifMissing := flagSet.Bool("if-missing", false, "Do nothing if the key already has a non-empty value")
+	secret := flagSet.Bool("secret", false, "Encrypt the value, keeping the key readable (implied if the current value is encrypted)")
 
-	var key, value string
+	var keys crypt.KeyOptions
+	keys.AddFlags(flagSet)
+
+	var source input.ValueSource
+	source.AddFlags(flagSet)
+
+	args := []string{}
+	for len(opts) > 0 && len(args) < 2 && !strings.HasPrefix(opts[0], "-") {
+		args = append(args, opts[0])
+		opts = opts[1:]
+	}
+	if err := flagSet.Parse(opts); err != nil {
+		return nil, err
+	}
+	args = append(args, flagSet.Args()...)
 
-	if len(opts) >= 2 && !strings.HasPrefix(opts[0], "-") && !strings.HasPrefix(opts[1], "-") {
-		key = opts[0]
-		value = opts[1]
-		if err := flagSet.Parse(opts[2:]); err != nil {
-			return nil, err
+	if err := source.Validate(); err != nil {
+		return nil, err
+	}
+	var key, value string
+	if source.IsSet() {
+		if len(args) != 1 {
+			return nil, errors.New("only the key is allowed when the value is read from another source")
 		}
+		key = args[0]
 	} else {
-		if err := flagSet.Parse(opts); err != nil {
-			return nil, err
-		}
-		args := flagSet.Args()
 		if len(args) < 2 {
 			return nil, errors.New("key and value are required")
 		}
 		key = args[0]
 		value = args[1]
-		if strings.HasPrefix(key, "-") || strings.HasPrefix(value, "-") {
-			return nil, errors.New("key and value are required")
-		}
+	}
+	if strings.HasPrefix(key, "-") || strings.HasPrefix(value, "-") {
+		return nil, errors.New("key and value are required")
 	}
 	if *file == "" {
 		fmt.Println("Error: -f flag is required")
@@ -177,9 +272,19 @@ func ParseUpdateOptions(opts []string) (*UpdateOptions, error) {
 		return nil, errors.New("file path is required")
 	}
 
+	occurrence, err := lines.ParseOccurrence(*all, *first, *last)
+	if err != nil {
+		return nil, err
+	}
+
 	return &UpdateOptions{
-		Key:      key,
-		Value:    value,
-		FilePath: *file,
+		Key:        key,
+		Value:      value,
+		FilePath:   *file,
+		Occurrence: occurrence,
+		Source:     source,
+		IfMissing:  *ifMissing,
+		Secret:     *secret,
+		Keys:       keys,
 	}, nil
 }
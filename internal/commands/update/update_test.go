@@ -1,18 +1,25 @@
 package update
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/ba58ajbse/envcraft/internal/input"
+	"github.com/ba58ajbse/envcraft/internal/lines"
 	"github.com/stretchr/testify/assert"
 )
 
 func Test_makeNewLines(t *testing.T) {
 	tests := map[string]struct {
-		orgLines []string
-		key      string
-		value    string
-		want     []string
-		wantErr  bool
+		orgLines   []string
+		key        string
+		value      string
+		occurrence string
+		want       []string
+		wantErr    bool
 	}{
 		"update existing key": {
 			orgLines: []string{"FOO=\"bar\"\n", "BAR=\"baz\"\n"},
@@ -35,13 +42,51 @@ func Test_makeNewLines(t *testing.T) {
 			want:     []string{},
 			wantErr:  true,
 		},
+		"duplicate key without occurrence": {
+			orgLines: []string{"FOO=\"bar\"\n", "FOO=\"baz\"\n"},
+			key:      "FOO",
+			value:    "newval",
+			wantErr:  true,
+		},
+		"duplicate key, update last": {
+			orgLines:   []string{"FOO=\"bar\"\n", "BAR=\"baz\"\n", "FOO=\"baz\""},
+			key:        "FOO",
+			value:      "newval",
+			occurrence: "last",
+			want:       []string{"FOO=\"bar\"\n", "BAR=\"baz\"\n", "FOO=\"newval\""},
+			wantErr:    false,
+		},
+		"duplicate key, update first": {
+			orgLines:   []string{"FOO=\"bar\"\n", "BAR=\"baz\"\n", "FOO=\"baz\""},
+			key:        "FOO",
+			value:      "newval",
+			occurrence: "first",
+			want:       []string{"FOO=\"newval\"\n", "BAR=\"baz\"\n", "FOO=\"baz\""},
+			wantErr:    false,
+		},
+		"duplicate key, update all": {
+			orgLines:   []string{"FOO=\"bar\"\n", "BAR=\"baz\"\n", "FOO=\"baz\""},
+			key:        "FOO",
+			value:      "newval",
+			occurrence: "all",
+			want:       []string{"FOO=\"newval\"\n", "BAR=\"baz\"\n", "FOO=\"newval\""},
+			wantErr:    false,
+		},
+		"commented key is not updated": {
+			orgLines: []string{"# FOO=\"old\"\n", "FOO=\"bar\"\n"},
+			key:      "FOO",
+			value:    "newval",
+			want:     []string{"# FOO=\"old\"\n", "FOO=\"newval\"\n"},
+			wantErr:  false,
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			cmd := &UpdateCmd{
 				Options: UpdateOptions{
-					Key:   tt.key,
-					Value: tt.value,
+					Key:        tt.key,
+					Value:      tt.value,
+					Occurrence: tt.occurrence,
 				},
 				OrgLines: tt.orgLines,
 			}
@@ -56,6 +101,16 @@ func Test_makeNewLines(t *testing.T) {
 	}
 }
 
+func Test_makeNewLines_DuplicateKeyHint(t *testing.T) {
+	cmd := &UpdateCmd{
+		Options:  UpdateOptions{Key: "FOO", Value: "newval"},
+		OrgLines: []string{"FOO=\"bar\"\n", "FOO=\"baz\"\n"},
+	}
+	_, err := cmd.makeNewLines()
+	assert.ErrorIs(t, err, lines.ErrAmbiguousKey)
+	assert.EqualError(t, err, "key defined more than once: FOO on lines 1, 2 (use --all, --first or --last)")
+}
+
 func TestParseUpdateOptions(t *testing.T) {
 	tests := map[string]struct {
 		opts    []string
@@ -72,6 +127,21 @@ func TestParseUpdateOptions(t *testing.T) {
 			want:    &UpdateOptions{Key: "KEY", Value: "VALUE", FilePath: "test.env"},
 			wantErr: false,
 		},
+		"last flag": {
+			opts:    []string{"KEY", "VALUE", "-f", "test.env", "--last"},
+			want:    &UpdateOptions{Key: "KEY", Value: "VALUE", FilePath: "test.env", Occurrence: "last"},
+			wantErr: false,
+		},
+		"conflicting occurrence flags": {
+			opts:    []string{"KEY", "VALUE", "-f", "test.env", "--first", "--all"},
+			want:    nil,
+			wantErr: true,
+		},
+		"value from file": {
+			opts:    []string{"KEY", "--value-file", "secret.txt", "-f", "test.env"},
+			want:    &UpdateOptions{Key: "KEY", FilePath: "test.env", Source: input.ValueSource{ValueFile: "secret.txt"}},
+			wantErr: false,
+		},
 		"missing value": {
 			opts:    []string{"KEY", "-f", "test.env"},
 			want:    nil,
@@ -95,3 +165,32 @@ func TestParseUpdateOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestExec_KeepsEncryptedValueEncrypted(t *testing.T) {
+	tmpDir := t.TempDir()
+	keyFile := filepath.Join(tmpDir, "team.key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("0123456789abcdef"), 0600))
+	keys := crypt.Keys{KeyFile: []byte("0123456789abcdef")}
+
+	encrypted, err := crypt.NewValueCipher(keys).Encrypt("API_KEY", "old")
+	assert.NoError(t, err)
+	envFile := filepath.Join(tmpDir, ".env")
+	assert.NoError(t, os.WriteFile(envFile, []byte("# API access\nAPI_KEY=\""+encrypted+"\"\nPLAIN=\"x\"\n"), 0644))
+
+	cmd, err := NewUpdateCmd(&UpdateOptions{Key: "API_KEY", Value: "new", FilePath: envFile, Keys: crypt.KeyOptions{KeyFile: keyFile}})
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Exec())
+
+	envLines, err := fs.ReadLines(envFile)
+	assert.NoError(t, err)
+	assert.Len(t, envLines, 3)
+	assert.Equal(t, "# API access\n", envLines[0])
+	assert.Equal(t, "PLAIN=\"x\"\n", envLines[2])
+
+	value, ok := lines.Value(envLines[1])
+	assert.True(t, ok)
+	assert.True(t, crypt.IsEncryptedValue(value))
+	got, err := crypt.NewValueCipher(keys).Decrypt("API_KEY", value)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", got)
+}
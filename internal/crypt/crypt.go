@@ -0,0 +1,343 @@
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// An encrypted file is a text envelope: a header line, one stanza per way of unlocking the
+// random data key, a separator and the payload sealed with the data key using AES-256-GCM.
+//
+//	envcraft-encrypted v1
+//	scrypt <salt> <log2 N> <wrapped data key>
+//	keyfile <wrapped data key>
+//	x25519 <ephemeral public key> <wrapped data key>
+//	---
+//	<payload>
+const (
+	header    = "envcraft-encrypted v1"
+	separator = "---"
+
+	dataKeySize = 32
+	scryptLogN  = 15
+	scryptR     = 8
+	scryptP     = 1
+)
+
+// ErrNoKey is returned when no passphrase, key file, identity or recipient is available.
+var ErrNoKey = errors.New("a passphrase, key file or recipient is required")
+
+// ErrDecrypt is returned when none of the available keys can unlock a file.
+var ErrDecrypt = errors.New("unable to decrypt: wrong passphrase or key")
+
+// Keys holds the secrets that can lock or unlock the data key of an encrypted file.
+// Recipients can only lock it; their Identities unlock it.
+type Keys struct {
+	Passphrase []byte
+	KeyFile    []byte
+	Identities []*ecdh.PrivateKey
+	Recipients []*ecdh.PublicKey
+}
+
+// MACKey derives a key for hashing values with HMAC from the key file, passphrase or first
+// identity of keys, so that hashes shown to others cannot be brute-forced without the key.
+// purpose separates the keys of different uses. Returns ErrNoKey if keys hold none of them.
+func (k Keys) MACKey(purpose string) ([]byte, error) {
+	var secret []byte
+	switch {
+	case len(k.KeyFile) > 0:
+		secret = bytes.TrimSpace(k.KeyFile)
+	case len(k.Passphrase) > 0:
+		secret = k.Passphrase
+	case len(k.Identities) > 0:
+		secret = k.Identities[0].Bytes()
+	default:
+		return nil, ErrNoKey
+	}
+	return hkdf.Key(sha256.New, secret, nil, "envcraft mac "+purpose, 32)
+}
+
+// Stanza is one way of unlocking the data key, such as a passphrase or a key file.
+type Stanza struct {
+	Type string
+	Args []string
+}
+
+// Envelope is the parsed form of an encrypted file.
+type Envelope struct {
+	Stanzas []Stanza
+	Payload []byte
+}
+
+// IsEncrypted reports whether data is an encrypted envelope.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(header+"\n"))
+}
+
+// Encrypt seals plaintext with a new data key and locks the data key with every available key
+// and for every recipient.
+func Encrypt(plaintext []byte, keys Keys) ([]byte, error) {
+	if len(keys.Passphrase) == 0 && len(keys.KeyFile) == 0 && len(keys.Recipients) == 0 {
+		return nil, ErrNoKey
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("error generating data key: %w", err)
+	}
+
+	stanzas, err := lockStanzas(keys, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return Seal(plaintext, dataKey, stanzas)
+}
+
+// Decrypt opens an encrypted envelope with the first key that unlocks its data key.
+func Decrypt(data []byte, keys Keys) ([]byte, error) {
+	envelope, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := envelope.DataKey(keys)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Open(dataKey)
+}
+
+// Seal encrypts plaintext with dataKey and returns the envelope text carrying stanzas.
+func Seal(plaintext, dataKey []byte, stanzas []Stanza) ([]byte, error) {
+	payload, err := seal(dataKey, plaintext, []byte(header))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(header + "\n")
+	for _, stanza := range stanzas {
+		buf.WriteString(strings.Join(append([]string{stanza.Type}, stanza.Args...), " ") + "\n")
+	}
+	buf.WriteString(separator + "\n")
+	buf.WriteString(encode(payload) + "\n")
+	return buf.Bytes(), nil
+}
+
+// Parse parses an encrypted envelope without decrypting it.
+func Parse(data []byte) (*Envelope, error) {
+	if !IsEncrypted(data) {
+		return nil, errors.New("not an envcraft encrypted file")
+	}
+
+	envelope := &Envelope{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == separator {
+			break
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		envelope.Stanzas = append(envelope.Stanzas, Stanza{Type: fields[0], Args: fields[1:]})
+	}
+	if !scanner.Scan() {
+		return nil, errors.New("encrypted file has no payload")
+	}
+	payload, err := decode(strings.TrimSpace(scanner.Text()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	envelope.Payload = payload
+
+	return envelope, nil
+}
+
+// DataKey unlocks the data key with the first stanza that matches one of keys.
+func (e *Envelope) DataKey(keys Keys) ([]byte, error) {
+	for _, stanza := range e.Stanzas {
+		switch {
+		case stanza.Type == "scrypt" && len(keys.Passphrase) > 0:
+			if dataKey, err := unwrapScrypt(stanza, keys.Passphrase); err == nil {
+				return dataKey, nil
+			}
+		case stanza.Type == "keyfile" && len(keys.KeyFile) > 0:
+			if dataKey, err := unwrapKeyFile(stanza, keys.KeyFile); err == nil {
+				return dataKey, nil
+			}
+		case stanza.Type == "x25519":
+			for _, identity := range keys.Identities {
+				if dataKey, err := unwrapX25519(stanza, identity); err == nil {
+					return dataKey, nil
+				}
+			}
+		}
+	}
+	return nil, ErrDecrypt
+}
+
+// Open decrypts the payload with dataKey.
+func (e *Envelope) Open(dataKey []byte) ([]byte, error) {
+	plaintext, err := open(dataKey, e.Payload, []byte(header))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// lockStanzas locks dataKey with the passphrase and key file of keys and for each of its recipients.
+func lockStanzas(keys Keys, dataKey []byte) ([]Stanza, error) {
+	stanzas := []Stanza{}
+	if len(keys.Passphrase) > 0 {
+		stanza, err := wrapScrypt(keys.Passphrase, dataKey)
+		if err != nil {
+			return nil, err
+		}
+		stanzas = append(stanzas, stanza)
+	}
+	if len(keys.KeyFile) > 0 {
+		stanza, err := wrapKeyFile(keys.KeyFile, dataKey)
+		if err != nil {
+			return nil, err
+		}
+		stanzas = append(stanzas, stanza)
+	}
+	for _, recipient := range keys.Recipients {
+		stanza, err := wrapX25519(recipient, dataKey)
+		if err != nil {
+			return nil, err
+		}
+		stanzas = append(stanzas, stanza)
+	}
+	return stanzas, nil
+}
+
+func wrapScrypt(passphrase, dataKey []byte) (Stanza, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return Stanza{}, fmt.Errorf("error generating salt: %w", err)
+	}
+	kek, err := scrypt.Key(passphrase, salt, 1<<scryptLogN, scryptR, scryptP, 32)
+	if err != nil {
+		return Stanza{}, fmt.Errorf("error deriving key: %w", err)
+	}
+	wrapped, err := seal(kek, dataKey, []byte("scrypt"))
+	if err != nil {
+		return Stanza{}, err
+	}
+	return Stanza{Type: "scrypt", Args: []string{encode(salt), strconv.Itoa(scryptLogN), encode(wrapped)}}, nil
+}
+
+func unwrapScrypt(stanza Stanza, passphrase []byte) ([]byte, error) {
+	if len(stanza.Args) != 3 {
+		return nil, errors.New("invalid scrypt stanza")
+	}
+	salt, err := decode(stanza.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	logN, err := strconv.Atoi(stanza.Args[1])
+	if err != nil || logN < 1 || logN > 22 {
+		return nil, errors.New("invalid scrypt work factor")
+	}
+	wrapped, err := decode(stanza.Args[2])
+	if err != nil {
+		return nil, err
+	}
+	kek, err := scrypt.Key(passphrase, salt, 1<<logN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	return open(kek, wrapped, []byte("scrypt"))
+}
+
+func wrapKeyFile(keyFile, dataKey []byte) (Stanza, error) {
+	kek, err := keyFileKEK(keyFile)
+	if err != nil {
+		return Stanza{}, err
+	}
+	wrapped, err := seal(kek, dataKey, []byte("keyfile"))
+	if err != nil {
+		return Stanza{}, err
+	}
+	return Stanza{Type: "keyfile", Args: []string{encode(wrapped)}}, nil
+}
+
+func unwrapKeyFile(stanza Stanza, keyFile []byte) ([]byte, error) {
+	if len(stanza.Args) != 1 {
+		return nil, errors.New("invalid keyfile stanza")
+	}
+	wrapped, err := decode(stanza.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	kek, err := keyFileKEK(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return open(kek, wrapped, []byte("keyfile"))
+}
+
+// keyFileKEK derives the key that locks the data key from the contents of a key file.
+func keyFileKEK(keyFile []byte) ([]byte, error) {
+	return hkdf.Key(sha256.New, bytes.TrimSpace(keyFile), nil, "envcraft keyfile", 32)
+}
+
+// seal encrypts plaintext with AES-256-GCM and returns nonce||ciphertext.
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open reverses seal.
+func open(key, data, aad []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func encode(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(s)
+}
@@ -0,0 +1,163 @@
+package crypt
+
+import (
+	"crypto/ecdh"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	plaintext := []byte("FOO=\"bar\"\nSECRET=\"s3cr3t\"\n")
+
+	tests := map[string]struct {
+		lock    Keys
+		unlock  Keys
+		wantErr error
+	}{
+		"passphrase": {
+			lock:   Keys{Passphrase: []byte("correct horse")},
+			unlock: Keys{Passphrase: []byte("correct horse")},
+		},
+		"key file": {
+			lock:   Keys{KeyFile: []byte("0123456789abcdef0123456789abcdef\n")},
+			unlock: Keys{KeyFile: []byte("0123456789abcdef0123456789abcdef")},
+		},
+		"either key unlocks": {
+			lock:   Keys{Passphrase: []byte("correct horse"), KeyFile: []byte("key")},
+			unlock: Keys{KeyFile: []byte("key")},
+		},
+		"wrong passphrase": {
+			lock:    Keys{Passphrase: []byte("correct horse")},
+			unlock:  Keys{Passphrase: []byte("battery staple")},
+			wantErr: ErrDecrypt,
+		},
+		"passphrase does not unlock key file stanza": {
+			lock:    Keys{KeyFile: []byte("key")},
+			unlock:  Keys{Passphrase: []byte("key")},
+			wantErr: ErrDecrypt,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := Encrypt(plaintext, tt.lock)
+			assert.NoError(t, err)
+			assert.True(t, IsEncrypted(data))
+			assert.NotContains(t, string(data), "s3cr3t")
+
+			got, err := Decrypt(data, tt.unlock)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, plaintext, got)
+			}
+		})
+	}
+}
+
+func TestEncrypt_NoKey(t *testing.T) {
+	_, err := Encrypt([]byte("FOO=bar"), Keys{})
+	assert.ErrorIs(t, err, ErrNoKey)
+}
+
+func TestDecrypt_TamperedPayload(t *testing.T) {
+	keys := Keys{KeyFile: []byte("key")}
+	data, err := Encrypt([]byte("FOO=bar"), keys)
+	assert.NoError(t, err)
+
+	envelope, err := Parse(data)
+	assert.NoError(t, err)
+	envelope.Payload[len(envelope.Payload)-1] ^= 0xff
+	dataKey, err := envelope.DataKey(keys)
+	assert.NoError(t, err)
+	_, err = envelope.Open(dataKey)
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
+
+func TestValueCipher(t *testing.T) {
+	tests := map[string]struct {
+		lock    Keys
+		unlock  Keys
+		wantErr error
+	}{
+		"passphrase": {
+			lock:   Keys{Passphrase: []byte("correct horse")},
+			unlock: Keys{Passphrase: []byte("correct horse")},
+		},
+		"key file": {
+			lock:   Keys{KeyFile: []byte("key")},
+			unlock: Keys{KeyFile: []byte("key")},
+		},
+		"wrong key file": {
+			lock:    Keys{KeyFile: []byte("key")},
+			unlock:  Keys{KeyFile: []byte("other")},
+			wantErr: ErrDecrypt,
+		},
+		"passphrase for key file value": {
+			lock:    Keys{KeyFile: []byte("key")},
+			unlock:  Keys{Passphrase: []byte("key")},
+			wantErr: ErrNoKey,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			encrypted, err := NewValueCipher(tt.lock).Encrypt("API_KEY", "s3cr3t")
+			assert.NoError(t, err)
+			assert.True(t, IsEncryptedValue(encrypted))
+			assert.NotContains(t, encrypted, "s3cr3t")
+
+			got, err := NewValueCipher(tt.unlock).Decrypt("API_KEY", encrypted)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "s3cr3t", got)
+			}
+		})
+	}
+}
+
+func TestValueCipher_BoundToKey(t *testing.T) {
+	cipher := NewValueCipher(Keys{KeyFile: []byte("key")})
+	encrypted, err := cipher.Encrypt("API_KEY", "s3cr3t")
+	assert.NoError(t, err)
+
+	_, err = cipher.Decrypt("OTHER_KEY", encrypted)
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	plain, err := cipher.Decrypt("PLAIN", "not encrypted")
+	assert.NoError(t, err)
+	assert.Equal(t, "not encrypted", plain)
+}
+
+func TestKeys_MACKey(t *testing.T) {
+	identity, err := GenerateIdentity()
+	assert.NoError(t, err)
+
+	tests := map[string]struct {
+		keys    Keys
+		purpose string
+		same    bool
+	}{
+		"key file before passphrase": {keys: Keys{KeyFile: []byte("key\n"), Passphrase: []byte("pass")}, purpose: "test", same: true},
+		"passphrase":                 {keys: Keys{Passphrase: []byte("key")}, purpose: "test", same: true},
+		"other purpose":              {keys: Keys{KeyFile: []byte("key")}, purpose: "other", same: false},
+		"identity":                   {keys: Keys{Identities: []*ecdh.PrivateKey{identity}}, purpose: "test", same: false},
+	}
+
+	want, err := Keys{KeyFile: []byte("key")}.MACKey("test")
+	assert.NoError(t, err)
+	assert.Len(t, want, 32)
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tt.keys.MACKey(tt.purpose)
+			assert.NoError(t, err)
+			assert.Len(t, got, 32)
+			assert.Equal(t, tt.same, string(got) == string(want))
+		})
+	}
+
+	_, err = Keys{}.MACKey("test")
+	assert.ErrorIs(t, err, ErrNoKey)
+}
@@ -0,0 +1,182 @@
+package crypt
+
+import (
+	"crypto/ecdh"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/input"
+)
+
+// Environment variables consulted when the corresponding flag is not set.
+const (
+	EnvPassphrase = "ENVCRAFT_PASSPHRASE"
+	EnvKeyFile    = "ENVCRAFT_KEY_FILE"
+	EnvIdentity   = "ENVCRAFT_IDENTITY"
+)
+
+// DefaultRecipientsFile is the recipients file used when it exists and no other is given.
+const DefaultRecipientsFile = ".envcraft-recipients"
+
+// KeyOptions selects where the passphrase, key file, identity and recipients used to lock and
+// unlock files come from.
+type KeyOptions struct {
+	KeyFile        string
+	PassphraseFile string
+	Identity       string
+	Recipients     string
+	// NoPrompt makes Load return ErrNoKey instead of asking for the passphrase,
+	// for commands run by other tools such as git.
+	NoPrompt bool
+}
+
+// AddFlags registers the --key-file and --passphrase-file flags on flagSet.
+func (o *KeyOptions) AddFlags(flagSet *flag.FlagSet) {
+	flagSet.StringVar(&o.KeyFile, "key-file", "", "Path to a key file (default $"+EnvKeyFile+")")
+	flagSet.StringVar(&o.PassphraseFile, "passphrase-file", "", "Read the passphrase from a file (default $"+EnvPassphrase+" or prompt)")
+}
+
+// AddRecipientFlags registers the --identity and --recipients flags used for whole files on flagSet.
+func (o *KeyOptions) AddRecipientFlags(flagSet *flag.FlagSet) {
+	flagSet.StringVar(&o.Identity, "identity", "", "Path to an X25519 identity file (default $"+EnvIdentity+" or "+DefaultIdentityPath()+")")
+	flagSet.StringVar(&o.Recipients, "recipients", "", "Path to a recipients file (default "+DefaultRecipientsFile+" if it exists)")
+}
+
+// DefaultIdentityPath returns where keys generate stores the identity of the current user.
+func DefaultIdentityPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(".envcraft", "identity")
+	}
+	return filepath.Join(dir, "envcraft", "identity")
+}
+
+// LoadFile reads the keys used to encrypt or decrypt a whole file: the recipients when encrypting,
+// the identities when decrypting. The passphrase or key file is used as well when configured,
+// and prompted for only if there are no recipients or identities.
+func (o KeyOptions) LoadFile(encrypting bool) (Keys, error) {
+	keys := Keys{}
+	var err error
+	if encrypting {
+		keys.Recipients, err = o.LoadRecipients()
+	} else {
+		keys.Identities, err = o.LoadIdentities()
+	}
+	if err != nil {
+		return keys, err
+	}
+	if (len(keys.Recipients) > 0 || len(keys.Identities) > 0) && !o.hasSecret() {
+		return keys, nil
+	}
+
+	secrets, err := o.Load(encrypting)
+	if err != nil {
+		return keys, err
+	}
+	keys.Passphrase = secrets.Passphrase
+	keys.KeyFile = secrets.KeyFile
+	return keys, nil
+}
+
+// LoadRecipients reads the recipients file, if one is given or the default one exists.
+func (o KeyOptions) LoadRecipients() ([]*ecdh.PublicKey, error) {
+	path := o.RecipientsPath()
+	if path == "" {
+		return nil, nil
+	}
+	return ReadRecipientsFile(path)
+}
+
+// RecipientsPath returns the recipients file in use, or an empty string if there is none.
+func (o KeyOptions) RecipientsPath() string {
+	if o.Recipients != "" {
+		return o.Recipients
+	}
+	if _, err := os.Stat(DefaultRecipientsFile); err == nil {
+		return DefaultRecipientsFile
+	}
+	return ""
+}
+
+// LoadIdentities reads the identity file, if one is given or the default one exists.
+func (o KeyOptions) LoadIdentities() ([]*ecdh.PrivateKey, error) {
+	path := o.Identity
+	if path == "" {
+		path = os.Getenv(EnvIdentity)
+	}
+	if path == "" {
+		if _, err := os.Stat(DefaultIdentityPath()); err != nil {
+			return nil, nil
+		}
+		path = DefaultIdentityPath()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading identity file %s: %w", path, err)
+	}
+	identities, err := ParseIdentities(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return identities, nil
+}
+
+// hasSecret reports whether a passphrase or key file is configured by flag or environment.
+func (o KeyOptions) hasSecret() bool {
+	return o.KeyFile != "" || o.PassphraseFile != "" || os.Getenv(EnvKeyFile) != "" || os.Getenv(EnvPassphrase) != ""
+}
+
+// Load reads the key file or passphrase, as used for single values. When neither is configured
+// the passphrase is prompted for on the terminal; confirm asks for it twice, as when encrypting.
+func (o KeyOptions) Load(confirm bool) (Keys, error) {
+	keys := Keys{}
+
+	keyFile := o.KeyFile
+	if keyFile == "" {
+		keyFile = os.Getenv(EnvKeyFile)
+	}
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return keys, fmt.Errorf("error reading key file %s: %w", keyFile, err)
+		}
+		keys.KeyFile = data
+	}
+
+	switch {
+	case o.PassphraseFile != "":
+		data, err := os.ReadFile(o.PassphraseFile)
+		if err != nil {
+			return keys, fmt.Errorf("error reading passphrase file %s: %w", o.PassphraseFile, err)
+		}
+		keys.Passphrase = []byte(strings.TrimRight(string(data), "\r\n"))
+	case os.Getenv(EnvPassphrase) != "":
+		keys.Passphrase = []byte(os.Getenv(EnvPassphrase))
+	case keyFile == "" && o.NoPrompt:
+		return keys, ErrNoKey
+	case keyFile == "":
+		passphrase, err := input.PromptHidden("Passphrase: ")
+		if err != nil {
+			return keys, fmt.Errorf("%w: %w", ErrNoKey, err)
+		}
+		if confirm {
+			again, err := input.PromptHidden("Confirm passphrase: ")
+			if err != nil {
+				return keys, err
+			}
+			if again != passphrase {
+				return keys, errors.New("passphrases do not match")
+			}
+		}
+		keys.Passphrase = []byte(passphrase)
+	}
+
+	if len(keys.Passphrase) == 0 && len(keys.KeyFile) == 0 {
+		return keys, ErrNoKey
+	}
+	return keys, nil
+}
@@ -0,0 +1,168 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ValuePrefix marks a value encrypted on its own, leaving the key, comments and ordering of
+// the file readable:
+//
+//	API_KEY="enc:v1:<scrypt|keyfile>:<salt>:<nonce and ciphertext>"
+//
+// The key name is authenticated with the value, so an encrypted value cannot be moved to another key.
+const ValuePrefix = "enc:v1:"
+
+// IsEncryptedValue reports whether value was produced by ValueCipher.Encrypt.
+func IsEncryptedValue(value string) bool {
+	return strings.HasPrefix(value, ValuePrefix)
+}
+
+// ValueCipher encrypts and decrypts single values. Derived keys are cached per salt, so a file
+// full of values locked with a passphrase costs one scrypt run per salt.
+type ValueCipher struct {
+	keys Keys
+	salt []byte
+	keks map[string][]byte
+}
+
+// NewValueCipher returns a ValueCipher using the key file if set, otherwise the passphrase.
+func NewValueCipher(keys Keys) *ValueCipher {
+	return &ValueCipher{keys: keys, keks: map[string][]byte{}}
+}
+
+// Encrypt returns the encrypted form of the value of key.
+func (c *ValueCipher) Encrypt(key, value string) (string, error) {
+	kind := "keyfile"
+	if len(c.keys.KeyFile) == 0 {
+		kind = "scrypt"
+	}
+	if c.salt == nil {
+		c.salt = make([]byte, 16)
+		if _, err := rand.Read(c.salt); err != nil {
+			return "", fmt.Errorf("error generating salt: %w", err)
+		}
+	}
+	kek, err := c.kek(kind, c.salt)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(kek, []byte(value), valueAAD(key))
+	if err != nil {
+		return "", err
+	}
+	return ValuePrefix + kind + ":" + encodeValue(c.salt) + ":" + encodeValue(sealed), nil
+}
+
+// Decrypt returns the plaintext of an encrypted value of key. Other values are returned unchanged.
+func (c *ValueCipher) Decrypt(key, value string) (string, error) {
+	if !IsEncryptedValue(value) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, ValuePrefix), ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("invalid encrypted value of %s", key)
+	}
+	salt, err := decodeValue(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value of %s: %w", key, err)
+	}
+	sealed, err := decodeValue(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value of %s: %w", key, err)
+	}
+	kek, err := c.kek(parts[0], salt)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(kek, sealed, valueAAD(key))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrDecrypt, key)
+	}
+	return string(plaintext), nil
+}
+
+// ErrKeyMismatch is returned when the keys do not decrypt the values already encrypted in a file.
+var ErrKeyMismatch = errors.New("the key does not decrypt the values already encrypted in the file")
+
+// Verify checks that the keys decrypt an encrypted value of key already in a file before a new
+// value is encrypted next to it: a mistyped passphrase would otherwise lock the new value with
+// a key that no longer opens the rest of the file.
+func (c *ValueCipher) Verify(key, value string) error {
+	if _, err := c.Decrypt(key, value); err != nil {
+		return fmt.Errorf("%w: %w", ErrKeyMismatch, err)
+	}
+	return nil
+}
+
+// DecryptEnv decrypts every encrypted value of env in place.
+func (c *ValueCipher) DecryptEnv(env map[string]string) error {
+	for key, value := range env {
+		plaintext, err := c.Decrypt(key, value)
+		if err != nil {
+			return err
+		}
+		env[key] = plaintext
+	}
+	return nil
+}
+
+// HasEncryptedValues reports whether any value of env is encrypted.
+func HasEncryptedValues(env map[string]string) bool {
+	for _, value := range env {
+		if IsEncryptedValue(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// kek derives the key that encrypts values for the given kind and salt.
+func (c *ValueCipher) kek(kind string, salt []byte) ([]byte, error) {
+	cacheKey := kind + ":" + string(salt)
+	if kek, ok := c.keks[cacheKey]; ok {
+		return kek, nil
+	}
+
+	var kek []byte
+	var err error
+	switch kind {
+	case "keyfile":
+		if len(c.keys.KeyFile) == 0 {
+			return nil, fmt.Errorf("%w: value was encrypted with a key file", ErrNoKey)
+		}
+		kek, err = hkdf.Key(sha256.New, bytes.TrimSpace(c.keys.KeyFile), salt, "envcraft value", 32)
+	case "scrypt":
+		if len(c.keys.Passphrase) == 0 {
+			return nil, fmt.Errorf("%w: value was encrypted with a passphrase", ErrNoKey)
+		}
+		kek, err = scrypt.Key(c.keys.Passphrase, salt, 1<<scryptLogN, scryptR, scryptP, 32)
+	default:
+		return nil, errors.New("unknown value encryption " + kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error deriving key: %w", err)
+	}
+	c.keks[cacheKey] = kek
+	return kek, nil
+}
+
+func valueAAD(key string) []byte {
+	return []byte("envcraft value v1 " + key)
+}
+
+func encodeValue(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeValue(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,221 @@
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Text forms of X25519 keys. A recipient (public key) is shared in the recipients file;
+// an identity (private key) stays with its owner.
+const (
+	RecipientPrefix = "x25519:"
+	IdentityPrefix  = "ENVCRAFT-X25519-IDENTITY:"
+)
+
+// GenerateIdentity returns a new X25519 private key.
+func GenerateIdentity() (*ecdh.PrivateKey, error) {
+	identity, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generating key: %w", err)
+	}
+	return identity, nil
+}
+
+// FormatRecipient returns the text form of a public key.
+func FormatRecipient(recipient *ecdh.PublicKey) string {
+	return RecipientPrefix + encodeValue(recipient.Bytes())
+}
+
+// ParseRecipient parses the text form of a public key.
+func ParseRecipient(s string) (*ecdh.PublicKey, error) {
+	encoded, found := strings.CutPrefix(strings.TrimSpace(s), RecipientPrefix)
+	if !found {
+		return nil, fmt.Errorf("invalid recipient %q: must start with %s", s, RecipientPrefix)
+	}
+	b, err := decodeValue(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", s, err)
+	}
+	recipient, err := ecdh.X25519().NewPublicKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", s, err)
+	}
+	return recipient, nil
+}
+
+// FormatIdentity returns the text form of a private key.
+func FormatIdentity(identity *ecdh.PrivateKey) string {
+	return IdentityPrefix + encodeValue(identity.Bytes())
+}
+
+// ParseIdentities parses the private keys of an identity file, ignoring blank lines and comments.
+func ParseIdentities(data []byte) ([]*ecdh.PrivateKey, error) {
+	identities := []*ecdh.PrivateKey{}
+	for _, line := range keyFileLines(data) {
+		encoded, found := strings.CutPrefix(line, IdentityPrefix)
+		if !found {
+			return nil, errors.New("invalid identity: must start with " + IdentityPrefix)
+		}
+		b, err := decodeValue(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity: %w", err)
+		}
+		identity, err := ecdh.X25519().NewPrivateKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+	if len(identities) == 0 {
+		return nil, errors.New("no identity found")
+	}
+	return identities, nil
+}
+
+// ReadRecipientsFile reads the public keys listed one per line in path.
+// Text after # is a comment, typically naming the owner of the key.
+func ReadRecipientsFile(path string) ([]*ecdh.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading recipients file %s: %w", path, err)
+	}
+	recipients, err := ParseRecipients(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return recipients, nil
+}
+
+// ParseRecipients parses the content of a recipients file.
+func ParseRecipients(data []byte) ([]*ecdh.PublicKey, error) {
+	recipients := []*ecdh.PublicKey{}
+	for _, line := range keyFileLines(data) {
+		recipient, err := ParseRecipient(line)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// keyFileLines returns the non-empty lines of data with comments removed.
+func keyFileLines(data []byte) []string {
+	result := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// Rewrap re-locks the data key of an encrypted file for the recipients of keys, unlocking it
+// with keys first. The passphrase and key file stanzas are kept as they are.
+//
+// With rotate set, a new data key is generated and the payload re-encrypted, so a recipient who
+// was removed cannot use a data key they unwrapped before. Passphrase and key file stanzas are
+// then only kept if keys holds the passphrase or key file to lock the new data key with.
+func Rewrap(data []byte, keys Keys, rotate bool) ([]byte, error) {
+	envelope, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := envelope.DataKey(keys)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := envelope.Open(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var stanzas []Stanza
+	if rotate {
+		dataKey = make([]byte, dataKeySize)
+		if _, err := rand.Read(dataKey); err != nil {
+			return nil, fmt.Errorf("error generating data key: %w", err)
+		}
+		if stanzas, err = lockStanzas(keys, dataKey); err != nil {
+			return nil, err
+		}
+	} else {
+		stanzas = slices.DeleteFunc(slices.Clone(envelope.Stanzas), func(s Stanza) bool {
+			return s.Type == "x25519"
+		})
+		for _, recipient := range keys.Recipients {
+			stanza, err := wrapX25519(recipient, dataKey)
+			if err != nil {
+				return nil, err
+			}
+			stanzas = append(stanzas, stanza)
+		}
+	}
+	if len(stanzas) == 0 {
+		return nil, ErrNoKey
+	}
+
+	return Seal(plaintext, dataKey, stanzas)
+}
+
+// wrapX25519 locks dataKey for recipient with a key agreed between a new ephemeral key and the recipient.
+func wrapX25519(recipient *ecdh.PublicKey, dataKey []byte) (Stanza, error) {
+	ephemeral, err := GenerateIdentity()
+	if err != nil {
+		return Stanza{}, err
+	}
+	kek, err := x25519KEK(ephemeral, recipient, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return Stanza{}, err
+	}
+	wrapped, err := seal(kek, dataKey, []byte("x25519"))
+	if err != nil {
+		return Stanza{}, err
+	}
+	return Stanza{Type: "x25519", Args: []string{encodeValue(ephemeral.PublicKey().Bytes()), encodeValue(wrapped)}}, nil
+}
+
+func unwrapX25519(stanza Stanza, identity *ecdh.PrivateKey) ([]byte, error) {
+	if len(stanza.Args) != 2 {
+		return nil, errors.New("invalid x25519 stanza")
+	}
+	b, err := decodeValue(stanza.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(b)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := decodeValue(stanza.Args[1])
+	if err != nil {
+		return nil, err
+	}
+	kek, err := x25519KEK(identity, ephemeral, ephemeral, identity.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	return open(kek, wrapped, []byte("x25519"))
+}
+
+// x25519KEK derives the key that locks the data key from the shared secret of private and peer,
+// bound to both public keys of the exchange.
+func x25519KEK(private *ecdh.PrivateKey, peer, ephemeral, recipient *ecdh.PublicKey) ([]byte, error) {
+	shared, err := private.ECDH(peer)
+	if err != nil {
+		return nil, fmt.Errorf("error agreeing key: %w", err)
+	}
+	salt := slices.Concat(ephemeral.Bytes(), recipient.Bytes())
+	return hkdf.Key(sha256.New, shared, salt, "envcraft x25519", 32)
+}
@@ -0,0 +1,76 @@
+package crypt
+
+import (
+	"crypto/ecdh"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecipient_RoundTrip(t *testing.T) {
+	identity, err := GenerateIdentity()
+	assert.NoError(t, err)
+
+	recipient, err := ParseRecipient(FormatRecipient(identity.PublicKey()))
+	assert.NoError(t, err)
+	assert.True(t, recipient.Equal(identity.PublicKey()))
+
+	identities, err := ParseIdentities([]byte("# comment\n" + FormatIdentity(identity) + "\n"))
+	assert.NoError(t, err)
+	assert.Len(t, identities, 1)
+	assert.True(t, identities[0].Equal(identity))
+
+	_, err = ParseRecipient("age1notarecipient")
+	assert.Error(t, err)
+}
+
+func TestEncrypt_Recipients(t *testing.T) {
+	alice, _ := GenerateIdentity()
+	bob, _ := GenerateIdentity()
+	eve, _ := GenerateIdentity()
+
+	data, err := Encrypt([]byte("A=1\n"), Keys{Recipients: []*ecdh.PublicKey{alice.PublicKey(), bob.PublicKey()}})
+	assert.NoError(t, err)
+
+	for _, identity := range []*ecdh.PrivateKey{alice, bob} {
+		plaintext, err := Decrypt(data, Keys{Identities: []*ecdh.PrivateKey{identity}})
+		assert.NoError(t, err)
+		assert.Equal(t, "A=1\n", string(plaintext))
+	}
+	_, err = Decrypt(data, Keys{Identities: []*ecdh.PrivateKey{eve}})
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
+
+func TestRewrap(t *testing.T) {
+	alice, _ := GenerateIdentity()
+	bob, _ := GenerateIdentity()
+	aliceKeys := Keys{Identities: []*ecdh.PrivateKey{alice}}
+
+	data, err := Encrypt([]byte("A=1\n"), Keys{KeyFile: []byte("team"), Recipients: []*ecdh.PublicKey{alice.PublicKey()}})
+	assert.NoError(t, err)
+
+	// Adding bob keeps the key file stanza and the data key.
+	added, err := Rewrap(data, Keys{Identities: aliceKeys.Identities, Recipients: []*ecdh.PublicKey{alice.PublicKey(), bob.PublicKey()}}, false)
+	assert.NoError(t, err)
+	plaintext, err := Decrypt(added, Keys{Identities: []*ecdh.PrivateKey{bob}})
+	assert.NoError(t, err)
+	assert.Equal(t, "A=1\n", string(plaintext))
+	_, err = Decrypt(added, Keys{KeyFile: []byte("team")})
+	assert.NoError(t, err)
+
+	// Removing bob replaces the data key, so a data key bob unwrapped before no longer opens the file.
+	envelope, _ := Parse(added)
+	oldDataKey, err := envelope.DataKey(Keys{Identities: []*ecdh.PrivateKey{bob}})
+	assert.NoError(t, err)
+
+	removed, err := Rewrap(added, Keys{Identities: aliceKeys.Identities, Recipients: []*ecdh.PublicKey{alice.PublicKey()}}, true)
+	assert.NoError(t, err)
+	_, err = Decrypt(removed, Keys{Identities: []*ecdh.PrivateKey{bob}})
+	assert.ErrorIs(t, err, ErrDecrypt)
+	envelope, _ = Parse(removed)
+	_, err = envelope.Open(oldDataKey)
+	assert.ErrorIs(t, err, ErrDecrypt)
+	plaintext, err = Decrypt(removed, aliceKeys)
+	assert.NoError(t, err)
+	assert.Equal(t, "A=1\n", string(plaintext))
+}
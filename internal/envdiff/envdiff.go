@@ -0,0 +1,53 @@
+package envdiff
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Kinds of change between two sets of variables.
+const (
+	Added   = "+"
+	Removed = "-"
+	Changed = "~"
+)
+
+// Change is a variable that differs between two sets of variables.
+type Change struct {
+	Kind string
+	Key  string
+	Old  string
+	New  string
+}
+
+// Compare returns the variables added, removed or changed from old to new, sorted by key.
+func Compare(old, new map[string]string) []Change {
+	changes := []Change{}
+	for key, oldValue := range old {
+		newValue, ok := new[key]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Kind: Removed, Key: key, Old: oldValue})
+		case newValue != oldValue:
+			changes = append(changes, Change{Kind: Changed, Key: key, Old: oldValue, New: newValue})
+		}
+	}
+	for key, newValue := range new {
+		if _, ok := old[key]; !ok {
+			changes = append(changes, Change{Kind: Added, Key: key, New: newValue})
+		}
+	}
+	slices.SortFunc(changes, func(a, b Change) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return changes
+}
+
+// PrintMasked writes one line per change without any value, so the diff is safe to show.
+func PrintMasked(w io.Writer, changes []Change) {
+	for _, change := range changes {
+		fmt.Fprintf(w, "  %s %s\n", change.Kind, change.Key)
+	}
+}
@@ -0,0 +1,201 @@
+package envedit
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+)
+
+// Editor sets, unsets and renames keys in the lines of an env file. Encrypted values stay
+// encrypted: the keys are loaded with LoadKeys the first time an operation meets one.
+type Editor struct {
+	LoadKeys func() (crypt.Keys, error)
+	cipher   *crypt.ValueCipher
+}
+
+// Set returns fileLines with key set to value, replacing the line of the key or appending one.
+// A value replacing an encrypted value is encrypted, once the keys are verified against it.
+// Returns lines.ErrAmbiguousKey if the key is defined more than once.
+func (e *Editor) Set(fileLines []string, key, value string) ([]string, error) {
+	indexes, err := lines.SelectOccurrences(key, lines.KeyIndexes(fileLines, key), "")
+	if errors.Is(err, lines.ErrAmbiguousKey) {
+		return nil, fmt.Errorf("%w (use dedupe to keep one definition)", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	newLines := slices.Clone(fileLines)
+
+	if len(indexes) == 0 {
+		line, err := assignment(key, value)
+		if err != nil {
+			return nil, err
+		}
+		if lines.IsEmptyOrBlank(newLines) {
+			return []string{line}, nil
+		}
+		if lines.EndsWithoutNewline(newLines) {
+			newLines[len(newLines)-1] += "\n"
+		}
+		return append(newLines, line), nil
+	}
+
+	i := indexes[0]
+	if current, _ := lines.Value(fileLines[i]); crypt.IsEncryptedValue(current) {
+		cipher, err := e.valueCipher()
+		if err != nil {
+			return nil, err
+		}
+		if err := cipher.Verify(key, current); err != nil {
+			return nil, err
+		}
+		if value, err = cipher.Encrypt(key, value); err != nil {
+			return nil, err
+		}
+	}
+	line, err := assignment(key, value)
+	if err != nil {
+		return nil, err
+	}
+	newLines[i] = withNewline(line, fileLines[i])
+	return newLines, nil
+}
+
+// Unset returns fileLines without the lines of key.
+func Unset(fileLines []string, key string) []string {
+	indexes := lines.KeyIndexes(fileLines, key)
+	kept := []string{}
+	for i, line := range fileLines {
+		if !slices.Contains(indexes, i) {
+			kept = append(kept, line)
+		}
+	}
+	return lines.KeepTrailingNewline(fileLines, kept)
+}
+
+// Rename returns fileLines with every line of key renamed to newKey. Encrypted values are bound
+// to their key, so they are decrypted and encrypted again for newKey.
+func (e *Editor) Rename(fileLines []string, key, newKey string) ([]string, error) {
+	if len(lines.KeyIndexes(fileLines, newKey)) > 0 {
+		return nil, fmt.Errorf("key %s already exists", newKey)
+	}
+	newLines := slices.Clone(fileLines)
+	for _, i := range lines.KeyIndexes(fileLines, key) {
+		line, err := e.renameLine(fileLines[i], key, newKey)
+		if err != nil {
+			return nil, err
+		}
+		newLines[i] = line
+	}
+	return newLines, nil
+}
+
+// renameLine returns the assignment line with its key renamed.
+func (e *Editor) renameLine(line, key, newKey string) (string, error) {
+	value, _ := lines.Value(line)
+	if !crypt.IsEncryptedValue(value) {
+		prefix, rest, _ := strings.Cut(line, "=")
+		return strings.Replace(prefix, key, newKey, 1) + "=" + rest, nil
+	}
+
+	cipher, err := e.valueCipher()
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := cipher.Decrypt(key, value)
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := cipher.Encrypt(newKey, plaintext)
+	if err != nil {
+		return "", err
+	}
+	renamed, err := assignment(newKey, encrypted)
+	if err != nil {
+		return "", err
+	}
+	return withNewline(renamed, line), nil
+}
+
+// Decrypt returns fileLines with the encrypted values of key decrypted.
+func (e *Editor) Decrypt(fileLines []string, key string) ([]string, error) {
+	newLines := slices.Clone(fileLines)
+	for _, i := range lines.KeyIndexes(fileLines, key) {
+		value, _ := lines.Value(fileLines[i])
+		if !crypt.IsEncryptedValue(value) {
+			continue
+		}
+		cipher, err := e.valueCipher()
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := cipher.Decrypt(key, value)
+		if err != nil {
+			return nil, err
+		}
+		line, err := assignment(key, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		newLines[i] = withNewline(line, fileLines[i])
+	}
+	return newLines, nil
+}
+
+// Encrypt returns fileLines with the plain values of key encrypted.
+func (e *Editor) Encrypt(fileLines []string, key string) ([]string, error) {
+	newLines := slices.Clone(fileLines)
+	for _, i := range lines.KeyIndexes(fileLines, key) {
+		value, _ := lines.Value(fileLines[i])
+		if crypt.IsEncryptedValue(value) {
+			continue
+		}
+		cipher, err := e.valueCipher()
+		if err != nil {
+			return nil, err
+		}
+		encrypted, err := cipher.Encrypt(key, value)
+		if err != nil {
+			return nil, err
+		}
+		line, err := assignment(key, encrypted)
+		if err != nil {
+			return nil, err
+		}
+		newLines[i] = withNewline(line, fileLines[i])
+	}
+	return newLines, nil
+}
+
+// valueCipher returns the cipher of the keys, loading them the first time.
+func (e *Editor) valueCipher() (*crypt.ValueCipher, error) {
+	if e.cipher == nil {
+		keys, err := e.LoadKeys()
+		if err != nil {
+			return nil, err
+		}
+		e.cipher = crypt.NewValueCipher(keys)
+	}
+	return e.cipher, nil
+}
+
+// assignment returns the line KEY="value", quoted so that dotenv loaders read value back unchanged.
+func assignment(key, value string) (string, error) {
+	quoted, err := lines.Quote(value)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", key, err)
+	}
+	return key + "=" + quoted, nil
+}
+
+// withNewline returns line ending with a newline if org did.
+func withNewline(line, org string) string {
+	if strings.HasSuffix(org, "\n") {
+		return line + "\n"
+	}
+	return line
+}
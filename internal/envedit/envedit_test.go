@@ -0,0 +1,161 @@
+package envedit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKeys = crypt.Keys{KeyFile: []byte("0123456789abcdef")}
+
+// newEditor returns an Editor counting how often it loads the keys.
+func newEditor(keys crypt.Keys, loads *int) *Editor {
+	return &Editor{LoadKeys: func() (crypt.Keys, error) {
+		*loads++
+		return keys, nil
+	}}
+}
+
+func TestSet(t *testing.T) {
+	tests := map[string]struct {
+		lines   []string
+		key     string
+		want    []string
+		wantErr error
+	}{
+		"replace":                  {lines: []string{"A=1\n", "B=2\n"}, key: "A", want: []string{"A=\"v\"\n", "B=2\n"}},
+		"replace last line":        {lines: []string{"B=2\n", "A=1"}, key: "A", want: []string{"B=2\n", "A=\"v\""}},
+		"append":                   {lines: []string{"B=2\n"}, key: "A", want: []string{"B=2\n", "A=\"v\""}},
+		"append without a newline": {lines: []string{"B=2"}, key: "A", want: []string{"B=2\n", "A=\"v\""}},
+		"empty file":               {lines: []string{""}, key: "A", want: []string{"A=\"v\""}},
+		"duplicate key":            {lines: []string{"A=1\n", "A=2\n"}, key: "A", wantErr: lines.ErrAmbiguousKey},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			loads := 0
+			got, err := newEditor(testKeys, &loads).Set(tt.lines, tt.key, "v")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.ErrorContains(t, err, "(use dedupe to keep one definition)")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, 0, loads)
+		})
+	}
+}
+
+func TestSetValueRoundTrip(t *testing.T) {
+	values := []string{"x$A${B}", "a\tb", `C:\dir\n`, `say "hi"`, "one\ntwo", `C:\dir\`}
+
+	fileLines := []string{"A=1\n", "B=2\n"}
+	editor := &Editor{}
+	for i, value := range values {
+		var err error
+		fileLines, err = editor.Set(fileLines, fmt.Sprintf("V%d", i), value)
+		require.NoError(t, err)
+	}
+	env, err := godotenv.Unmarshal(strings.Join(fileLines, ""))
+	require.NoError(t, err)
+	for i, value := range values {
+		assert.Equal(t, value, env[fmt.Sprintf("V%d", i)])
+	}
+}
+
+func TestSetEncrypted(t *testing.T) {
+	cipher := crypt.NewValueCipher(testKeys)
+	encrypted, err := cipher.Encrypt("TOKEN", "old")
+	require.NoError(t, err)
+	fileLines := []string{"TOKEN=\"" + encrypted + "\"\n"}
+
+	loads := 0
+	got, err := newEditor(testKeys, &loads).Set(fileLines, "TOKEN", "new")
+	require.NoError(t, err)
+	value, _ := lines.Value(got[0])
+	plaintext, err := cipher.Decrypt("TOKEN", value)
+	require.NoError(t, err)
+	assert.Equal(t, "new", plaintext)
+	assert.True(t, strings.HasSuffix(got[0], "\n"))
+
+	_, err = newEditor(crypt.Keys{KeyFile: []byte("another key")}, &loads).Set(fileLines, "TOKEN", "new")
+	assert.ErrorIs(t, err, crypt.ErrKeyMismatch)
+}
+
+func TestDecryptEncrypt(t *testing.T) {
+	cipher := crypt.NewValueCipher(testKeys)
+	encrypted, err := cipher.Encrypt("TOKEN", "s3cr3t $HOME")
+	require.NoError(t, err)
+
+	loads := 0
+	editor := newEditor(testKeys, &loads)
+	decrypted, err := editor.Decrypt([]string{"TOKEN=\"" + encrypted + "\"\n", "B=2\n"}, "TOKEN")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TOKEN=\"s3cr3t \\$HOME\"\n", "B=2\n"}, decrypted)
+
+	got, err := editor.Encrypt(append(decrypted, "TOKEN=added"), "TOKEN")
+	require.NoError(t, err)
+	assert.Equal(t, "B=2\n", got[1])
+	for i, want := range map[int]string{0: "s3cr3t $HOME", 2: "added"} {
+		value, _ := lines.Value(got[i])
+		plaintext, err := cipher.Decrypt("TOKEN", value)
+		require.NoError(t, err)
+		assert.Equal(t, want, plaintext)
+	}
+	assert.Equal(t, 1, loads)
+
+	_, err = newEditor(crypt.Keys{KeyFile: []byte("another key")}, &loads).Decrypt([]string{"TOKEN=\"" + encrypted + "\""}, "TOKEN")
+	assert.Error(t, err)
+}
+
+func TestUnset(t *testing.T) {
+	tests := map[string]struct {
+		lines []string
+		want  []string
+	}{
+		"every line of the key":  {lines: []string{"A=1\n", "B=2\n", "A=3\n"}, want: []string{"B=2\n"}},
+		"keeps no final newline": {lines: []string{"A=1\n", "B=2\n", "A=3"}, want: []string{"B=2"}},
+		"missing key":            {lines: []string{"B=2\n"}, want: []string{"B=2\n"}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Unset(tt.lines, "A"))
+		})
+	}
+}
+
+func TestRename(t *testing.T) {
+	cipher := crypt.NewValueCipher(testKeys)
+	encrypted, err := cipher.Encrypt("OLD", "s3cr3t")
+	require.NoError(t, err)
+
+	loads := 0
+	editor := newEditor(testKeys, &loads)
+	got, err := editor.Rename([]string{"OLD=x # note\n", "B=2\n"}, "OLD", "NEW")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"NEW=x # note\n", "B=2\n"}, got)
+
+	got, err = editor.Rename([]string{"OLD=\"" + encrypted + "\"\n", "OLD=\"" + encrypted + "\""}, "OLD", "NEW")
+	require.NoError(t, err)
+	for _, line := range got {
+		key, _ := lines.Key(line)
+		assert.Equal(t, "NEW", key)
+		value, _ := lines.Value(line)
+		plaintext, err := cipher.Decrypt("NEW", value)
+		require.NoError(t, err)
+		assert.Equal(t, "s3cr3t", plaintext)
+	}
+	assert.Equal(t, []bool{true, false}, []bool{strings.HasSuffix(got[0], "\n"), strings.HasSuffix(got[1], "\n")})
+	assert.Equal(t, 1, loads)
+
+	_, err = editor.Rename([]string{"OLD=1\n", "NEW=2\n"}, "OLD", "NEW")
+	assert.Error(t, err)
+}
@@ -0,0 +1,114 @@
+package envfile
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+	"github.com/joho/godotenv"
+)
+
+// Env holds the variables of an env file as a dotenv loader resolves them,
+// with the keys in the order they are first defined in the file.
+// Lines are the lines of the decrypted file, with encrypted values as they are written.
+type Env struct {
+	Keys   []string
+	Values map[string]string
+	Lines  []string
+}
+
+// Load reads the variables of path, decrypting the file or its encrypted values first.
+// Decrypted content is kept in memory only.
+func Load(path string, keyOptions crypt.KeyOptions) (*Env, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if crypt.IsEncrypted(data) {
+		keys, err := keyOptions.LoadFile(false)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = crypt.Decrypt(data, keys); err != nil {
+			return nil, err
+		}
+	}
+
+	env, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if crypt.HasEncryptedValues(env.Values) {
+		keys, err := keyOptions.Load(false)
+		if err != nil {
+			return nil, err
+		}
+		if err := crypt.NewValueCipher(keys).DecryptEnv(env.Values); err != nil {
+			return nil, err
+		}
+	}
+
+	return env, nil
+}
+
+// LoadValueKeys loads the keys for encrypting values into envLines, verified against the first
+// value already encrypted in the file if there is one.
+func LoadValueKeys(envLines []string, keyOptions crypt.KeyOptions, confirm bool) (crypt.Keys, error) {
+	env, err := Parse([]byte(strings.Join(envLines, "")))
+	if err != nil {
+		return crypt.Keys{}, err
+	}
+	key := ""
+	for _, k := range env.Keys {
+		if crypt.IsEncryptedValue(env.Values[k]) {
+			key = k
+			break
+		}
+	}
+	if key == "" {
+		return keyOptions.Load(confirm)
+	}
+
+	// The passphrase is checked against the file, so there is no need to type it twice.
+	keys, err := keyOptions.Load(false)
+	if err != nil {
+		return keys, err
+	}
+	return keys, crypt.NewValueCipher(keys).Verify(key, env.Values[key])
+}
+
+// Parse reads the variables of data as they are written, without decrypting anything.
+func Parse(data []byte) (*Env, error) {
+	values, err := godotenv.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &Env{Keys: orderKeys(data, values), Values: values, Lines: strings.SplitAfter(string(data), "\n")}, nil
+}
+
+// orderKeys returns the keys of values in the order they are first defined in data.
+// Keys that cannot be found on a line of their own are appended in sorted order.
+func orderKeys(data []byte, values map[string]string) []string {
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, ok := lines.Key(line)
+		key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+		if _, defined := values[key]; ok && defined && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	rest := []string{}
+	for key := range values {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	slices.Sort(rest)
+	return append(keys, rest...)
+}
@@ -0,0 +1,137 @@
+package envfile
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+)
+
+// EnvProfile names the profile to load when --env is not given.
+const EnvProfile = "ENVCRAFT_ENV"
+
+// ProfileTest is the profile in which the shared .env.local is skipped, so tests do not
+// depend on a developer's local overrides.
+const ProfileTest = "test"
+
+// notProfiles are suffixes of env files that are templates rather than profiles.
+var notProfiles = []string{"example", "sample", "template", "dist", "enc"}
+
+// ProfileOptions selects a profile following the dotenv-flow convention. For the production
+// profile of .env these files are loaded, each overriding the ones before:
+//
+//	.env
+//	.env.local
+//	.env.production
+//	.env.production.local
+type ProfileOptions struct {
+	Env string
+}
+
+// AddFlags registers the --env flag on flagSet.
+func (o *ProfileOptions) AddFlags(flagSet *flag.FlagSet) {
+	flagSet.StringVar(&o.Env, "env", "", "Profile to load, such as production, with its .local overrides (default $"+EnvProfile+")")
+}
+
+// Name returns the selected profile, or an empty string if there is none.
+func (o ProfileOptions) Name() string {
+	if o.Env != "" {
+		return o.Env
+	}
+	return os.Getenv(EnvProfile)
+}
+
+// Load reads path, or the files of the selected profile with path as the base file.
+func (o ProfileOptions) Load(path string, keyOptions crypt.KeyOptions) (*Env, error) {
+	profile := o.Name()
+	if profile == "" {
+		return Load(path, keyOptions)
+	}
+
+	files := ProfileFiles(path, profile)
+	if !slices.ContainsFunc(files, func(file string) bool { return isProfileFile(path, profile, file) }) {
+		return nil, fmt.Errorf("profile %s not found: neither %s.%s nor %s.%s.local exists", profile, path, profile, path, profile)
+	}
+	return LoadFiles(files, keyOptions)
+}
+
+// ProfileFiles returns the existing files of profile for the base file, lowest precedence first.
+// An encrypted FILE.enc is used where FILE does not exist.
+func ProfileFiles(base, profile string) []string {
+	candidates := []string{base}
+	if profile != ProfileTest {
+		candidates = append(candidates, base+".local")
+	}
+	if profile != "" {
+		candidates = append(candidates, base+"."+profile, base+"."+profile+".local")
+	}
+
+	files := []string{}
+	for _, candidate := range candidates {
+		for _, file := range []string{candidate, candidate + ".enc"} {
+			if _, err := os.Stat(file); err == nil {
+				files = append(files, file)
+				break
+			}
+		}
+	}
+	return files
+}
+
+// Profiles returns the names of the profiles that have files next to the base file, sorted.
+func Profiles(base string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(base))
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := filepath.Base(base) + "."
+	profiles := []string{}
+	for _, entry := range entries {
+		name, found := strings.CutPrefix(entry.Name(), prefix)
+		if !found || entry.IsDir() {
+			continue
+		}
+		name = strings.TrimSuffix(strings.TrimSuffix(name, ".enc"), ".local")
+		if name == "" || name == "local" || slices.Contains(notProfiles, name) || strings.Contains(name, ".") || slices.Contains(profiles, name) {
+			continue
+		}
+		profiles = append(profiles, name)
+	}
+	slices.Sort(profiles)
+	return profiles, nil
+}
+
+// LoadFiles reads files in order, later files overriding the values of earlier ones.
+func LoadFiles(files []string, keyOptions crypt.KeyOptions) (*Env, error) {
+	if len(files) == 0 {
+		return nil, errors.New("no env file found")
+	}
+
+	merged := &Env{Keys: []string{}, Values: map[string]string{}, Lines: []string{}}
+	for _, file := range files {
+		env, err := Load(file, keyOptions)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for _, key := range env.Keys {
+			if _, ok := merged.Values[key]; !ok {
+				merged.Keys = append(merged.Keys, key)
+			}
+			merged.Values[key] = env.Values[key]
+		}
+		merged.Lines = append(merged.Lines, env.Lines...)
+	}
+	return merged, nil
+}
+
+// isProfileFile reports whether file is specific to profile rather than shared by all profiles.
+func isProfileFile(base, profile, file string) bool {
+	file = strings.TrimSuffix(file, ".enc")
+	return file == base+"."+profile || file == base+"."+profile+".local"
+}
@@ -0,0 +1,89 @@
+package envfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return filepath.Join(dir, ".env")
+}
+
+func TestProfileOptions_Load(t *testing.T) {
+	base := writeFiles(t, map[string]string{
+		".env":                  "A=env\nB=env\nC=env\nD=env\n",
+		".env.local":            "B=local\n",
+		".env.production":       "C=production\n",
+		".env.production.local": "D=production.local\n",
+		".env.test":             "C=test\n",
+		".env.example":          "A=\n",
+	})
+
+	tests := map[string]struct {
+		profile string
+		want    map[string]string
+		wantErr bool
+	}{
+		"no profile loads the file only": {
+			profile: "",
+			want:    map[string]string{"A": "env", "B": "env", "C": "env", "D": "env"},
+		},
+		"production": {
+			profile: "production",
+			want:    map[string]string{"A": "env", "B": "local", "C": "production", "D": "production.local"},
+		},
+		"test skips .env.local": {
+			profile: "test",
+			want:    map[string]string{"A": "env", "B": "env", "C": "test", "D": "env"},
+		},
+		"unknown profile": {
+			profile: "staging",
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			env, err := ProfileOptions{Env: tt.profile}.Load(base, crypt.KeyOptions{})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, env.Values)
+			assert.Equal(t, []string{"A", "B", "C", "D"}, env.Keys)
+		})
+	}
+}
+
+func TestProfileOptions_FromEnvironment(t *testing.T) {
+	base := writeFiles(t, map[string]string{".env": "A=env\n", ".env.staging": "A=staging\n"})
+	t.Setenv(EnvProfile, "staging")
+
+	env, err := ProfileOptions{}.Load(base, crypt.KeyOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "staging", env.Values["A"])
+}
+
+func TestProfiles(t *testing.T) {
+	base := writeFiles(t, map[string]string{
+		".env": "", ".env.local": "", ".env.example": "", ".env.enc": "",
+		".env.production.enc": "", ".env.staging.local": "", ".env.test": "",
+	})
+
+	profiles, err := Profiles(base)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"production", "staging", "test"}, profiles)
+
+	dir := filepath.Dir(base)
+	assert.Equal(t, []string{base, filepath.Join(dir, ".env.local"), filepath.Join(dir, ".env.production.enc")}, ProfileFiles(base, "production"))
+	assert.Equal(t, []string{base, filepath.Join(dir, ".env.test")}, ProfileFiles(base, "test"))
+}
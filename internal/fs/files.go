@@ -6,8 +6,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
+// OnWrite, when set, is called after WriteLines or WriteFileAtomic replaced a file, with its
+// previous content (nil if it did not exist) and its new content. It is used to journal changes.
+var OnWrite func(filePath string, before, after []byte)
+
 func ReadLines(filePath string) ([]string, error) {
 	envFile, err := os.Open(filePath)
 	if err != nil {
@@ -35,6 +41,7 @@ func ReadLines(filePath string) ([]string, error) {
 }
 
 func WriteLines(filePath string, lines []string) error {
+	before := readForJournal(filePath)
 	out, err := os.OpenFile(filePath, os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		return fmt.Errorf("error opening file %s for writing: %w", filePath, err)
@@ -50,6 +57,89 @@ func WriteLines(filePath string, lines []string) error {
 	if err := writer.Flush(); err != nil {
 		return fmt.Errorf("error flushing writer: %w", err)
 	}
+	if OnWrite != nil {
+		OnWrite(filePath, before, []byte(strings.Join(lines, "")))
+	}
 
 	return nil
 }
+
+// WriteFileAtomic replaces filePath with data by writing a temporary file in the same
+// directory and renaming it, so readers never see a partly written file.
+func WriteFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+	staged, err := StageFile(filePath, data, perm)
+	if err != nil {
+		return err
+	}
+	return staged.Commit()
+}
+
+// StagedFile is the new content of a file, written to a temporary file next to it,
+// that replaces the file only when committed. Staging every file first lets a
+// command update several files together or not at all.
+type StagedFile struct {
+	path   string
+	tmp    string
+	before []byte
+	data   []byte
+}
+
+// StageFile writes data to a temporary file in the directory of filePath.
+func StageFile(filePath string, data []byte, perm os.FileMode) (*StagedFile, error) {
+	before := readForJournal(filePath)
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary file for %s: %w", filePath, err)
+	}
+	staged := &StagedFile{path: filePath, tmp: tmp.Name(), before: before, data: data}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		staged.Discard()
+		return nil, fmt.Errorf("error writing to file %s: %w", filePath, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		staged.Discard()
+		return nil, fmt.Errorf("error setting permissions of %s: %w", filePath, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		staged.Discard()
+		return nil, fmt.Errorf("error writing to file %s: %w", filePath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		staged.Discard()
+		return nil, fmt.Errorf("error writing to file %s: %w", filePath, err)
+	}
+	return staged, nil
+}
+
+// Commit replaces the file with the staged content.
+func (s *StagedFile) Commit() error {
+	if err := os.Rename(s.tmp, s.path); err != nil {
+		s.Discard()
+		return fmt.Errorf("error replacing file %s: %w", s.path, err)
+	}
+	if OnWrite != nil {
+		OnWrite(s.path, s.before, s.data)
+	}
+	return nil
+}
+
+// Discard removes the staged content, leaving the file untouched.
+func (s *StagedFile) Discard() {
+	os.Remove(s.tmp)
+}
+
+// readForJournal returns the current content of filePath when writes are journaled.
+func readForJournal(filePath string) []byte {
+	if OnWrite == nil {
+		return nil
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil
+	}
+	return data
+}
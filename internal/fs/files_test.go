@@ -0,0 +1,162 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// record sets OnWrite to collect the writes of the test.
+func record(t *testing.T) *[]string {
+	writes := []string{}
+	orgOnWrite := OnWrite
+	OnWrite = func(filePath string, before, after []byte) {
+		writes = append(writes, filepath.Base(filePath)+": "+string(before)+" -> "+string(after))
+	}
+	t.Cleanup(func() { OnWrite = orgOnWrite })
+	return &writes
+}
+
+// tempFiles returns the temporary files left in dir.
+func tempFiles(t *testing.T, dir string) []string {
+	matches, err := filepath.Glob(filepath.Join(dir, ".*.tmp-*"))
+	require.NoError(t, err)
+	return matches
+}
+
+func TestReadLines(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    []string
+	}{
+		"trailing newline":    {content: "A=1\nB=2\n", want: []string{"A=1\n", "B=2\n"}},
+		"no trailing newline": {content: "A=1\nB=2", want: []string{"A=1\n", "B=2"}},
+		"empty file":          {content: "", want: []string{""}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), ".env")
+			require.NoError(t, os.WriteFile(filePath, []byte(tt.content), 0o600))
+
+			got, err := ReadLines(filePath)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWriteLines(t *testing.T) {
+	writes := record(t)
+	filePath := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(filePath, []byte("A=1\n"), 0o600))
+
+	require.NoError(t, WriteLines(filePath, []string{"A=2\n", "B=3"}))
+	got, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "A=2\nB=3", string(got))
+	assert.Equal(t, []string{".env: A=1\n -> A=2\nB=3"}, *writes)
+}
+
+func TestStageFile(t *testing.T) {
+	tests := map[string]struct {
+		existing string
+		commit   bool
+		want     string
+		wantPerm os.FileMode
+		writes   []string
+	}{
+		"commit replaces the file": {
+			existing: "A=1\n",
+			commit:   true,
+			want:     "A=2\n",
+			wantPerm: 0o640,
+			writes:   []string{".env: A=1\n -> A=2\n"},
+		},
+		"commit creates the file": {
+			existing: "",
+			commit:   true,
+			want:     "A=2\n",
+			wantPerm: 0o640,
+			writes:   []string{".env:  -> A=2\n"},
+		},
+		"discard keeps the file": {
+			existing: "A=1\n",
+			commit:   false,
+			want:     "A=1\n",
+			wantPerm: 0o600,
+			writes:   []string{},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			writes := record(t)
+			dir := t.TempDir()
+			filePath := filepath.Join(dir, ".env")
+			if tt.existing != "" {
+				require.NoError(t, os.WriteFile(filePath, []byte(tt.existing), 0o600))
+			}
+
+			staged, err := StageFile(filePath, []byte("A=2\n"), 0o640)
+			require.NoError(t, err)
+			assert.Len(t, tempFiles(t, dir), 1)
+			if tt.existing != "" {
+				got, err := os.ReadFile(filePath)
+				require.NoError(t, err)
+				assert.Equal(t, tt.existing, string(got), "the file is untouched until committed")
+			}
+
+			if tt.commit {
+				require.NoError(t, staged.Commit())
+			} else {
+				staged.Discard()
+			}
+			assert.Empty(t, tempFiles(t, dir))
+			got, err := os.ReadFile(filePath)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+			info, err := os.Stat(filePath)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPerm, info.Mode().Perm())
+			assert.Equal(t, tt.writes, *writes)
+		})
+	}
+}
+
+func TestStageFileMissingDirectory(t *testing.T) {
+	_, err := StageFile(filepath.Join(t.TempDir(), "missing", ".env"), []byte("A=1\n"), 0o600)
+	assert.Error(t, err)
+}
+
+func TestCommitFailureRemovesStagedFile(t *testing.T) {
+	writes := record(t)
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target")
+	staged, err := StageFile(target, []byte("A=1\n"), 0o600)
+	require.NoError(t, err)
+	// A directory cannot be replaced by a file.
+	require.NoError(t, os.MkdirAll(filepath.Join(target, "child"), 0o755))
+
+	assert.Error(t, staged.Commit())
+	assert.Empty(t, tempFiles(t, dir))
+	assert.Empty(t, *writes)
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, ".env")
+	require.NoError(t, os.WriteFile(filePath, []byte("A=1\n"), 0o644))
+
+	require.NoError(t, WriteFileAtomic(filePath, []byte("A=2\n"), 0o600))
+	got, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "A=2\n", string(got))
+	info, err := os.Stat(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	assert.Empty(t, tempFiles(t, dir))
+}
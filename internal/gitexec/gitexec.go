@@ -0,0 +1,19 @@
+package gitexec
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Run runs git with args and returns its output. The errors of git go to stderr.
+func Run(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error running git %s: %w", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,14 @@
+package input
+
+import "strings"
+
+// SplitList splits a comma separated flag value, dropping empty entries.
+func SplitList(s string) []string {
+	list := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,26 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitList(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  []string
+	}{
+		"empty":          {input: "", want: []string{}},
+		"one":            {input: "DB_*", want: []string{"DB_*"}},
+		"spaces":         {input: " *_URL , *_PASS ", want: []string{"*_URL", "*_PASS"}},
+		"empty entries":  {input: "A,,B,", want: []string{"A", "B"}},
+		"only separator": {input: " , ", want: []string{}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SplitList(tt.input))
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package input
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/secret"
+	"golang.org/x/term"
+)
+
+// ValueSource selects where a value is read from instead of the command line,
+// so secrets do not end up in shell history or ps output.
+type ValueSource struct {
+	Stdin     bool
+	ValueFile string
+	FromEnv   string
+	Prompt    bool
+	Generate  bool
+	Length    int
+	Charset   string
+}
+
+// Stdin is the reader used by --stdin. It can be replaced in tests.
+var Stdin io.Reader = os.Stdin
+
+// ErrNoAnswer is returned by Confirm when stdin ends before an answer is given.
+var ErrNoAnswer = errors.New("no answer: stdin is not interactive or was closed")
+
+// stdin buffers Stdin for every read, so that input buffered while reading one answer is
+// still there for the next. It is replaced along with Stdin.
+var stdin struct {
+	source io.Reader
+	reader *bufio.Reader
+}
+
+// stdinReader returns the shared buffered reader of Stdin.
+func stdinReader() *bufio.Reader {
+	if stdin.reader == nil || stdin.source != Stdin {
+		stdin.source, stdin.reader = Stdin, bufio.NewReader(Stdin)
+	}
+	return stdin.reader
+}
+
+// AddFlags registers the --stdin, --value-file, --from-env, --prompt and --generate flags on flagSet.
+func (s *ValueSource) AddFlags(flagSet *flag.FlagSet) {
+	flagSet.BoolVar(&s.Stdin, "stdin", false, "Read the value from stdin (may be multi-line)")
+	flagSet.StringVar(&s.ValueFile, "value-file", "", "Read the value from a file")
+	flagSet.StringVar(&s.FromEnv, "from-env", "", "Read the value from an environment variable")
+	flagSet.BoolVar(&s.Prompt, "prompt", false, "Prompt for the value without echoing it")
+	flagSet.BoolVar(&s.Generate, "generate", false, "Generate a random secret as the value")
+	flagSet.IntVar(&s.Length, "length", 0, "Length of the generated secret in characters, or words for --charset words")
+	flagSet.StringVar(&s.Charset, "charset", "", "Charset of the generated secret: "+strings.Join(secret.Charsets, ", ")+" (default base64url)")
+}
+
+// IsSet reports whether any source other than the command line was chosen.
+func (s ValueSource) IsSet() bool {
+	return s.Stdin || s.ValueFile != "" || s.FromEnv != "" || s.Prompt || s.Generate
+}
+
+// Validate returns an error if more than one source was chosen.
+func (s ValueSource) Validate() error {
+	count := 0
+	for _, set := range []bool{s.Stdin, s.ValueFile != "", s.FromEnv != "", s.Prompt, s.Generate} {
+		if set {
+			count++
+		}
+	}
+	if count > 1 {
+		return errors.New("only one of --stdin, --value-file, --from-env, --prompt and --generate may be set")
+	}
+	if !s.Generate && (s.Length != 0 || s.Charset != "") {
+		return errors.New("--length and --charset require --generate")
+	}
+	return nil
+}
+
+// Read returns the value for key from the chosen source.
+// A single trailing newline is removed from stdin and file input.
+func (s ValueSource) Read(key string) (string, error) {
+	switch {
+	case s.Stdin:
+		data, err := io.ReadAll(stdinReader())
+		if err != nil {
+			return "", fmt.Errorf("error reading value from stdin: %w", err)
+		}
+		return trimNewline(string(data)), nil
+	case s.ValueFile != "":
+		data, err := os.ReadFile(s.ValueFile)
+		if err != nil {
+			return "", fmt.Errorf("error reading value file %s: %w", s.ValueFile, err)
+		}
+		return trimNewline(string(data)), nil
+	case s.FromEnv != "":
+		value, ok := os.LookupEnv(s.FromEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", s.FromEnv)
+		}
+		return value, nil
+	case s.Prompt:
+		return PromptHidden(fmt.Sprintf("Value for %s: ", key))
+	case s.Generate:
+		charset := s.Charset
+		if charset == "" {
+			charset = secret.CharsetBase64URL
+		}
+		return secret.Generate(charset, s.Length)
+	default:
+		return "", errors.New("no value source set")
+	}
+}
+
+// PromptHidden asks for a value on the terminal without echoing the input.
+func PromptHidden(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("prompting requires an interactive terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	value, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("error reading value: %w", err)
+	}
+	return string(value), nil
+}
+
+func trimNewline(value string) string {
+	value = strings.TrimSuffix(value, "\n")
+	return strings.TrimSuffix(value, "\r")
+}
+
+// Confirm asks a yes/no question on stderr and reads the answer from Stdin.
+// An empty answer returns def. If stdin ends without an answer, it returns ErrNoAnswer
+// rather than def, so that a loop asking again cannot run forever.
+func Confirm(prompt string, def bool) (bool, error) {
+	choices := " [y/N] "
+	if def {
+		choices = " [Y/n] "
+	}
+	fmt.Fprint(os.Stderr, prompt+choices)
+	answer, err := stdinReader().ReadString('\n')
+	if errors.Is(err, io.EOF) && answer == "" {
+		fmt.Fprintln(os.Stderr)
+		return false, ErrNoAnswer
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("error reading answer: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "":
+		return def, nil
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
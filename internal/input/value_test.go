@@ -0,0 +1,143 @@
+package input
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setStdin replaces Stdin with text for the test.
+func setStdin(t *testing.T, text string) {
+	orgStdin := Stdin
+	Stdin = strings.NewReader(text)
+	t.Cleanup(func() { Stdin = orgStdin })
+}
+
+func TestConfirm(t *testing.T) {
+	tests := map[string]struct {
+		stdin   string
+		def     bool
+		want    bool
+		wantErr error
+	}{
+		"yes":                     {stdin: "y\n", def: false, want: true},
+		"YES without newline":     {stdin: "YES", def: false, want: true},
+		"no":                      {stdin: "n\n", def: true, want: false},
+		"other answer":            {stdin: "maybe\n", def: true, want: false},
+		"empty answer":            {stdin: "\n", def: true, want: true},
+		"empty answer default no": {stdin: "\n", def: false, want: false},
+		"closed stdin":            {stdin: "", def: true, want: false, wantErr: ErrNoAnswer},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			setStdin(t, tt.stdin)
+			got, err := Confirm("Continue?", tt.def)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConfirm_SharesStdin(t *testing.T) {
+	setStdin(t, "n\ny\n")
+
+	first, err := Confirm("First?", true)
+	assert.NoError(t, err)
+	assert.False(t, first)
+	second, err := Confirm("Second?", false)
+	assert.NoError(t, err)
+	assert.True(t, second)
+	_, err = Confirm("Third?", true)
+	assert.ErrorIs(t, err, ErrNoAnswer)
+}
+
+func TestValueSource_Read(t *testing.T) {
+	valueFile := filepath.Join(t.TempDir(), "value")
+	assert.NoError(t, os.WriteFile(valueFile, []byte("from file\n"), 0600))
+	t.Setenv("ENVCRAFT_TEST_VALUE", "from env")
+
+	tests := map[string]struct {
+		source  ValueSource
+		stdin   string
+		want    string
+		wantErr bool
+	}{
+		"stdin keeps inner newlines": {
+			source: ValueSource{Stdin: true},
+			stdin:  "line 1\nline 2\r\n",
+			want:   "line 1\nline 2",
+		},
+		"value file": {
+			source: ValueSource{ValueFile: valueFile},
+			want:   "from file",
+		},
+		"missing value file": {
+			source:  ValueSource{ValueFile: valueFile + ".missing"},
+			wantErr: true,
+		},
+		"environment variable": {
+			source: ValueSource{FromEnv: "ENVCRAFT_TEST_VALUE"},
+			want:   "from env",
+		},
+		"unset environment variable": {
+			source:  ValueSource{FromEnv: "ENVCRAFT_TEST_UNSET"},
+			wantErr: true,
+		},
+		"no source": {
+			source:  ValueSource{},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			setStdin(t, tt.stdin)
+			got, err := tt.source.Read("KEY")
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValueSource_ReadGenerated(t *testing.T) {
+	got, err := ValueSource{Generate: true, Charset: "hex", Length: 16}.Read("KEY")
+	assert.NoError(t, err)
+	assert.Len(t, got, 16)
+	assert.Equal(t, "", strings.Trim(got, "0123456789abcdef"))
+}
+
+func TestValueSource_Validate(t *testing.T) {
+	tests := map[string]struct {
+		source  ValueSource
+		wantErr bool
+	}{
+		"command line":            {source: ValueSource{}, wantErr: false},
+		"one source":              {source: ValueSource{Stdin: true}, wantErr: false},
+		"generate with length":    {source: ValueSource{Generate: true, Length: 12, Charset: "alnum"}, wantErr: false},
+		"two sources":             {source: ValueSource{Stdin: true, Prompt: true}, wantErr: true},
+		"length without generate": {source: ValueSource{Length: 12}, wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.source.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
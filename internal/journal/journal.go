@@ -0,0 +1,226 @@
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ba58ajbse/envcraft/internal/envdiff"
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/joho/godotenv"
+)
+
+// Dir is the directory, next to the journaled file, that holds the journals.
+const Dir = ".envcraft"
+
+// MaxEntries bounds the number of operations kept per file; the oldest are dropped first.
+const MaxEntries = 50
+
+// EnvDisable disables journaling when set to "off".
+const EnvDisable = "ENVCRAFT_JOURNAL"
+
+var (
+	// ErrNothingToUndo is returned when every journaled operation has been undone.
+	ErrNothingToUndo = errors.New("nothing to undo")
+	// ErrNothingToRedo is returned when no undone operation can be redone.
+	ErrNothingToRedo = errors.New("nothing to redo")
+	// ErrModified is returned when the file changed outside envcraft since the operation.
+	ErrModified = errors.New("file was modified since the journaled operation")
+)
+
+// Entry is a journaled operation, holding the file content before and after it.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Keys    []string  `json:"keys"`
+	Before  []byte    `json:"before"`
+	After   []byte    `json:"after"`
+	// Existed is false when the operation created the file.
+	Existed bool `json:"existed"`
+}
+
+// Journal is the bounded list of operations on a file. Entries before Position
+// are applied, the ones from Position on have been undone and can be redone.
+type Journal struct {
+	File     string  `json:"file"`
+	Position int     `json:"position"`
+	Entries  []Entry `json:"entries"`
+}
+
+// Path returns the path of the journal of filePath.
+func Path(filePath string) string {
+	return filepath.Join(filepath.Dir(filePath), Dir, "journal", filepath.Base(filePath)+".json")
+}
+
+// Load reads the journal of filePath, returning an empty journal if there is none yet.
+func Load(filePath string) (*Journal, error) {
+	data, err := os.ReadFile(Path(filePath))
+	if errors.Is(err, os.ErrNotExist) {
+		return &Journal{File: filepath.Base(filePath)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading journal of %s: %w", filePath, err)
+	}
+	var j Journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("error parsing journal of %s: %w", filePath, err)
+	}
+	j.Position = min(max(j.Position, 0), len(j.Entries))
+	return &j, nil
+}
+
+// Save writes the journal of filePath. The journal holds plaintext values, so the
+// directory is only accessible to the owner and is ignored by git.
+func (j *Journal) Save(filePath string) error {
+	journalPath := Path(filePath)
+	if err := os.MkdirAll(filepath.Dir(journalPath), 0o700); err != nil {
+		return fmt.Errorf("error creating journal directory: %w", err)
+	}
+	root := filepath.Join(filepath.Dir(filePath), Dir)
+	if err := os.Chmod(root, 0o700); err != nil {
+		return fmt.Errorf("error restricting journal directory: %w", err)
+	}
+	ignore := filepath.Join(root, ".gitignore")
+	if _, err := os.Stat(ignore); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(ignore, []byte("*\n"), 0o600); err != nil {
+			return fmt.Errorf("error writing %s: %w", ignore, err)
+		}
+	}
+
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding journal of %s: %w", filePath, err)
+	}
+	if err := writeFile(journalPath, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("error writing journal of %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// Add appends an operation, discarding the undone operations and the oldest
+// ones beyond MaxEntries.
+func (j *Journal) Add(entry Entry) {
+	j.Entries = append(j.Entries[:j.Position], entry)
+	if len(j.Entries) > MaxEntries {
+		j.Entries = j.Entries[len(j.Entries)-MaxEntries:]
+	}
+	j.Position = len(j.Entries)
+}
+
+// Undo restores filePath to its content before the last applied operation and returns that operation.
+func (j *Journal) Undo(filePath string) (*Entry, error) {
+	if j.Position == 0 {
+		return nil, ErrNothingToUndo
+	}
+	entry := &j.Entries[j.Position-1]
+	if err := replace(filePath, entry.After, entry.Before, entry.Existed); err != nil {
+		return nil, err
+	}
+	j.Position--
+	return entry, nil
+}
+
+// Redo applies the first undone operation to filePath again and returns it.
+func (j *Journal) Redo(filePath string) (*Entry, error) {
+	if j.Position == len(j.Entries) {
+		return nil, ErrNothingToRedo
+	}
+	entry := &j.Entries[j.Position]
+	if err := replace(filePath, entry.Before, entry.After, true); err != nil {
+		return nil, err
+	}
+	j.Position++
+	return entry, nil
+}
+
+// replaying is set while undo or redo rewrites a file, so that the write is not journaled again.
+var replaying bool
+
+// replace writes content to filePath, or removes it if keep is false, after checking
+// that it still holds expected so that changes made outside envcraft are not lost.
+func replace(filePath string, expected, content []byte, keep bool) error {
+	current, err := os.ReadFile(filePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error reading file %s: %w", filePath, err)
+	}
+	if string(current) != string(expected) {
+		return fmt.Errorf("%w: %s", ErrModified, filePath)
+	}
+	if !keep {
+		if err := os.Remove(filePath); err != nil {
+			return fmt.Errorf("error removing file %s: %w", filePath, err)
+		}
+		return nil
+	}
+
+	perm := os.FileMode(0o600)
+	if info, err := os.Stat(filePath); err == nil {
+		perm = info.Mode().Perm()
+	}
+	replaying = true
+	defer func() { replaying = false }()
+	if err := fs.WriteFileAtomic(filePath, content, perm); err != nil {
+		return fmt.Errorf("error writing to file %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// writeFile replaces a file atomically without reporting the write to fs.OnWrite.
+func writeFile(filePath string, data []byte, perm os.FileMode) error {
+	onWrite := fs.OnWrite
+	fs.OnWrite = nil
+	defer func() { fs.OnWrite = onWrite }()
+	return fs.WriteFileAtomic(filePath, data, perm)
+}
+
+// Recorder returns an fs.OnWrite hook journaling the writes of command. Failing
+// to journal does not fail the command; a warning is printed instead.
+func Recorder(command string) func(filePath string, before, after []byte) {
+	return func(filePath string, before, after []byte) {
+		if replaying || os.Getenv(EnvDisable) == "off" || string(before) == string(after) {
+			return
+		}
+		if err := Record(filePath, command, before, after); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
+
+// Record journals an operation of command that replaced before with after in filePath.
+// A nil before means the operation created the file.
+func Record(filePath, command string, before, after []byte) error {
+	j, err := Load(filePath)
+	if err != nil {
+		return err
+	}
+	j.Add(Entry{
+		Time:    time.Now(),
+		Command: command,
+		Keys:    TouchedKeys(before, after),
+		Before:  before,
+		After:   after,
+		Existed: before != nil,
+	})
+	return j.Save(filePath)
+}
+
+// TouchedKeys returns the keys added, removed or changed between two versions
+// of an env file. Content that cannot be parsed, such as an encrypted file, has no keys.
+func TouchedKeys(before, after []byte) []string {
+	old, err := godotenv.UnmarshalBytes(before)
+	if err != nil {
+		return []string{}
+	}
+	new, err := godotenv.UnmarshalBytes(after)
+	if err != nil {
+		return []string{}
+	}
+	keys := []string{}
+	for _, change := range envdiff.Compare(old, new) {
+		keys = append(keys, change.Key)
+	}
+	return keys
+}
@@ -0,0 +1,131 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordUndoRedo(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), ".env")
+	v1 := []byte("A=1\nB=2\n")
+	v2 := []byte("A=1\n")
+	v3 := []byte("A=1\nC=\"3\"\n")
+	require.NoError(t, os.WriteFile(filePath, v3, 0o640))
+	require.NoError(t, Record(filePath, "delete", v1, v2))
+	require.NoError(t, Record(filePath, "add", v2, v3))
+
+	j, err := Load(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, 2, j.Position)
+	assert.Equal(t, []string{"B"}, j.Entries[0].Keys)
+	assert.Equal(t, []string{"C"}, j.Entries[1].Keys)
+
+	entry, err := j.Undo(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "add", entry.Command)
+	entry, err = j.Undo(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "delete", entry.Command)
+	got, _ := os.ReadFile(filePath)
+	assert.Equal(t, v1, got)
+	_, err = j.Undo(filePath)
+	assert.ErrorIs(t, err, ErrNothingToUndo)
+
+	_, err = j.Redo(filePath)
+	require.NoError(t, err)
+	got, _ = os.ReadFile(filePath)
+	assert.Equal(t, v2, got)
+	info, _ := os.Stat(filePath)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+
+	// A new operation discards the undone one.
+	j.Add(Entry{Command: "update", Before: v2, After: v1})
+	assert.Len(t, j.Entries, 2)
+	_, err = j.Redo(filePath)
+	assert.ErrorIs(t, err, ErrNothingToRedo)
+}
+
+func TestUndoModifiedFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(filePath, []byte("A=edited\n"), 0o600))
+	require.NoError(t, Record(filePath, "add", []byte(""), []byte("A=1\n")))
+
+	j, err := Load(filePath)
+	require.NoError(t, err)
+	_, err = j.Undo(filePath)
+	assert.ErrorIs(t, err, ErrModified)
+	got, _ := os.ReadFile(filePath)
+	assert.Equal(t, "A=edited\n", string(got))
+}
+
+func TestUndoCreatedFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(filePath, []byte("A=1\n"), 0o600))
+	require.NoError(t, Record(filePath, "import", nil, []byte("A=1\n")))
+
+	j, err := Load(filePath)
+	require.NoError(t, err)
+	_, err = j.Undo(filePath)
+	require.NoError(t, err)
+	assert.NoFileExists(t, filePath)
+	_, err = j.Redo(filePath)
+	require.NoError(t, err)
+	assert.FileExists(t, filePath)
+}
+
+func TestJournalBoundedAndRestricted(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, ".env")
+	for range MaxEntries + 5 {
+		require.NoError(t, Record(filePath, "update", []byte("A=1\n"), []byte("A=2\n")))
+	}
+
+	j, err := Load(filePath)
+	require.NoError(t, err)
+	assert.Len(t, j.Entries, MaxEntries)
+	assert.Equal(t, MaxEntries, j.Position)
+
+	info, err := os.Stat(filepath.Join(dir, Dir))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+	info, err = os.Stat(Path(filePath))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	ignore, err := os.ReadFile(filepath.Join(dir, Dir, ".gitignore"))
+	require.NoError(t, err)
+	assert.Equal(t, "*\n", string(ignore))
+}
+
+func TestTouchedKeys(t *testing.T) {
+	tests := map[string]struct {
+		before string
+		after  string
+		want   []string
+	}{
+		"changed and added": {
+			before: "A=1\nB=2\n",
+			after:  "A=3\nB=2\nC=4\n",
+			want:   []string{"A", "C"},
+		},
+		"commented out": {
+			before: "A=1\nB=2\n",
+			after:  "A=1\n# B=2\n",
+			want:   []string{"B"},
+		},
+		"comment only": {
+			before: "A=1\n",
+			after:  "# note\nA=1\n",
+			want:   []string{},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, TouchedKeys([]byte(tt.before), []byte(tt.after)))
+		})
+	}
+}
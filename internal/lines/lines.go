@@ -1,6 +1,29 @@
 package lines
 
-import "strings"
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// Occurrence strategies select which lines of a key defined more than once a command acts on.
+const (
+	OccurrenceAll   = "all"
+	OccurrenceFirst = "first"
+	OccurrenceLast  = "last" // The effective one: dotenv loaders let the last definition win.
+)
+
+// ErrAmbiguousKey is returned when a key is defined more than once and no occurrence was chosen.
+var ErrAmbiguousKey = errors.New("key defined more than once")
+
+// ErrUnquotable is returned for a value that no quoting lets dotenv loaders read back unchanged.
+var ErrUnquotable = errors.New("value cannot be written to an env file")
+
+// doubleQuoteEscaper escapes what dotenv loaders unescape or expand in a double-quoted value.
+var doubleQuoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`, "\r", `\r`)
 
 func IsEmptyOrBlank(lines []string) bool {
 	return len(lines) == 0 || (len(lines) == 1 && lines[0] == "")
@@ -9,3 +32,127 @@ func IsEmptyOrBlank(lines []string) bool {
 func EndsWithoutNewline(lines []string) bool {
 	return len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n")
 }
+
+// KeepTrailingNewline makes the last of newLines end with a newline only if the last of orgLines did.
+func KeepTrailingNewline(orgLines, newLines []string) []string {
+	lastLineIndex := len(newLines) - 1
+	if lastLineIndex < 0 {
+		return newLines
+	}
+	newLines[lastLineIndex] = strings.TrimSuffix(newLines[lastLineIndex], "\n")
+	if !EndsWithoutNewline(orgLines) {
+		newLines[lastLineIndex] += "\n"
+	}
+	return newLines
+}
+
+// Key returns the key of an assignment line such as KEY=value.
+// Empty lines, comments and lines without '=' report false.
+func Key(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", false
+	}
+	key, _, found := strings.Cut(line, "=")
+	if !found {
+		return "", false
+	}
+	return strings.TrimSpace(key), true
+}
+
+// Value returns the parsed value of an assignment line, with quotes removed and escapes expanded
+// the way dotenv loaders do.
+func Value(line string) (string, bool) {
+	key, ok := Key(line)
+	if !ok {
+		return "", false
+	}
+	env, err := godotenv.Unmarshal(strings.TrimSpace(line))
+	if err != nil {
+		return "", false
+	}
+	value, ok := env[key]
+	return value, ok
+}
+
+// Quote returns value in the form to write after KEY= so that dotenv loaders read it back
+// unchanged: double quoted with backslashes, quotes, dollars and line breaks escaped. Double
+// quotes cannot hold a value ending in a quote or a backslash, so such a value is single quoted,
+// or left unquoted, when that reads back unchanged. Returns ErrUnquotable otherwise.
+func Quote(value string) (string, error) {
+	candidates := []string{
+		`"` + doubleQuoteEscaper.Replace(value) + `"`,
+		"'" + value + "'",
+		strings.ReplaceAll(value, "$", `\$`),
+	}
+	for _, quoted := range candidates {
+		if strings.ContainsAny(quoted, "\n\r") {
+			continue
+		}
+		if env, err := godotenv.Unmarshal("KEY=" + quoted); err == nil && env["KEY"] == value {
+			return quoted, nil
+		}
+	}
+	return "", ErrUnquotable
+}
+
+// MatchKey reports whether key equals pattern or matches it as a glob pattern, such as DB_*.
+func MatchKey(pattern, key string) bool {
+	if pattern == key {
+		return true
+	}
+	matched, err := path.Match(pattern, key)
+	return err == nil && matched
+}
+
+// KeyIndexes returns the indexes of all assignment lines for key.
+func KeyIndexes(lines []string, key string) []int {
+	indexes := []int{}
+	for i, line := range lines {
+		if k, ok := Key(line); ok && k == key {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// SelectOccurrences narrows the indexes of a key's lines down to the chosen occurrence.
+// A key found on more than one line requires an occurrence, otherwise ErrAmbiguousKey is returned.
+// The error does not say how to choose one, which depends on the command: callers add that.
+func SelectOccurrences(key string, indexes []int, occurrence string) ([]int, error) {
+	if len(indexes) <= 1 {
+		return indexes, nil
+	}
+	switch occurrence {
+	case OccurrenceAll:
+		return indexes, nil
+	case OccurrenceFirst:
+		return indexes[:1], nil
+	case OccurrenceLast:
+		return indexes[len(indexes)-1:], nil
+	case "":
+		lineNums := make([]string, len(indexes))
+		for i, index := range indexes {
+			lineNums[i] = fmt.Sprint(index + 1)
+		}
+		return nil, fmt.Errorf("%w: %s on lines %s", ErrAmbiguousKey, key, strings.Join(lineNums, ", "))
+	default:
+		return nil, fmt.Errorf("unknown occurrence %q", occurrence)
+	}
+}
+
+// ParseOccurrence returns the occurrence chosen by the --all, --first and --last flags.
+// At most one of them may be set.
+func ParseOccurrence(all, first, last bool) (string, error) {
+	occurrence := ""
+	for name, set := range map[string]bool{OccurrenceAll: all, OccurrenceFirst: first, OccurrenceLast: last} {
+		if !set {
+			continue
+		}
+		if occurrence != "" {
+			return "", errors.New("only one of --all, --first and --last may be set")
+		}
+		occurrence = name
+	}
+	return occurrence, nil
+}
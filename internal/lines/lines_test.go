@@ -0,0 +1,49 @@
+package lines
+
+import (
+	"testing"
+
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuote(t *testing.T) {
+	tests := map[string]struct {
+		value   string
+		want    string
+		wantErr error
+	}{
+		"plain":                 {value: "v", want: `"v"`},
+		"empty":                 {value: "", want: `""`},
+		"references":            {value: "x$A${B}$(C)", want: `"x\$A\${B}\$(C)"`},
+		"tab":                   {value: "a\tb", want: "\"a\tb\""},
+		"backslashes":           {value: `a\b\n`, want: `"a\\b\\n"`},
+		"double quotes":         {value: `say "hi" now`, want: `"say \"hi\" now"`},
+		"newlines":              {value: "line1\nline2\r\n", want: `"line1\nline2\r\n"`},
+		"single quotes":         {value: "it's", want: `"it's"`},
+		"hash":                  {value: "a #b", want: `"a #b"`},
+		"ending in a quote":     {value: `say "hi"`, want: `'say "hi"'`},
+		"ending in a backslash": {value: `C:\dir\`, want: `C:\dir\`},
+		"backslash and dollar":  {value: `$HOME\`, want: `\$HOME\`},
+		"unquotable":            {value: "'a\\", wantErr: ErrUnquotable},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Quote(tt.value)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			// Read back after other variables, which must not be expanded into the value.
+			env, err := godotenv.Unmarshal("A=1\nB=2\nC=3\nHOME=/root\nKEY=" + got + "\nNEXT=n\n")
+			require.NoError(t, err)
+			assert.Equal(t, tt.value, env["KEY"])
+			assert.Equal(t, "n", env["NEXT"])
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package secret
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Charsets supported by Generate.
+const (
+	CharsetHex       = "hex"
+	CharsetBase64    = "base64"
+	CharsetBase64URL = "base64url"
+	CharsetAlnum     = "alnum"
+	CharsetUUID      = "uuid"
+	CharsetWords     = "words"
+)
+
+// Charsets lists the names accepted by Generate.
+var Charsets = []string{CharsetHex, CharsetBase64, CharsetBase64URL, CharsetAlnum, CharsetUUID, CharsetWords}
+
+const (
+	defaultLength = 32  // characters
+	minimumBits   = 128 // of a secret of the default length
+	alnum         = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+//go:embed words.txt
+var wordList string
+
+var words = strings.Fields(wordList)
+
+// defaultWords is the number of words that gives a secret of at least minimumBits, each word
+// adding log2(len(words)) bits.
+var defaultWords = int(math.Ceil(minimumBits / math.Log2(float64(len(words)))))
+
+// Generate returns a random secret from crypto/rand in the given charset.
+// length is the number of characters, or the number of words for the words charset;
+// zero selects the default. It is ignored for uuid.
+func Generate(charset string, length int) (string, error) {
+	if length < 0 {
+		return "", fmt.Errorf("length must be a non-negative integer, got %d", length)
+	}
+	if length == 0 {
+		length = defaultLength
+		if charset == CharsetWords {
+			length = defaultWords
+		}
+	}
+
+	switch charset {
+	case CharsetHex:
+		b, err := randomBytes((length + 1) / 2)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(b)[:length], nil
+	case CharsetBase64:
+		b, err := randomBytes(length*3/4 + 3)
+		if err != nil {
+			return "", err
+		}
+		return base64.RawStdEncoding.EncodeToString(b)[:length], nil
+	case CharsetBase64URL:
+		b, err := randomBytes(length*3/4 + 3)
+		if err != nil {
+			return "", err
+		}
+		return base64.RawURLEncoding.EncodeToString(b)[:length], nil
+	case CharsetAlnum:
+		var sb strings.Builder
+		for range length {
+			i, err := randomIndex(len(alnum))
+			if err != nil {
+				return "", err
+			}
+			sb.WriteByte(alnum[i])
+		}
+		return sb.String(), nil
+	case CharsetUUID:
+		b, err := randomBytes(16)
+		if err != nil {
+			return "", err
+		}
+		b[6] = (b[6] & 0x0f) | 0x40 // version 4
+		b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+	case CharsetWords:
+		picked := make([]string, length)
+		for i := range picked {
+			j, err := randomIndex(len(words))
+			if err != nil {
+				return "", err
+			}
+			picked[i] = words[j]
+		}
+		return strings.Join(picked, "-"), nil
+	default:
+		return "", fmt.Errorf("unknown charset %q (expected one of %s)", charset, strings.Join(Charsets, ", "))
+	}
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("error generating random bytes: %w", err)
+	}
+	return b, nil
+}
+
+func randomIndex(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("error generating random number: %w", err)
+	}
+	return int(i.Int64()), nil
+}
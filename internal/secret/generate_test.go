@@ -0,0 +1,55 @@
+package secret
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	tests := map[string]struct {
+		charset string
+		length  int
+		pattern string
+		wantErr bool
+	}{
+		"hex":             {charset: CharsetHex, length: 11, pattern: `^[0-9a-f]{11}$`},
+		"base64":          {charset: CharsetBase64, length: 40, pattern: `^[A-Za-z0-9+/]{40}$`},
+		"base64url":       {charset: CharsetBase64URL, length: 0, pattern: `^[A-Za-z0-9_-]{32}$`},
+		"alnum":           {charset: CharsetAlnum, length: 20, pattern: `^[A-Za-z0-9]{20}$`},
+		"uuid":            {charset: CharsetUUID, length: 5, pattern: `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		"words":           {charset: CharsetWords, length: 4, pattern: `^[a-z]+(-[a-z]+){3}$`},
+		"default words":   {charset: CharsetWords, length: 0, pattern: `^[a-z]+(-[a-z]+){15}$`},
+		"unknown charset": {charset: "emoji", wantErr: true},
+		"negative length": {charset: CharsetHex, length: -1, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Generate(tt.charset, tt.length)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Regexp(t, regexp.MustCompile(tt.pattern), got)
+		})
+	}
+}
+
+func TestGenerate_DefaultWordsStrength(t *testing.T) {
+	assert.Len(t, words, len(slices.Compact(slices.Sorted(slices.Values(words)))), "the word list has duplicates")
+	assert.GreaterOrEqual(t, float64(defaultWords)*math.Log2(float64(len(words))), 128.0)
+}
+
+func TestGenerate_Unique(t *testing.T) {
+	a, err := Generate(CharsetAlnum, 32)
+	assert.NoError(t, err)
+	b, err := Generate(CharsetAlnum, 32)
+	assert.NoError(t, err)
+	assert.NotEqual(t, a, b)
+	assert.False(t, strings.Contains(a, " "))
+}
@@ -0,0 +1,67 @@
+package sensitive
+
+import (
+	"path"
+	"strings"
+
+	"github.com/ba58ajbse/envcraft/internal/crypt"
+	"github.com/ba58ajbse/envcraft/internal/lines"
+)
+
+// Annotations mark the key below them as secret or not, overriding the patterns:
+//
+//	# envcraft:secret
+//	SESSION_SALT="..."
+const (
+	SecretAnnotation = "# envcraft:secret"
+	PublicAnnotation = "# envcraft:public"
+)
+
+// DefaultPatterns are the glob patterns of key names treated as secret when no annotation says otherwise.
+var DefaultPatterns = []string{"*PASSWORD*", "*PASSWD*", "*SECRET*", "*TOKEN*", "*_KEY", "*API_KEY*", "*PRIVATE*", "*CREDENTIAL*", "*_DSN", "*DATABASE_URL*"}
+
+// Keys reports for every key of envLines whether it holds a secret. An annotation in the comment
+// block directly above the key decides; otherwise a key is secret if its value is encrypted or
+// its name matches one of patterns.
+func Keys(envLines []string, patterns []string) map[string]bool {
+	secret := map[string]bool{}
+	annotation := ""
+	for _, line := range envLines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == SecretAnnotation || trimmed == PublicAnnotation:
+			annotation = trimmed
+			continue
+		case strings.HasPrefix(trimmed, "#"):
+			continue
+		}
+
+		key, ok := lines.Key(line)
+		if !ok {
+			annotation = ""
+			continue
+		}
+		key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+		value, _ := lines.Value(line)
+		switch {
+		case annotation == SecretAnnotation:
+			secret[key] = true
+		case annotation == PublicAnnotation:
+			secret[key] = false
+		case !secret[key]:
+			secret[key] = crypt.IsEncryptedValue(value) || MatchesAny(key, patterns)
+		}
+		annotation = ""
+	}
+	return secret
+}
+
+// MatchesAny reports whether key matches one of the glob patterns, ignoring case.
+func MatchesAny(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(strings.ToUpper(pattern), strings.ToUpper(key)); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package sensitive
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeys(t *testing.T) {
+	envLines := strings.SplitAfter(strings.Join([]string{
+		"APP_NAME=web",
+		"DB_PASSWORD=secret",
+		"# envcraft:secret",
+		"SESSION_SALT=salt",
+		"# envcraft:public",
+		"# the key id is published",
+		"PUBLIC_API_KEY=pk_123",
+		"API_TOKEN=\"enc:v1:scrypt:abc:def\"",
+		"HOST=enc:v1:keyfile:abc:def",
+		"",
+	}, "\n"), "\n")
+
+	got := Keys(envLines, DefaultPatterns)
+	assert.Equal(t, map[string]bool{
+		"APP_NAME":       false,
+		"DB_PASSWORD":    true,
+		"SESSION_SALT":   true,
+		"PUBLIC_API_KEY": false,
+		"API_TOKEN":      true,
+		"HOST":           true,
+	}, got)
+}
@@ -1,14 +1,41 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
+	"github.com/ba58ajbse/envcraft/internal/auditlog"
 	"github.com/ba58ajbse/envcraft/internal/commands/add"
+	"github.com/ba58ajbse/envcraft/internal/commands/audit"
+	"github.com/ba58ajbse/envcraft/internal/commands/batch"
 	"github.com/ba58ajbse/envcraft/internal/commands/comment"
+	"github.com/ba58ajbse/envcraft/internal/commands/compose"
+	"github.com/ba58ajbse/envcraft/internal/commands/decrypt"
+	"github.com/ba58ajbse/envcraft/internal/commands/dedupe"
 	"github.com/ba58ajbse/envcraft/internal/commands/delete"
+	"github.com/ba58ajbse/envcraft/internal/commands/diff"
+	"github.com/ba58ajbse/envcraft/internal/commands/edit"
+	"github.com/ba58ajbse/envcraft/internal/commands/encrypt"
+	"github.com/ba58ajbse/envcraft/internal/commands/export"
+	"github.com/ba58ajbse/envcraft/internal/commands/fingerprint"
+	"github.com/ba58ajbse/envcraft/internal/commands/gha"
+	"github.com/ba58ajbse/envcraft/internal/commands/git"
+	"github.com/ba58ajbse/envcraft/internal/commands/guard"
+	"github.com/ba58ajbse/envcraft/internal/commands/history"
+	"github.com/ba58ajbse/envcraft/internal/commands/importer"
+	"github.com/ba58ajbse/envcraft/internal/commands/k8s"
+	"github.com/ba58ajbse/envcraft/internal/commands/keys"
+	"github.com/ba58ajbse/envcraft/internal/commands/matrix"
+	"github.com/ba58ajbse/envcraft/internal/commands/merge"
+	"github.com/ba58ajbse/envcraft/internal/commands/patch"
+	"github.com/ba58ajbse/envcraft/internal/commands/profiles"
+	"github.com/ba58ajbse/envcraft/internal/commands/recipients"
+	"github.com/ba58ajbse/envcraft/internal/commands/rotate"
 	"github.com/ba58ajbse/envcraft/internal/commands/run"
 	"github.com/ba58ajbse/envcraft/internal/commands/update"
+	"github.com/ba58ajbse/envcraft/internal/fs"
+	"github.com/ba58ajbse/envcraft/internal/journal"
 )
 
 func main() {
@@ -20,22 +47,69 @@ func main() {
 	command := os.Args[1]
 	opts := os.Args[2:]
 	commands := map[string]func([]string) error{
-		"add":     add.Run,
-		"update":  update.Run,
-		"delete":  delete.Run,
-		"comment": comment.Run,
-		"run":     run.Run,
+		"add":        add.Run,
+		"update":     update.Run,
+		"delete":     delete.Run,
+		"comment":    comment.Run,
+		"run":        run.Run,
+		"dedupe":     dedupe.Run,
+		"rotate":     rotate.Run,
+		"encrypt":    encrypt.Run,
+		"decrypt":    decrypt.Run,
+		"edit":       edit.Run,
+		"export":     export.Run,
+		"gha":        gha.Run,
+		"import":     importer.Run,
+		"k8s":        k8s.Run,
+		"keys":       keys.Run,
+		"recipients": recipients.Run,
+		"compose":    compose.Run,
+		"profiles":   profiles.Run,
+		"diff":       diff.Run,
+		"matrix":     matrix.Run,
+
+		"fingerprint": fingerprint.Run,
+		"undo":        history.Undo,
+		"redo":        history.Redo,
+		"history":     history.Run,
+		"audit":       audit.Run,
+		"apply-batch": batch.Run,
+		"patch":       patch.Run,
+		"merge":       merge.Run,
+		"git":         git.Run,
+		"guard":       guard.Run,
 	}
 	cmd, ok := commands[command]
 	if !ok {
-		fmt.Println("Usage: envcraft [add|update|delete|comment|run] [flags]")
+		fmt.Println("Usage: envcraft [add|update|delete|comment|run|dedupe|rotate|encrypt|decrypt|edit|export|gha|import|k8s|keys|recipients|compose|profiles|diff|matrix|fingerprint|undo|redo|history|audit|apply-batch|patch|merge|git|guard] [flags]")
 		os.Exit(1)
 	}
 
+	// Every file a command rewrites is journaled so that the change can be undone,
+	// and audited when an audit log is configured.
+	journalWrite := journal.Recorder(command)
+	auditWrite := auditlog.Recorder(command)
+	fs.OnWrite = func(filePath string, before, after []byte) {
+		journalWrite(filePath, before, after)
+		auditWrite(filePath, before, after)
+	}
+
 	if err := cmd(opts); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		// Commands such as diff report outcomes through distinct exit codes,
+		// and run passes on the exit code of the command it ran.
+		var exit interface{ ExitCode() int }
+		if errors.As(err, &exit) && exit.ExitCode() > 0 {
+			os.Exit(exit.ExitCode())
+		}
 		os.Exit(1)
 	}
 
-	fmt.Println("\n✅", command, "completed.")
+	// git runs textconv for every file it shows, so it prints nothing but the converted text.
+	if command == "git" && len(opts) > 0 && opts[0] == git.ActionTextconv {
+		return
+	}
+
+	// Status goes to stderr so commands such as export can be piped.
+	fmt.Fprintln(os.Stderr, "\n✅", command, "completed.")
 }